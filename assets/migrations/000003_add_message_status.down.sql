@@ -0,0 +1,5 @@
+BEGIN;
+
+ALTER TABLE messages DROP COLUMN IF EXISTS status;
+
+COMMIT;
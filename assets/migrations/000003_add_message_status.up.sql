@@ -0,0 +1,6 @@
+BEGIN;
+
+-- Track how an assistant reply ended (e.g. completed or stopped by the user)
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS status TEXT NOT NULL DEFAULT 'completed';
+
+COMMIT;
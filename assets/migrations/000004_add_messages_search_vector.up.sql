@@ -0,0 +1,23 @@
+BEGIN;
+
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS search_vector TSVECTOR;
+
+-- Keep the search vector in sync with the message content
+CREATE OR REPLACE FUNCTION messages_search_vector_update() RETURNS trigger AS $$
+BEGIN
+    NEW.search_vector := to_tsvector('english', coalesce(NEW.content, ''));
+    RETURN NEW;
+END
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS trg_messages_search_vector ON messages;
+CREATE TRIGGER trg_messages_search_vector
+    BEFORE INSERT OR UPDATE OF content ON messages
+    FOR EACH ROW EXECUTE PROCEDURE messages_search_vector_update();
+
+-- Backfill the existing messages
+UPDATE messages SET search_vector = to_tsvector('english', coalesce(content, ''));
+
+CREATE INDEX IF NOT EXISTS idx_messages_search_vector ON messages USING GIN (search_vector);
+
+COMMIT;
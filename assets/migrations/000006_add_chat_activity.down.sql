@@ -0,0 +1,15 @@
+BEGIN;
+
+DROP INDEX IF EXISTS idx_chats_user_id_created_at;
+DROP INDEX IF EXISTS idx_chats_user_id_last_message_at;
+DROP TRIGGER IF EXISTS trg_chats_message_count ON messages;
+DROP FUNCTION IF EXISTS chats_message_count_update();
+DROP TRIGGER IF EXISTS trg_chats_activity ON messages;
+DROP FUNCTION IF EXISTS chats_activity_update();
+DROP FUNCTION IF EXISTS message_is_active(TEXT, JSONB);
+ALTER TABLE chats DROP COLUMN IF EXISTS last_message_preview;
+ALTER TABLE chats DROP COLUMN IF EXISTS message_count;
+ALTER TABLE chats DROP COLUMN IF EXISTS last_message_at;
+ALTER TABLE chats DROP COLUMN IF EXISTS updated_at;
+
+COMMIT;
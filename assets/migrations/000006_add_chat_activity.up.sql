@@ -0,0 +1,83 @@
+BEGIN;
+
+ALTER TABLE chats ADD COLUMN IF NOT EXISTS updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW();
+ALTER TABLE chats ADD COLUMN IF NOT EXISTS last_message_at TIMESTAMPTZ NOT NULL DEFAULT NOW();
+ALTER TABLE chats ADD COLUMN IF NOT EXISTS message_count INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE chats ADD COLUMN IF NOT EXISTS last_message_preview TEXT NOT NULL DEFAULT '';
+
+-- Only the active messages are counted, i.e. neither failed nor superseded by a regenerated reply
+CREATE OR REPLACE FUNCTION message_is_active(status TEXT, metadata JSONB) RETURNS BOOLEAN AS $$
+    SELECT status <> 'failed' AND NOT COALESCE(metadata ? 'superseded_by', FALSE)
+$$ LANGUAGE sql IMMUTABLE;
+
+-- Backfill the activity of the existing chats
+UPDATE chats SET last_message_at = created_at, updated_at = created_at;
+
+UPDATE chats c SET
+    last_message_at = s.last_message_at,
+    updated_at = s.last_message_at,
+    message_count = s.message_count,
+    last_message_preview = s.last_message_preview
+FROM (
+    SELECT DISTINCT ON (chat_id)
+        chat_id,
+        created_at AS last_message_at,
+        COUNT(*) FILTER (WHERE message_is_active(status, metadata)) OVER (PARTITION BY chat_id) AS message_count,
+        LEFT(content, 200) AS last_message_preview
+    FROM messages
+    ORDER BY chat_id, created_at DESC
+) s
+WHERE s.chat_id = c.id;
+
+-- Keep the activity of the chats in sync with their messages.
+-- The first message always sets the activity, so imported chats keep their original timestamps.
+CREATE OR REPLACE FUNCTION chats_activity_update() RETURNS trigger AS $$
+BEGIN
+    UPDATE chats SET
+        message_count = message_count + message_is_active(NEW.status, NEW.metadata)::INT,
+        updated_at = NOW(),
+        last_message_at = CASE
+            WHEN message_count = 0 OR NEW.created_at >= last_message_at THEN NEW.created_at
+            ELSE last_message_at END,
+        last_message_preview = CASE
+            WHEN (message_count = 0 OR NEW.created_at >= last_message_at) AND NEW.content <> '' THEN LEFT(NEW.content, 200)
+            ELSE last_message_preview END
+    WHERE id = NEW.chat_id;
+    RETURN NEW;
+END
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS trg_chats_activity ON messages;
+CREATE TRIGGER trg_chats_activity
+    AFTER INSERT ON messages
+    FOR EACH ROW EXECUTE PROCEDURE chats_activity_update();
+
+-- Messages stop being counted once they fail or get superseded, and when they are deleted.
+CREATE OR REPLACE FUNCTION chats_message_count_update() RETURNS trigger AS $$
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        UPDATE chats SET message_count = message_count - message_is_active(OLD.status, OLD.metadata)::INT
+        WHERE id = OLD.chat_id;
+        RETURN OLD;
+    END IF;
+
+    IF message_is_active(NEW.status, NEW.metadata) <> message_is_active(OLD.status, OLD.metadata) THEN
+        UPDATE chats SET
+            message_count = message_count + message_is_active(NEW.status, NEW.metadata)::INT
+                - message_is_active(OLD.status, OLD.metadata)::INT,
+            updated_at = NOW()
+        WHERE id = NEW.chat_id;
+    END IF;
+    RETURN NEW;
+END
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS trg_chats_message_count ON messages;
+CREATE TRIGGER trg_chats_message_count
+    AFTER UPDATE OF status, metadata OR DELETE ON messages
+    FOR EACH ROW EXECUTE PROCEDURE chats_message_count_update();
+
+CREATE INDEX IF NOT EXISTS idx_chats_user_id_last_message_at ON chats(user_id, last_message_at DESC, id DESC);
+CREATE INDEX IF NOT EXISTS idx_chats_user_id_created_at ON chats(user_id, created_at DESC, id DESC);
+
+COMMIT;
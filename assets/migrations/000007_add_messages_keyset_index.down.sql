@@ -0,0 +1,5 @@
+BEGIN;
+
+DROP INDEX IF EXISTS idx_messages_chat_id_created_at;
+
+COMMIT;
@@ -0,0 +1,5 @@
+BEGIN;
+
+CREATE INDEX IF NOT EXISTS idx_messages_chat_id_created_at ON messages(chat_id, created_at DESC, id DESC);
+
+COMMIT;
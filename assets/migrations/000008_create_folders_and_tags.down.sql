@@ -0,0 +1,11 @@
+BEGIN;
+
+DROP INDEX IF EXISTS idx_chats_folder_id;
+ALTER TABLE chats DROP COLUMN IF EXISTS archived;
+ALTER TABLE chats DROP COLUMN IF EXISTS pinned;
+ALTER TABLE chats DROP COLUMN IF EXISTS folder_id;
+DROP TABLE IF EXISTS chat_tags;
+DROP TABLE IF EXISTS tags;
+DROP TABLE IF EXISTS folders;
+
+COMMIT;
@@ -0,0 +1,10 @@
+BEGIN;
+
+DROP INDEX IF EXISTS idx_chats_project_id;
+ALTER TABLE chats DROP COLUMN IF EXISTS agent;
+ALTER TABLE chats DROP COLUMN IF EXISTS project_id;
+DROP TABLE IF EXISTS project_file_chunks;
+DROP TABLE IF EXISTS project_files;
+DROP TABLE IF EXISTS projects;
+
+COMMIT;
@@ -0,0 +1,5 @@
+BEGIN;
+
+ALTER TABLE chats DROP COLUMN IF EXISTS title_state;
+
+COMMIT;
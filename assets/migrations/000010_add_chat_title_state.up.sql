@@ -0,0 +1,7 @@
+BEGIN;
+
+-- the existing chats were titled when they were created
+ALTER TABLE chats ADD COLUMN IF NOT EXISTS title_state TEXT NOT NULL DEFAULT 'generated';
+ALTER TABLE chats ALTER COLUMN title_state SET DEFAULT 'placeholder';
+
+COMMIT;
@@ -0,0 +1,6 @@
+BEGIN;
+
+ALTER TABLE chats DROP COLUMN IF EXISTS forked_from_message_id;
+ALTER TABLE chats DROP COLUMN IF EXISTS forked_from_chat_id;
+
+COMMIT;
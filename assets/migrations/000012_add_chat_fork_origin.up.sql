@@ -0,0 +1,6 @@
+BEGIN;
+
+ALTER TABLE chats ADD COLUMN IF NOT EXISTS forked_from_chat_id UUID REFERENCES chats(id) ON DELETE SET NULL;
+ALTER TABLE chats ADD COLUMN IF NOT EXISTS forked_from_message_id UUID REFERENCES messages(id) ON DELETE SET NULL;
+
+COMMIT;
@@ -0,0 +1,20 @@
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS message_feedback (
+    message_id UUID PRIMARY KEY REFERENCES messages(id) ON DELETE CASCADE,
+    chat_id UUID NOT NULL REFERENCES chats(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    rating SMALLINT NOT NULL CHECK (rating IN (-1, 1)),
+    reasons JSONB NOT NULL DEFAULT '[]',
+    comment TEXT NOT NULL DEFAULT '',
+    -- the agent and model which answered, kept for the reports
+    agent TEXT NOT NULL DEFAULT '',
+    model TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- the reports and datasets date the feedback by its last rating
+CREATE INDEX IF NOT EXISTS idx_message_feedback_updated_at ON message_feedback(updated_at);
+
+COMMIT;
@@ -0,0 +1,7 @@
+BEGIN;
+
+ALTER TABLE chats DROP COLUMN IF EXISTS generation_started_at;
+ALTER TABLE chats DROP COLUMN IF EXISTS generation_id;
+ALTER TABLE chats DROP COLUMN IF EXISTS generation_state;
+
+COMMIT;
@@ -0,0 +1,9 @@
+BEGIN;
+
+-- a chat generates one reply at a time, the generating state is its lock across the instances
+ALTER TABLE chats ADD COLUMN IF NOT EXISTS generation_state TEXT NOT NULL DEFAULT 'idle';
+-- the assistant message holding the lock, it does not exist until the reply is saved
+ALTER TABLE chats ADD COLUMN IF NOT EXISTS generation_id UUID;
+ALTER TABLE chats ADD COLUMN IF NOT EXISTS generation_started_at TIMESTAMPTZ;
+
+COMMIT;
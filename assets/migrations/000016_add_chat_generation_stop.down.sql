@@ -0,0 +1,5 @@
+BEGIN;
+
+ALTER TABLE chats DROP COLUMN IF EXISTS generation_stop_requested;
+
+COMMIT;
@@ -0,0 +1,6 @@
+BEGIN;
+
+-- set when the reply is asked to stop on another instance than the one generating it, which polls it
+ALTER TABLE chats ADD COLUMN IF NOT EXISTS generation_stop_requested BOOLEAN NOT NULL DEFAULT FALSE;
+
+COMMIT;
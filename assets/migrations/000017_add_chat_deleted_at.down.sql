@@ -0,0 +1,6 @@
+BEGIN;
+
+DROP INDEX IF EXISTS idx_chats_deleted_at;
+ALTER TABLE chats DROP COLUMN IF EXISTS deleted_at;
+
+COMMIT;
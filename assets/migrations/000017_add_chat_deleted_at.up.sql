@@ -0,0 +1,7 @@
+BEGIN;
+
+-- deleted chats stay in the trash until they are purged after the retention period
+ALTER TABLE chats ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ;
+CREATE INDEX IF NOT EXISTS idx_chats_deleted_at ON chats (deleted_at) WHERE deleted_at IS NOT NULL;
+
+COMMIT;
@@ -0,0 +1,15 @@
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS user_settings (
+    user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    about_me TEXT NOT NULL DEFAULT '',
+    how_to_respond TEXT NOT NULL DEFAULT '',
+    language TEXT NOT NULL DEFAULT '',
+    default_agent TEXT NOT NULL DEFAULT '',
+    default_model TEXT NOT NULL DEFAULT '',
+    response_length TEXT NOT NULL DEFAULT '',
+    response_style TEXT NOT NULL DEFAULT '',
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+COMMIT;
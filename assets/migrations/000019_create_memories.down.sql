@@ -0,0 +1,6 @@
+BEGIN;
+
+ALTER TABLE user_settings DROP COLUMN IF EXISTS memory_enabled;
+DROP TABLE IF EXISTS memories;
+
+COMMIT;
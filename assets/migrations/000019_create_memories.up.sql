@@ -0,0 +1,21 @@
+BEGIN;
+
+-- durable facts about the users remembered across their chats
+CREATE TABLE IF NOT EXISTS memories (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    content TEXT NOT NULL,
+    embedding VECTOR(1536) NOT NULL,
+    -- where the memory was last learned, kept when the chat is gone
+    source_chat_id UUID REFERENCES chats(id) ON DELETE SET NULL,
+    source_message_id UUID REFERENCES messages(id) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_memories_user_id ON memories(user_id);
+CREATE INDEX IF NOT EXISTS idx_memories_embedding ON memories USING hnsw (embedding vector_cosine_ops);
+
+ALTER TABLE user_settings ADD COLUMN IF NOT EXISTS memory_enabled BOOLEAN NOT NULL DEFAULT TRUE;
+
+COMMIT;
@@ -0,0 +1,17 @@
+package dtos
+
+// FineTuneChatExample is a line of a supervised fine-tuning file of the GPT API.
+type FineTuneChatExample struct {
+	Messages []*GPTMessage `json:"messages"`
+}
+
+// FineTunePreferenceExample is a line of a preference fine-tuning file of the GPT API.
+type FineTunePreferenceExample struct {
+	Input              FineTuneInput `json:"input"`
+	PreferredOutput    []*GPTMessage `json:"preferred_output"`
+	NonPreferredOutput []*GPTMessage `json:"non_preferred_output"`
+}
+
+type FineTuneInput struct {
+	Messages []*GPTMessage `json:"messages"`
+}
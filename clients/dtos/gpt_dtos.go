@@ -1,5 +1,7 @@
 package dtos
 
+import "github.com/amahdian/ai-assistant-be/domain/model/common"
+
 type GPTMessage struct {
 	Role    string `json:"role"`
 	Content string `json:"content"`
@@ -13,3 +15,75 @@ type GPTStreamChunk struct {
 		FinishReason *string `json:"finish_reason,omitempty"`
 	} `json:"choices"`
 }
+
+// GPTStreamResult is a single element of a streamed GPT response.
+// A result with a non-nil Err is always the last one sent on the stream.
+type GPTStreamResult struct {
+	Content string
+	Err     error
+}
+
+type GPTCompletionResponse struct {
+	Choices []struct {
+		Message struct {
+			Content string `json:"content"`
+		} `json:"message"`
+	} `json:"choices"`
+}
+
+// GPTSuggestions is the structured output of the follow-up suggestions.
+type GPTSuggestions struct {
+	Suggestions []string `json:"suggestions"`
+}
+
+// GPTSuggestionsFormat is the response format making the model reply with GPTSuggestions.
+var GPTSuggestionsFormat = map[string]interface{}{
+	"type": "json_schema",
+	"json_schema": map[string]interface{}{
+		"name":   "follow_up_suggestions",
+		"strict": true,
+		"schema": map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"suggestions": map[string]interface{}{
+					"type":  "array",
+					"items": map[string]interface{}{"type": "string"},
+				},
+			},
+			"required":             []string{"suggestions"},
+			"additionalProperties": false,
+		},
+	},
+}
+
+// GPTMemories is the structured output of the memory extraction.
+type GPTMemories struct {
+	Memories []string `json:"memories"`
+}
+
+// GPTMemoriesFormat is the response format making the model reply with GPTMemories.
+var GPTMemoriesFormat = map[string]interface{}{
+	"type": "json_schema",
+	"json_schema": map[string]interface{}{
+		"name":   "user_memories",
+		"strict": true,
+		"schema": map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"memories": map[string]interface{}{
+					"type":  "array",
+					"items": map[string]interface{}{"type": "string"},
+				},
+			},
+			"required":             []string{"memories"},
+			"additionalProperties": false,
+		},
+	},
+}
+
+type GPTEmbeddingResponse struct {
+	Data []struct {
+		Index     int           `json:"index"`
+		Embedding common.Vector `json:"embedding"`
+	} `json:"data"`
+}
@@ -3,11 +3,15 @@ package clients
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"github.com/amahdian/ai-assistant-be/clients/dtos"
 	"github.com/amahdian/ai-assistant-be/domain/model"
+	"github.com/amahdian/ai-assistant-be/domain/model/common"
+	"github.com/amahdian/ai-assistant-be/pkg/logger"
 	"github.com/pkg/errors"
+	"github.com/samber/lo"
 	"io"
 	"net/http"
 	"strings"
@@ -16,11 +20,30 @@ import (
 
 const defaultSystemPrompt = "You are a helpful assistant for the AI-Assistant App. You are powered by a sophisticated AI model."
 
+// ChatModel is the model answering the chats unless the user chose another one,
+// the model of a reply is recorded in its metadata.
+const ChatModel = "gpt-4o"
+
+// ChatModels are the models the users can choose to answer their chats.
+var ChatModels = []string{ChatModel, "gpt-4o-mini", "gpt-4.1", "gpt-4.1-mini"}
+
+// suggestionModel is the cheaper model suggesting the follow-up questions of the replies
+// and extracting the memories of the users.
+const suggestionModel = "gpt-4o-mini"
+
+// embeddingModel must produce vectors of model.EmbeddingDimensions dimensions.
+const embeddingModel = "text-embedding-3-small"
+
 // GPTClient defines the interface for interacting with the GPT model.
 type GPTClient interface {
-	SendToGPT(systemPrompt, summary string, messages []*model.Message) (string, error)
+	SendToGPT(chatModel, systemPrompt, summary string, messages []*model.Message) (string, error)
 	SendMessages(messages []*model.Message) (string, error)
-	SendToGPTStream(systemPrompt, summary string, messages []*model.Message) (<-chan string, error)
+	SendToGPTStream(ctx context.Context, chatModel, systemPrompt, summary string, messages []*model.Message) (<-chan *dtos.GPTStreamResult, error)
+	CreateEmbeddings(ctx context.Context, inputs []string) ([]common.Vector, error)
+	// SuggestFollowUps asks for the follow-up questions described by the prompt as structured output.
+	SuggestFollowUps(ctx context.Context, prompt []*model.Message) ([]string, error)
+	// ExtractMemories asks for the facts about the user described by the prompt as structured output.
+	ExtractMemories(ctx context.Context, prompt []*model.Message) ([]string, error)
 }
 
 type gptClient struct {
@@ -42,19 +65,19 @@ func NewGPTClient(baseUrl, token string) GPTClient {
 }
 
 func (c *gptClient) SendMessages(messages []*model.Message) (string, error) {
-	return c.SendToGPT(defaultSystemPrompt, "", messages)
+	return c.SendToGPT(ChatModel, defaultSystemPrompt, "", messages)
 }
 
 // SendToGPT sends a request and gets a complete response.
-func (c *gptClient) SendToGPT(systemPrompt, summary string, messages []*model.Message) (string, error) {
-	payload := c.createPayload(systemPrompt, summary, messages, false)
+func (c *gptClient) SendToGPT(chatModel, systemPrompt, summary string, messages []*model.Message) (string, error) {
+	payload := c.createPayload(chatModel, systemPrompt, summary, messages, false)
 
 	body, err := json.Marshal(payload)
 	if err != nil {
 		return "", errors.Wrap(err, "failed to marshal payload")
 	}
 
-	resp, err := c.doRequest(body)
+	resp, err := c.doRequest(context.Background(), "/chat/completions", body)
 	if err != nil {
 		return "", err
 	}
@@ -85,32 +108,129 @@ func (c *gptClient) SendToGPT(systemPrompt, summary string, messages []*model.Me
 }
 
 // SendToGPTStream sends a request and returns a channel for streaming the response.
-func (c *gptClient) SendToGPTStream(systemPrompt, summary string, messages []*model.Message) (<-chan string, error) {
-	payload := c.createPayload(systemPrompt, summary, messages, true)
+// Cancelling ctx aborts the upstream request and closes the channel.
+func (c *gptClient) SendToGPTStream(ctx context.Context, chatModel, systemPrompt, summary string, messages []*model.Message) (<-chan *dtos.GPTStreamResult, error) {
+	payload := c.createPayload(chatModel, systemPrompt, summary, messages, true)
 
 	body, err := json.Marshal(payload)
 	if err != nil {
 		return nil, errors.Wrapf(err, "failed to marshal payload")
 	}
 
-	resp, err := c.doRequest(body)
+	resp, err := c.doRequest(ctx, "/chat/completions", body)
 	if err != nil {
 		return nil, err
 	}
 
 	if resp.StatusCode != http.StatusOK {
+		bodyBytes, _ := io.ReadAll(resp.Body)
 		resp.Body.Close()
-		return nil, errors.Wrapf(err, "API returned status code: %d", resp.StatusCode)
+		return nil, fmt.Errorf("API returned status code: %d, body: %s", resp.StatusCode, string(bodyBytes))
 	}
 
-	streamChan := make(chan string)
-	go c.processStream(resp, streamChan)
+	streamChan := make(chan *dtos.GPTStreamResult)
+	go c.processStream(ctx, resp, streamChan)
 
 	return streamChan, nil
 }
 
+// CreateEmbeddings returns the embeddings of the inputs in the same order.
+func (c *gptClient) CreateEmbeddings(ctx context.Context, inputs []string) ([]common.Vector, error) {
+	body, err := json.Marshal(map[string]interface{}{
+		"model": embeddingModel,
+		"input": inputs,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to marshal payload")
+	}
+
+	resp, err := c.doRequest(ctx, "/embeddings", body)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	bodyBytes, _ := io.ReadAll(resp.Body)
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("API returned status code: %d, body: %s", resp.StatusCode, string(bodyBytes))
+	}
+
+	var result dtos.GPTEmbeddingResponse
+	if err = json.Unmarshal(bodyBytes, &result); err != nil {
+		return nil, errors.Wrapf(err, "failed to decode response: %s", string(bodyBytes))
+	}
+	if len(result.Data) != len(inputs) {
+		return nil, fmt.Errorf("API returned %d embeddings for %d inputs", len(result.Data), len(inputs))
+	}
+
+	embeddings := make([]common.Vector, len(inputs))
+	for _, d := range result.Data {
+		if d.Index < 0 || d.Index >= len(inputs) {
+			return nil, fmt.Errorf("API returned an embedding for unknown input %d", d.Index)
+		}
+		embeddings[d.Index] = d.Embedding
+	}
+	return embeddings, nil
+}
+
+func (c *gptClient) SuggestFollowUps(ctx context.Context, prompt []*model.Message) ([]string, error) {
+	var suggestions dtos.GPTSuggestions
+	if err := c.completeStructured(ctx, prompt, dtos.GPTSuggestionsFormat, &suggestions); err != nil {
+		return nil, err
+	}
+	return suggestions.Suggestions, nil
+}
+
+func (c *gptClient) ExtractMemories(ctx context.Context, prompt []*model.Message) ([]string, error) {
+	var memories dtos.GPTMemories
+	if err := c.completeStructured(ctx, prompt, dtos.GPTMemoriesFormat, &memories); err != nil {
+		return nil, err
+	}
+	return memories.Memories, nil
+}
+
+// completeStructured sends the prompt to the cheaper model and decodes its reply in the response format into out.
+func (c *gptClient) completeStructured(ctx context.Context, prompt []*model.Message, format map[string]interface{}, out interface{}) error {
+	body, err := json.Marshal(map[string]interface{}{
+		"model": suggestionModel,
+		"messages": lo.Map(prompt, func(m *model.Message, _ int) *dtos.GPTMessage {
+			return &dtos.GPTMessage{Role: m.Role, Content: m.Content}
+		}),
+		"response_format": format,
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal payload")
+	}
+
+	resp, err := c.doRequest(ctx, "/chat/completions", body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	bodyBytes, _ := io.ReadAll(resp.Body)
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("API returned status code: %d, body: %s", resp.StatusCode, string(bodyBytes))
+	}
+
+	var result dtos.GPTCompletionResponse
+	if err = json.Unmarshal(bodyBytes, &result); err != nil {
+		return errors.Wrapf(err, "failed to decode response: %s", string(bodyBytes))
+	}
+	if len(result.Choices) == 0 {
+		return errors.New("no response content from API")
+	}
+
+	if err = json.Unmarshal([]byte(result.Choices[0].Message.Content), out); err != nil {
+		return errors.Wrapf(err, "failed to decode structured output: %s", result.Choices[0].Message.Content)
+	}
+	return nil
+}
+
 // createPayload builds the request body for the GPT API.
-func (c *gptClient) createPayload(systemPrompt, summary string, messages []*model.Message, stream bool) map[string]interface{} {
+func (c *gptClient) createPayload(chatModel, systemPrompt, summary string, messages []*model.Message, stream bool) map[string]interface{} {
 	var gptMessages []*dtos.GPTMessage
 
 	// 1. Set the system prompt (agent's personality)
@@ -137,18 +257,17 @@ func (c *gptClient) createPayload(systemPrompt, summary string, messages []*mode
 	}
 
 	return map[string]interface{}{
-		"model":    "gpt-4o",
+		"model":    chatModel,
 		"messages": gptMessages,
 		"stream":   stream,
 	}
 }
 
 // doRequest performs the actual HTTP request to the GPT API.
-func (c *gptClient) doRequest(body []byte) (*http.Response, error) {
-	endpoint := "/chat/completions"
+func (c *gptClient) doRequest(ctx context.Context, endpoint string, body []byte) (*http.Response, error) {
 	url := fmt.Sprintf("%s%s", c.BaseUrl, endpoint)
 
-	req, err := http.NewRequest("POST", url, bytes.NewReader(body))
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(body))
 	if err != nil {
 		return nil, errors.Wrapf(err, "failed to create request")
 	}
@@ -165,18 +284,32 @@ func (c *gptClient) doRequest(body []byte) (*http.Response, error) {
 }
 
 // processStream reads the streaming response body and sends content chunks to a channel.
-func (c *gptClient) processStream(resp *http.Response, streamChan chan string) {
+// If the stream ends before the "[DONE]" marker the error is sent as the last result.
+func (c *gptClient) processStream(ctx context.Context, resp *http.Response, streamChan chan *dtos.GPTStreamResult) {
 	defer resp.Body.Close()
 	defer close(streamChan)
 
+	send := func(result *dtos.GPTStreamResult) bool {
+		select {
+		case streamChan <- result:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+
 	reader := bufio.NewReader(resp.Body)
 	for {
 		line, err := reader.ReadBytes('\n')
 		if err != nil {
-			if err != io.EOF {
-				fmt.Printf("Error reading stream: %v\n", err)
+			if ctx.Err() != nil {
+				return
+			}
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
 			}
-			break
+			send(&dtos.GPTStreamResult{Err: errors.Wrap(err, "failed to read stream")})
+			return
 		}
 
 		dataPrefix := "data: "
@@ -188,17 +321,19 @@ func (c *gptClient) processStream(resp *http.Response, streamChan chan string) {
 		jsonStr = strings.TrimSpace(jsonStr)
 
 		if jsonStr == "[DONE]" {
-			break
+			return
 		}
 
 		var chunk dtos.GPTStreamChunk
 		if err := json.Unmarshal([]byte(jsonStr), &chunk); err != nil {
-			fmt.Printf("Error unmarshalling stream chunk: %v\n", err)
+			logger.Errorf("failed to unmarshal stream chunk: %v", err)
 			continue
 		}
 
 		if len(chunk.Choices) > 0 && chunk.Choices[0].Delta.Content != "" {
-			streamChan <- chunk.Choices[0].Delta.Content
+			if !send(&dtos.GPTStreamResult{Content: chunk.Choices[0].Delta.Content}) {
+				return
+			}
 		}
 	}
 }
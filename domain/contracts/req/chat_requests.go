@@ -1,5 +1,73 @@
 package req
 
+import (
+	"github.com/amahdian/ai-assistant-be/domain/model"
+	"github.com/amahdian/ai-assistant-be/domain/model/common"
+)
+
+// IdempotencyKeyHeader makes the retries of sending a message replay the original result.
+const IdempotencyKeyHeader = "Idempotency-Key"
+
 type SendMessage struct {
 	Message string `json:"message" binding:"required"`
 }
+
+type CreateChat struct {
+	Message string `json:"message" binding:"required"`
+	// optional, the chat is created inside the project
+	ProjectId string `json:"project_id"`
+}
+
+type RenameChat struct {
+	Title string `json:"title" binding:"required,max=200"`
+}
+
+type StopGeneration struct {
+	// optional, stops every generation of the chat when empty
+	MessageId string `json:"message_id"`
+}
+
+type ListChats struct {
+	// activity (default) or created
+	Sort      model.ChatSort `form:"sort" binding:"omitempty,oneof=activity created"`
+	FolderId  string         `form:"folder_id"`
+	ProjectId string         `form:"project_id"`
+	TagId     string         `form:"tag_id"`
+	Pinned    *bool          `form:"pinned"`
+	// archived chats are only listed when set
+	Archived bool `form:"archived"`
+	common.CursorPagination
+}
+
+type MoveChats struct {
+	ChatIds []string `json:"chat_ids" binding:"required,min=1"`
+	// the chats are moved out of any folder when empty
+	FolderId string `json:"folder_id"`
+}
+
+type DeleteChats struct {
+	ChatIds []string `json:"chat_ids" binding:"required,min=1"`
+}
+
+type TagChats struct {
+	ChatIds []string `json:"chat_ids" binding:"required,min=1"`
+	Add     []string `json:"add"`
+	Remove  []string `json:"remove"`
+}
+
+type ForkChat struct {
+	// optional, the whole chat is forked when empty
+	MessageId string `json:"message_id"`
+}
+
+type ExportChat struct {
+	// md (default), json or html
+	Format model.ChatExportFormat `form:"format" binding:"omitempty,oneof=md json html"`
+}
+
+type ListMessages struct {
+	// opaque cursor of the previous page, the latest messages are returned when empty
+	Before string `form:"before"`
+	// must be in 0-100 range. default: 20
+	Limit int `form:"limit" binding:"min=0,max=100"`
+}
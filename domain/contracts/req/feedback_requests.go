@@ -0,0 +1,45 @@
+package req
+
+import (
+	"time"
+
+	"github.com/amahdian/ai-assistant-be/domain/model"
+	"github.com/amahdian/ai-assistant-be/global"
+)
+
+type MessageUri struct {
+	Id    string `uri:"id" binding:"required"`
+	MsgId string `uri:"msgId" binding:"required"`
+}
+
+type SetFeedback struct {
+	// 1 (thumbs up) or -1 (thumbs down)
+	Rating model.FeedbackRating `json:"rating" binding:"required,oneof=1 -1"`
+	// any of model.FeedbackReasons
+	Reasons []string `json:"reasons"`
+	Comment string   `json:"comment"`
+}
+
+type FeedbackReport struct {
+	// monthly (default) or yearly
+	Period global.PeriodType `form:"period" binding:"omitempty,oneof=monthly yearly"`
+	Agent  string            `form:"agent"`
+	Model  string            `form:"model"`
+	From   *time.Time        `form:"from" time_format:"2006-01-02"`
+	To     *time.Time        `form:"to" time_format:"2006-01-02"`
+}
+
+type FeedbackDataset struct {
+	// chat (default) or preference
+	Format model.DatasetFormat `form:"format" binding:"omitempty,oneof=chat preference"`
+	// train (default) or validation
+	Split model.DatasetSplit `form:"split" binding:"omitempty,oneof=train validation"`
+	// share of the chats in the validation split, 0.1 by default
+	ValidationRatio *float64 `form:"validation_ratio" binding:"omitempty,gte=0,lte=1"`
+	// masks the personal data of the messages, true by default
+	ScrubPII *bool      `form:"scrub_pii"`
+	Agent    string     `form:"agent"`
+	Model    string     `form:"model"`
+	From     *time.Time `form:"from" time_format:"2006-01-02"`
+	To       *time.Time `form:"to" time_format:"2006-01-02"`
+}
@@ -0,0 +1,5 @@
+package req
+
+type SaveFolder struct {
+	Name string `json:"name" binding:"required"`
+}
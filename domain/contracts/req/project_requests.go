@@ -0,0 +1,13 @@
+package req
+
+type SaveProject struct {
+	Name         string `json:"name" binding:"required"`
+	Instructions string `json:"instructions"`
+	// name of the agent of the chats created in the project, the default agent when empty
+	DefaultAgent string `json:"default_agent"`
+}
+
+type ProjectFileUri struct {
+	Id     string `uri:"id" binding:"required"`
+	FileId string `uri:"fileId" binding:"required"`
+}
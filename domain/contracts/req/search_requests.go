@@ -0,0 +1,18 @@
+package req
+
+import (
+	"github.com/amahdian/ai-assistant-be/domain/model"
+	"github.com/amahdian/ai-assistant-be/domain/model/common"
+)
+
+type SearchMessages struct {
+	Query string `form:"q" binding:"required"`
+	common.Pagination
+}
+
+type SemanticSearch struct {
+	Query string `form:"q" binding:"required"`
+	// semantic (default) or hybrid, which merges the full-text and the semantic rankings
+	Mode  model.SearchMode `form:"mode" binding:"omitempty,oneof=semantic hybrid"`
+	Limit int              `form:"limit" binding:"min=0,max=100"`
+}
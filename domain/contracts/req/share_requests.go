@@ -0,0 +1,17 @@
+package req
+
+import "time"
+
+type ShareChat struct {
+	// optional, the link never expires when empty
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+type ShareUri struct {
+	Id      string `uri:"id" binding:"required"`
+	ShareId string `uri:"shareId" binding:"required"`
+}
+
+type ShareTokenUri struct {
+	Token string `uri:"token" binding:"required"`
+}
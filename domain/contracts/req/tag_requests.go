@@ -0,0 +1,5 @@
+package req
+
+type SaveTag struct {
+	Name string `json:"name" binding:"required"`
+}
@@ -1,5 +1,7 @@
 package req
 
+import "github.com/amahdian/ai-assistant-be/domain/model"
+
 type Login struct {
 	Email    string `json:"email" binding:"required"`
 	Password string `json:"password" binding:"required"`
@@ -9,3 +11,17 @@ type Register struct {
 	Email    string `json:"email" binding:"required"`
 	Password string `json:"password" binding:"required"`
 }
+
+// SaveUserSettings replaces all the settings of the user, the omitted ones are cleared.
+type SaveUserSettings struct {
+	AboutMe      string `json:"about_me" binding:"max=1500"`
+	HowToRespond string `json:"how_to_respond" binding:"max=1500"`
+	Language     string `json:"language" binding:"max=50"`
+	// the default agent and model when empty
+	DefaultAgent   string               `json:"default_agent"`
+	DefaultModel   string               `json:"default_model"`
+	ResponseLength model.ResponseLength `json:"response_length" binding:"omitempty,oneof=short balanced detailed"`
+	ResponseStyle  model.ResponseStyle  `json:"response_style" binding:"omitempty,oneof=casual formal technical"`
+	// memories are turned on when omitted
+	MemoryEnabled *bool `json:"memory_enabled"`
+}
@@ -73,6 +73,10 @@ func PaginatedOk[T any](ctx *gin.Context, data []T, pagination *common.Paginatio
 	ctx.JSON(http.StatusOK, NewPaginatedResponse(data, pagination))
 }
 
+func CursorPaginatedOk[T any](ctx *gin.Context, data []T, pagination *common.CursorPagination) {
+	ctx.JSON(http.StatusOK, NewCursorPaginatedResponse(data, pagination))
+}
+
 func Created(ctx *gin.Context, data ...interface{}) {
 	if len(data) > 0 {
 		ctx.JSON(http.StatusCreated, NewResponse(data[0]))
@@ -102,6 +106,13 @@ func WriteJsonFileBytes(ctx *gin.Context, data []byte, fileName string) {
 	_, _ = ctx.Writer.Write(data) // ignore error
 }
 
+// WriteFileBytes writes the data as a downloadable file, the file name must have its extension.
+func WriteFileBytes(ctx *gin.Context, data []byte, fileName, contentType string) {
+	ctx.Writer.Header().Set("Content-Type", contentType)
+	ctx.Writer.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q; filename*=utf-8''%q", fileName, fileName))
+	_, _ = ctx.Writer.Write(data) // ignore error
+}
+
 func setSheetHeaders(ctx *gin.Context, fileName string) {
 	fileName = strings.TrimSuffix(fileName, ".xlsx") // remove suffix ".xlsx" if present
 	fileName = fmt.Sprintf("%s.xlsx", fileName)      // add suffix ".xlsx"
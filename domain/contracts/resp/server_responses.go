@@ -32,6 +32,22 @@ type PageInfo struct {
 	IsEmpty       bool  `json:"isEmpty"`
 }
 
+type CursorPaginatedResponse[T any] struct {
+	Success  bool           `json:"success"`
+	Data     []T            `json:"data"`
+	PageInfo CursorPageInfo `json:"pageInfo"`
+}
+
+// CursorPageInfo has no total count since counting all the elements on every page would defeat the keyset pagination.
+type CursorPageInfo struct {
+	PageSize      int  `json:"pageSize"`
+	ElementsCount int  `json:"elementsCount"`
+	HasMore       bool `json:"hasMore"`
+	IsEmpty       bool `json:"isEmpty"`
+	// cursor of the next page, empty on the last page
+	NextCursor string `json:"nextCursor,omitempty"`
+}
+
 type HealthResponseDto struct {
 	AppName    string `json:"appName"`
 	AppVersion string `json:"appVersion"`
@@ -87,3 +103,21 @@ func NewPaginatedResponse[T any](data []T, pagination *common.Pagination) *Pagin
 		},
 	}
 }
+
+func NewCursorPaginatedResponse[T any](data []T, pagination *common.CursorPagination) *CursorPaginatedResponse[T] {
+	if data == nil {
+		data = make([]T, 0)
+	}
+	elementsCount := len(data)
+	return &CursorPaginatedResponse[T]{
+		Success: true,
+		Data:    data,
+		PageInfo: CursorPageInfo{
+			PageSize:      pagination.Limit,
+			ElementsCount: elementsCount,
+			HasMore:       pagination.NextCursor != "",
+			IsEmpty:       elementsCount == 0,
+			NextCursor:    pagination.NextCursor,
+		},
+	}
+}
@@ -4,6 +4,8 @@ type Agent struct {
 	Name         string
 	Description  string
 	SystemPrompt string
+	// Suggestions is the number of follow-up questions suggested after each reply, zero disables them.
+	Suggestions int
 }
 
 var AllAgents []*Agent
@@ -11,4 +13,18 @@ var AllAgents []*Agent
 var DefaultAgent = &Agent{
 	Name:         "Default",
 	SystemPrompt: "You are a helpful assistant for the AI-Assistant App. You are powered by a sophisticated AI model.",
+	Suggestions:  3,
+}
+
+// FindAgent returns the agent by its name. An empty name refers to the default agent.
+func FindAgent(name string) (*Agent, bool) {
+	if name == "" || name == DefaultAgent.Name {
+		return DefaultAgent, true
+	}
+	for _, agent := range AllAgents {
+		if agent.Name == name {
+			return agent, true
+		}
+	}
+	return nil, false
 }
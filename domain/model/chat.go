@@ -2,18 +2,84 @@ package model
 
 import (
 	"github.com/google/uuid"
+	"gorm.io/gorm"
 	"time"
 )
 
+// PlaceholderChatTitle is the title of a chat until one is generated from its first exchange.
+const PlaceholderChatTitle = "New chat"
+
+type ChatTitleState string
+
+const (
+	ChatTitleStatePlaceholder ChatTitleState = "placeholder"
+	ChatTitleStateGenerated   ChatTitleState = "generated"
+	// ChatTitleStateCustom is a title set by the user, it is never replaced by a generated one.
+	ChatTitleStateCustom ChatTitleState = "custom"
+)
+
+type ChatGenerationState string
+
+const (
+	ChatGenerationStateIdle ChatGenerationState = "idle"
+	// ChatGenerationStateGenerating locks the chat, no other message can be sent until the reply is saved.
+	ChatGenerationStateGenerating ChatGenerationState = "generating"
+)
+
+type ChatSort string
+
+const (
+	// ChatSortActivity orders the chats by their last message, most recent first.
+	ChatSortActivity ChatSort = "activity"
+	// ChatSortCreated orders the chats by their creation time, newest first.
+	ChatSortCreated ChatSort = "created"
+)
+
+// ChatFilter narrows down a chat listing. Archived chats are only listed when Archived is set.
+type ChatFilter struct {
+	Sort      ChatSort
+	FolderId  string
+	ProjectId string
+	TagId     string
+	Pinned    *bool
+	Archived  bool
+}
+
 type Chat struct {
-	ID        uuid.UUID `json:"id" gorm:"type:uuid;default:uuid_generate_v4()"`
-	UserId    string    `json:"user_id"`
-	Title     string    `json:"title"`
-	Summary   string    `json:"summary"`
-	CreatedAt time.Time `json:"created_at"`
+	ID         uuid.UUID      `json:"id" gorm:"type:uuid;default:uuid_generate_v4()"`
+	UserId     string         `json:"user_id"`
+	Title      string         `json:"title"`
+	TitleState ChatTitleState `json:"title_state"`
+	Summary    string         `json:"summary"`
+	CreatedAt  time.Time      `json:"created_at"`
+	UpdatedAt  time.Time      `json:"updated_at"`
+	FolderId   *string        `json:"folder_id"`
+	ProjectId  *string        `json:"project_id"`
+	// name of the agent answering in the chat, the default agent when empty
+	Agent    string `json:"agent"`
+	Pinned   bool   `json:"pinned"`
+	Archived bool   `json:"archived"`
+	// the chat and the last message the chat was forked from
+	ForkedFromChatId    *string `json:"forked_from_chat_id"`
+	ForkedFromMessageId *string `json:"forked_from_message_id"`
+	// set while the chat is in the trash, the deleted chats are left out of every query unless asked for
+	DeletedAt gorm.DeletedAt `json:"deleted_at"`
+
+	// maintained by the database on every message insert
+	LastMessageAt      time.Time `json:"last_message_at" gorm:"->"`
+	MessageCount       int       `json:"message_count" gorm:"->"`
+	LastMessagePreview string    `json:"last_message_preview" gorm:"->"`
+
+	// only changed by locking and unlocking the chat
+	GenerationState ChatGenerationState `json:"generation_state" gorm:"->"`
+	// the assistant message being generated
+	GenerationId *string `json:"generation_id" gorm:"->"`
 
 	User     *User      `gorm:"-" json:"-"`
+	Tags     []*Tag     `gorm:"-" json:"tags,omitempty"`
 	Messages []*Message `gorm:"-" json:"messages,omitempty"`
+	// cursor of the messages older than Messages, empty when Messages starts the chat
+	MessagesCursor string `gorm:"-" json:"messages_cursor,omitempty"`
 }
 
 func (*Chat) TableName() string {
@@ -0,0 +1,22 @@
+package model
+
+type ChatEventType string
+
+const (
+	// ChatEventGeneration is published when a generation starts in a chat.
+	ChatEventGeneration ChatEventType = "generation"
+	// ChatEventTitle is published when the title of a chat changes.
+	ChatEventTitle ChatEventType = "title"
+	// ChatEventTyping is published when the user is typing in a chat on one of their devices.
+	ChatEventTyping ChatEventType = "typing"
+)
+
+// ChatEvent is a notification about a chat that is pushed to all the connected clients of its owner.
+type ChatEvent struct {
+	Type      ChatEventType `json:"type"`
+	ChatID    string        `json:"chat_id"`
+	MessageID string        `json:"message_id,omitempty"`
+	// EventID is the stream position to subscribe from for generation events.
+	EventID string `json:"event_id,omitempty"`
+	Title   string `json:"title,omitempty"`
+}
@@ -0,0 +1,43 @@
+package model
+
+import (
+	"time"
+
+	"github.com/amahdian/ai-assistant-be/domain/model/common"
+)
+
+type ChatExportFormat string
+
+const (
+	ChatExportFormatMarkdown ChatExportFormat = "md"
+	ChatExportFormatJson     ChatExportFormat = "json"
+	ChatExportFormatHtml     ChatExportFormat = "html"
+)
+
+// ChatExportVersion is the version of the JSON export format.
+// Fields are only ever added to the format, the version is bumped on incompatible changes.
+const ChatExportVersion = 1
+
+// ChatExport is the JSON export of a chat, which can be imported again.
+type ChatExport struct {
+	Version    int       `json:"version"`
+	ExportedAt time.Time `json:"exported_at"`
+	Title      string    `json:"title"`
+	// name of the agent answering in the chat, the default agent when empty
+	Agent     string    `json:"agent,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	// every message of the chat in chronological order, including the replies superseded by a regenerated one
+	Messages []*ChatExportMessage `json:"messages"`
+}
+
+type ChatExportMessage struct {
+	// id of the message in the exported chat, the regenerated_from and superseded_by metadata refer to it
+	ID        string          `json:"id"`
+	Role      string          `json:"role"`
+	Content   string          `json:"content"`
+	Status    MessageStatus   `json:"status"`
+	CreatedAt time.Time       `json:"created_at"`
+	Metadata  common.Metadata `json:"metadata,omitempty"`
+	// the project document excerpts the assistant was given for the reply
+	Citations []*Citation `json:"citations,omitempty"`
+}
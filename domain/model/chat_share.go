@@ -0,0 +1,39 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ChatShare is a public read-only link to a snapshot of a chat. Revoking the link deletes it.
+type ChatShare struct {
+	ID uuid.UUID `json:"id" gorm:"type:uuid;default:uuid_generate_v4()"`
+	// Token is the unguessable part of the public link
+	Token  string `json:"token"`
+	ChatID string `json:"chat_id"`
+	UserId string `json:"user_id"`
+	// the active messages of the chat when it was shared
+	Snapshot  *ChatExport `json:"snapshot,omitempty" gorm:"type:jsonb;serializer:json"`
+	ExpiresAt *time.Time  `json:"expires_at"`
+	ViewCount int         `json:"view_count"`
+	CreatedAt time.Time   `json:"created_at"`
+}
+
+func (*ChatShare) TableName() string {
+	return "chat_shares"
+}
+
+// Expired reports whether the link can no longer be viewed.
+func (s *ChatShare) Expired(now time.Time) bool {
+	return s.ExpiresAt != nil && !now.Before(*s.ExpiresAt)
+}
+
+// SharedChat is the public view of a shared chat, it does not reveal the owner or the chat.
+type SharedChat struct {
+	Title     string               `json:"title"`
+	Messages  []*ChatExportMessage `json:"messages"`
+	SharedAt  time.Time            `json:"shared_at"`
+	ExpiresAt *time.Time           `json:"expires_at"`
+	ViewCount int                  `json:"view_count"`
+}
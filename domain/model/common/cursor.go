@@ -0,0 +1,52 @@
+package common
+
+import (
+	"encoding/base64"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	DefaultCursorLimit = 20
+)
+
+type CursorPagination struct {
+	// opaque position returned by the previous page. empty for the first page.
+	Cursor string `json:"cursor" form:"cursor"`
+
+	// must be in 0-100 range. default: 20
+	Limit int `json:"limit" form:"limit" binding:"min=0,max=100"`
+
+	// for internal use only
+	NextCursor string `json:"-" swaggerignore:"true"`
+}
+
+// Cursor is a keyset pagination position, the sort value and the id of the last element of a page.
+type Cursor struct {
+	At time.Time
+	ID string
+}
+
+// Encode returns the opaque representation of the cursor handed to the clients.
+func (c *Cursor) Encode() string {
+	raw := c.At.UTC().Format(time.RFC3339Nano) + "|" + c.ID
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func DecodeCursor(encoded string) (*Cursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, errors.Wrap(err, "malformed cursor")
+	}
+	at, id, ok := strings.Cut(string(raw), "|")
+	if !ok || id == "" {
+		return nil, errors.New("malformed cursor")
+	}
+	t, err := time.Parse(time.RFC3339Nano, at)
+	if err != nil {
+		return nil, errors.Wrap(err, "malformed cursor")
+	}
+	return &Cursor{At: t, ID: id}, nil
+}
@@ -0,0 +1,68 @@
+package common
+
+import (
+	"database/sql/driver"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Vector is an embedding stored in a pgvector column.
+type Vector []float32
+
+func (v Vector) Value() (driver.Value, error) {
+	if v == nil {
+		return nil, nil
+	}
+	return v.String(), nil
+}
+
+func (v *Vector) Scan(src interface{}) error {
+	if src == nil {
+		*v = nil
+		return nil
+	}
+
+	var s string
+	switch t := src.(type) {
+	case []byte:
+		s = string(t)
+	case string:
+		s = t
+	default:
+		return errors.New("incompatible type for Vector: expected []byte or string")
+	}
+
+	s = strings.TrimSuffix(strings.TrimPrefix(strings.TrimSpace(s), "["), "]")
+	if s == "" {
+		*v = Vector{}
+		return nil
+	}
+
+	parts := strings.Split(s, ",")
+	vector := make(Vector, len(parts))
+	for i, part := range parts {
+		f, err := strconv.ParseFloat(strings.TrimSpace(part), 32)
+		if err != nil {
+			return errors.Wrapf(err, "failed to parse vector element %q", part)
+		}
+		vector[i] = float32(f)
+	}
+	*v = vector
+	return nil
+}
+
+// String formats the vector in the pgvector text representation, e.g. "[1,2,3]".
+func (v Vector) String() string {
+	var sb strings.Builder
+	sb.WriteByte('[')
+	for i, f := range v {
+		if i > 0 {
+			sb.WriteByte(',')
+		}
+		sb.WriteString(strconv.FormatFloat(float64(f), 'f', -1, 32))
+	}
+	sb.WriteByte(']')
+	return sb.String()
+}
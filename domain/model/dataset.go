@@ -0,0 +1,26 @@
+package model
+
+type DatasetFormat string
+
+const (
+	// DatasetFormatChat lists the positively rated replies in the chat fine-tuning format of OpenAI.
+	DatasetFormatChat DatasetFormat = "chat"
+	// DatasetFormatPreference pairs a preferred reply with a rejected regeneration of it.
+	DatasetFormatPreference DatasetFormat = "preference"
+)
+
+type DatasetSplit string
+
+const (
+	DatasetSplitTrain      DatasetSplit = "train"
+	DatasetSplitValidation DatasetSplit = "validation"
+)
+
+type DatasetOptions struct {
+	Format DatasetFormat
+	Split  DatasetSplit
+	// share of the chats, in 0-1 range, which belong to the validation split, nil uses the default
+	ValidationRatio *float64
+	// ScrubPII masks emails, phone numbers, card numbers and IP addresses in every message.
+	ScrubPII bool
+}
@@ -0,0 +1,31 @@
+package model
+
+import (
+	"time"
+
+	"github.com/amahdian/ai-assistant-be/domain/model/common"
+	"github.com/google/uuid"
+)
+
+// EmbeddingDimensions must match the dimensions of the message_embeddings vector column.
+const EmbeddingDimensions = 1536
+
+type MessageEmbedding struct {
+	MessageID uuid.UUID     `json:"message_id" gorm:"type:uuid;primaryKey"`
+	ChatID    string        `json:"chat_id"`
+	UserId    string        `json:"user_id"`
+	Embedding common.Vector `json:"-" gorm:"type:vector(1536)"`
+	CreatedAt time.Time     `json:"created_at"`
+}
+
+func (*MessageEmbedding) TableName() string {
+	return "message_embeddings"
+}
+
+// EmbeddingMatch is a message whose embedding is close to a query vector.
+type EmbeddingMatch struct {
+	MessageID string
+	ChatID    string
+	// Similarity is the cosine similarity to the query, the higher the closer.
+	Similarity float64
+}
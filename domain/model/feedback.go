@@ -0,0 +1,80 @@
+package model
+
+import (
+	"time"
+
+	"github.com/amahdian/ai-assistant-be/global"
+)
+
+type FeedbackRating int
+
+const (
+	FeedbackRatingUp   FeedbackRating = 1
+	FeedbackRatingDown FeedbackRating = -1
+)
+
+// FeedbackReasons are the categories users pick from when rating a reply.
+var FeedbackReasons = []string{"inaccurate", "unhelpful", "incomplete", "harmful", "too_long", "too_short", "formatting", "other"}
+
+// MessageFeedback is the rating of an assistant reply by the owner of the chat, a reply has one feedback at most.
+type MessageFeedback struct {
+	MessageID string         `json:"message_id" gorm:"primaryKey"`
+	ChatID    string         `json:"chat_id"`
+	UserId    string         `json:"user_id"`
+	Rating    FeedbackRating `json:"rating"`
+	Reasons   []string       `json:"reasons" gorm:"type:jsonb;serializer:json"`
+	Comment   string         `json:"comment"`
+	Agent     string         `json:"agent"`
+	Model     string         `json:"model"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+}
+
+func (*MessageFeedback) TableName() string {
+	return "message_feedback"
+}
+
+type FeedbackReportFilter struct {
+	Period global.PeriodType
+	Agent  string
+	Model  string
+	From   *time.Time
+	To     *time.Time
+}
+
+// FeedbackReportRow aggregates the feedback of an agent and model during a period.
+type FeedbackReportRow struct {
+	// start of the period
+	Period   time.Time `json:"period"`
+	Agent    string    `json:"agent"`
+	Model    string    `json:"model"`
+	Total    int       `json:"total"`
+	Positive int       `json:"positive"`
+	Negative int       `json:"negative"`
+	// share of positive ratings in 0-1 range
+	ApprovalRate float64                `json:"approval_rate"`
+	TopReasons   []*FeedbackReasonCount `json:"top_reasons"`
+	Examples     []*FeedbackExample     `json:"examples"`
+}
+
+type FeedbackReasonCount struct {
+	Period time.Time `json:"-"`
+	Agent  string    `json:"-"`
+	Model  string    `json:"-"`
+	Reason string    `json:"reason"`
+	Count  int       `json:"count"`
+}
+
+// FeedbackExample is a negatively rated reply of a report row.
+type FeedbackExample struct {
+	Period    time.Time `json:"-"`
+	Agent     string    `json:"-"`
+	Model     string    `json:"-"`
+	MessageID string    `json:"message_id"`
+	ChatID    string    `json:"chat_id"`
+	Reasons   []string  `json:"reasons" gorm:"serializer:json"`
+	Comment   string    `json:"comment"`
+	Content   string    `json:"content"`
+	// when the reply was last rated
+	UpdatedAt time.Time `json:"updated_at"`
+}
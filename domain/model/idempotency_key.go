@@ -0,0 +1,37 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+)
+
+type IdempotencyState string
+
+const (
+	IdempotencyStatePending   IdempotencyState = "pending"
+	IdempotencyStateCompleted IdempotencyState = "completed"
+)
+
+// IdempotencyKey records a request sent with an Idempotency-Key header, so its retries replay
+// the original result instead of executing the request again.
+type IdempotencyKey struct {
+	UserId      string `gorm:"primaryKey"`
+	Key         string `gorm:"primaryKey"`
+	Fingerprint string
+	State       IdempotencyState
+	ChatId      *string
+	// MessageId is the assistant message answering the request, it is known once the request completed.
+	MessageId *string
+	// Response is the JSON result of the request, streamed requests have none since they are replayed from the message.
+	Response  json.RawMessage `gorm:"type:jsonb;serializer:json"`
+	CreatedAt time.Time
+	ExpiresAt time.Time
+}
+
+func (*IdempotencyKey) TableName() string {
+	return "idempotency_keys"
+}
+
+func (k *IdempotencyKey) Completed() bool {
+	return k.State == IdempotencyStateCompleted
+}
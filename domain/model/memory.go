@@ -0,0 +1,28 @@
+package model
+
+import (
+	"time"
+
+	"github.com/amahdian/ai-assistant-be/domain/model/common"
+	"github.com/google/uuid"
+)
+
+// Memory is a durable fact about a user, e.g. a preference, learned in one chat and remembered in all of them.
+type Memory struct {
+	ID        uuid.UUID     `json:"id" gorm:"type:uuid;default:uuid_generate_v4()"`
+	UserId    string        `json:"-"`
+	Content   string        `json:"content"`
+	Embedding common.Vector `json:"-" gorm:"type:vector(1536)"`
+	// the chat and the message the memory was last learned from, nil once they are deleted
+	SourceChatId    *string   `json:"source_chat_id"`
+	SourceMessageId *string   `json:"source_message_id"`
+	CreatedAt       time.Time `json:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at"`
+
+	// Similarity is only set by searches, it is the cosine similarity to the query
+	Similarity float64 `json:"-" gorm:"->"`
+}
+
+func (*Memory) TableName() string {
+	return "memories"
+}
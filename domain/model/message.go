@@ -1,17 +1,71 @@
 package model
 
 import (
+	"encoding/json"
 	"github.com/amahdian/ai-assistant-be/domain/model/common"
 	"time"
 
 	"github.com/google/uuid"
 )
 
+type MessageStatus string
+
+const (
+	MessageStatusCompleted MessageStatus = "completed"
+	MessageStatusStopped   MessageStatus = "stopped"
+	MessageStatusFailed    MessageStatus = "failed"
+)
+
+const (
+	// MetadataRegeneratedFrom holds the id of the reply a regenerated message replaced.
+	MetadataRegeneratedFrom = "regenerated_from"
+	// MetadataSupersededBy holds the id of the regenerated message that replaced this reply.
+	MetadataSupersededBy = "superseded_by"
+	// MetadataCitations holds the JSON encoded citations of a reply, see EncodeCitations.
+	MetadataCitations = "citations"
+	// MetadataModel holds the name of the model which generated the reply.
+	MetadataModel = "model"
+	// MetadataSuggestions holds the JSON array of the follow-up questions suggested after the reply.
+	MetadataSuggestions = "suggestions"
+)
+
+// Citation is an excerpt of a project document that was given to the assistant for a reply.
+type Citation struct {
+	FileID     string `json:"file_id"`
+	FileName   string `json:"file_name"`
+	ChunkIndex int    `json:"chunk_index"`
+	Excerpt    string `json:"excerpt"`
+}
+
+// EncodeCitations encodes the citations into the metadata value of MetadataCitations.
+func EncodeCitations(citations []*Citation) string {
+	encoded, _ := json.Marshal(citations)
+	return string(encoded)
+}
+
+// Citations decodes the citations of the message, malformed citations are ignored.
+func (m *Message) Citations() []*Citation {
+	return DecodeCitations(m.Metadata[MetadataCitations])
+}
+
+// DecodeCitations decodes the metadata value of MetadataCitations, malformed citations are ignored.
+func DecodeCitations(encoded string) []*Citation {
+	if encoded == "" {
+		return nil
+	}
+	var citations []*Citation
+	if err := json.Unmarshal([]byte(encoded), &citations); err != nil {
+		return nil
+	}
+	return citations
+}
+
 type Message struct {
 	ID        uuid.UUID       `json:"id" gorm:"type:uuid;default:uuid_generate_v4()"`
 	ChatID    string          `json:"chat_id"`
 	Role      string          `json:"role"` // "user" or "assistant"
 	Content   string          `json:"content"`
+	Status    MessageStatus   `json:"status" gorm:"default:completed"`
 	CreatedAt time.Time       `json:"created_at"`
 	Metadata  common.Metadata `json:"metadata" gorm:"type:jsonb"`
 
@@ -22,7 +76,22 @@ func (*Message) TableName() string {
 	return "messages"
 }
 
+const (
+	// StreamEventMessage carries a chunk of the assistant reply.
+	StreamEventMessage = "message"
+	// StreamEventDone ends the reply of a generation, its metadata holds the final status.
+	// Only trailers such as StreamEventSuggestions can follow it.
+	StreamEventDone = "done"
+	// StreamEventChat is the first event of the stream of a new chat, its metadata holds the chat id and title.
+	StreamEventChat = "chat"
+	// StreamEventSuggestions follows the done event of a completed reply, its content is the JSON array of the follow-up questions.
+	StreamEventSuggestions = "suggestions"
+)
+
 type StreamedMessage struct {
+	// ID uniquely identifies the event across generations, clients resume streams from it.
+	ID       string            `json:"id,omitempty"`
+	Event    string            `json:"event,omitempty"`
 	Content  string            `json:"content"`
 	Metadata map[string]string `json:"metadata,omitempty"`
 }
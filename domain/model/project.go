@@ -0,0 +1,55 @@
+package model
+
+import (
+	"time"
+
+	"github.com/amahdian/ai-assistant-be/domain/model/common"
+	"github.com/google/uuid"
+)
+
+// Project groups chats sharing the same instructions, default agent and knowledge files.
+type Project struct {
+	ID           uuid.UUID `json:"id" gorm:"type:uuid;default:uuid_generate_v4()"`
+	UserId       string    `json:"user_id"`
+	Name         string    `json:"name"`
+	Instructions string    `json:"instructions"`
+	DefaultAgent string    `json:"default_agent"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+
+	Files []*ProjectFile `gorm:"-" json:"files,omitempty"`
+}
+
+func (*Project) TableName() string {
+	return "projects"
+}
+
+type ProjectFile struct {
+	ID          uuid.UUID `json:"id" gorm:"type:uuid;default:uuid_generate_v4()"`
+	ProjectID   string    `json:"project_id"`
+	Name        string    `json:"name"`
+	ContentType string    `json:"content_type"`
+	Size        int64     `json:"size"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+func (*ProjectFile) TableName() string {
+	return "project_files"
+}
+
+// ProjectFileChunk is a piece of a project file small enough to be embedded and added to a prompt.
+type ProjectFileChunk struct {
+	ID         uuid.UUID     `json:"id" gorm:"type:uuid;default:uuid_generate_v4()"`
+	FileID     string        `json:"file_id"`
+	ProjectID  string        `json:"project_id"`
+	ChunkIndex int           `json:"chunk_index"`
+	Content    string        `json:"content"`
+	Embedding  common.Vector `json:"-" gorm:"type:vector(1536)"`
+
+	// FileName is only set by searches
+	FileName string `json:"file_name" gorm:"->"`
+}
+
+func (*ProjectFileChunk) TableName() string {
+	return "project_file_chunks"
+}
@@ -0,0 +1,23 @@
+package model
+
+import "time"
+
+// ChatSearchResult is a chat matching a search query along with its best matching message.
+type ChatSearchResult struct {
+	ChatID    string    `json:"chat_id"`
+	ChatTitle string    `json:"chat_title"`
+	MessageID string    `json:"message_id"`
+	Role      string    `json:"role"`
+	Snippet   string    `json:"snippet"`
+	Rank      float64   `json:"rank"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type SearchMode string
+
+const (
+	// SearchModeSemantic ranks the results by the embedding similarity only.
+	SearchModeSemantic SearchMode = "semantic"
+	// SearchModeHybrid merges the full-text and the semantic rankings.
+	SearchModeHybrid SearchMode = "hybrid"
+)
@@ -0,0 +1,27 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type Tag struct {
+	ID        uuid.UUID `json:"id" gorm:"type:uuid;default:uuid_generate_v4()"`
+	UserId    string    `json:"user_id"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (*Tag) TableName() string {
+	return "tags"
+}
+
+type ChatTag struct {
+	ChatID string `json:"chat_id" gorm:"primaryKey"`
+	TagID  string `json:"tag_id" gorm:"primaryKey"`
+}
+
+func (*ChatTag) TableName() string {
+	return "chat_tags"
+}
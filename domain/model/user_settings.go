@@ -0,0 +1,53 @@
+package model
+
+import "time"
+
+// ResponseLength is the preferred length of the replies, the agent decides when empty.
+type ResponseLength string
+
+const (
+	ResponseLengthShort    ResponseLength = "short"
+	ResponseLengthBalanced ResponseLength = "balanced"
+	ResponseLengthDetailed ResponseLength = "detailed"
+)
+
+// ResponseStyle is the preferred tone of the replies, the agent decides when empty.
+type ResponseStyle string
+
+const (
+	ResponseStyleCasual    ResponseStyle = "casual"
+	ResponseStyleFormal    ResponseStyle = "formal"
+	ResponseStyleTechnical ResponseStyle = "technical"
+)
+
+// UserSettings are the custom instructions and preferences added to the prompts of the user's chats.
+// Every field is optional, users without settings get the ones of NewUserSettings.
+type UserSettings struct {
+	UserId string `json:"-" gorm:"primaryKey"`
+	// what the assistant should know about the user
+	AboutMe string `json:"about_me"`
+	// how the assistant should respond to the user
+	HowToRespond string `json:"how_to_respond"`
+	// language of the replies, the language of the user's messages when empty
+	Language string `json:"language"`
+	// agent of the new chats outside of projects and model of the replies, the defaults when empty
+	DefaultAgent   string         `json:"default_agent"`
+	DefaultModel   string         `json:"default_model"`
+	ResponseLength ResponseLength `json:"response_length"`
+	ResponseStyle  ResponseStyle  `json:"response_style"`
+	// whether memories are learned from the user's chats and added to their prompts
+	MemoryEnabled bool      `json:"memory_enabled"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// NewUserSettings returns the settings of a user who never saved any.
+func NewUserSettings(userId string) *UserSettings {
+	return &UserSettings{
+		UserId:        userId,
+		MemoryEnabled: true,
+	}
+}
+
+func (*UserSettings) TableName() string {
+	return "user_settings"
+}
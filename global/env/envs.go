@@ -7,6 +7,7 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/sethvargo/go-envconfig"
 
@@ -26,6 +27,15 @@ type Envs struct {
 		SwaggerHostAddr string `env:"SWAGGER_HOST_ADDR"`
 		AssetsDir       string `env:"ASSETS_DIR, required"`
 		JwtSecret       string `env:"JWT_SECRET, required"`
+		// AllowedOrigins are the cross-site origins allowed to open WebSocket connections,
+		// same-site connections are always allowed.
+		AllowedOrigins []string `env:"ALLOWED_ORIGINS"`
+		// comma separated emails of the users allowed to see the reports of the app
+		AdminEmails []string `env:"ADMIN_EMAILS"`
+		// how long the Idempotency-Key of a request is remembered
+		IdempotencyKeyTTL time.Duration `env:"IDEMPOTENCY_KEY_TTL, default=24h"`
+		// how long the deleted chats stay in the trash before they are permanently removed
+		DeletedChatRetention time.Duration `env:"DELETED_CHAT_RETENTION, default=720h"`
 	}
 
 	Db struct {
@@ -76,7 +86,7 @@ func Load(basePath string) (*Envs, error) {
 		log.Print("no .env file found")
 	}
 
-	log.Printf(fmt.Sprintf("trying to load %v env files", activeEnvFiles))
+	log.Printf("trying to load %v env files", activeEnvFiles)
 	err = godotenv.Load(activeEnvFiles...)
 	if err != nil {
 		return nil, err
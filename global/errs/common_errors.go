@@ -5,7 +5,7 @@ import (
 )
 
 const (
-	DefaultEntryNotFoundMessage = "%q entry by id %d could not be found"
+	DefaultEntryNotFoundMessage = "%q entry by id %q could not be found"
 	InvalidSearchFieldMessage   = "invalid field %q for search condition"
 	FailedToListItemsMessage    = "failed to list %q"
 )
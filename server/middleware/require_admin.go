@@ -0,0 +1,26 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/amahdian/ai-assistant-be/domain/contracts/resp"
+	"github.com/amahdian/ai-assistant-be/svc/auth"
+	"github.com/gin-gonic/gin"
+	"github.com/samber/lo"
+)
+
+// RequireAdmin only lets the users with one of the admin emails through.
+func RequireAdmin(adminEmails []string) gin.HandlerFunc {
+	admins := lo.SliceToMap(adminEmails, func(email string) (string, struct{}) {
+		return strings.ToLower(strings.TrimSpace(email)), struct{}{}
+	})
+	return func(c *gin.Context) {
+		userInfo := auth.UserInfoFromCtx(c.Request.Context())
+		if _, ok := admins[strings.ToLower(userInfo.Email)]; !ok || userInfo.Email == "" {
+			c.AbortWithStatusJSON(http.StatusForbidden, resp.NewErrorResponse("permission denied"))
+			return
+		}
+		c.Next()
+	}
+}
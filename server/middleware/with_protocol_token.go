@@ -0,0 +1,27 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+// WebSocketTokenProtocol is the WebSocket subprotocol that carries the JWT of the handshake.
+const WebSocketTokenProtocol = "access_token"
+
+// WithProtocolToken copies the JWT offered as a WebSocket subprotocol into the Authorization header when the header is missing.
+// Browsers cannot set headers on WebSocket handshakes, so they offer the token right after WebSocketTokenProtocol instead,
+// e.g. new WebSocket(url, ["access_token", jwt]), which unlike a query parameter does not end up in URLs and access logs.
+func WithProtocolToken() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetHeader("Authorization") == "" {
+			protocols := websocket.Subprotocols(c.Request)
+			for i := 0; i+1 < len(protocols); i++ {
+				if protocols[i] == WebSocketTokenProtocol {
+					c.Request.Header.Set("Authorization", "Bearer "+protocols[i+1])
+					break
+				}
+			}
+		}
+		c.Next()
+	}
+}
@@ -1,22 +1,20 @@
 package middleware
 
 import (
-	"context"
 	"fmt"
 	"net/http"
 
-	"github.com/amahdian/ai-assistant-be/server/utils"
 	"github.com/amahdian/ai-assistant-be/storage"
 	"github.com/amahdian/ai-assistant-be/svc/auth"
 	"github.com/gin-gonic/gin"
 )
 
+// WithUserSettings loads the settings of the authenticated user into the request context.
 func WithUserSettings(stg storage.Storage) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		ctx := c.Request.Context()
 		userInfo := auth.UserInfoFromCtx(ctx)
-		usStg := stg.User(ctx)
-		userSettings, err := usStg.FindByEmail(userInfo.Email)
+		userSettings, err := stg.UserSettings(ctx).FindByUserId(userInfo.ID.String())
 		if err != nil {
 			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
 				"message": fmt.Sprintf("could not fetch user settings: %v", err),
@@ -24,7 +22,7 @@ func WithUserSettings(stg storage.Storage) gin.HandlerFunc {
 			return
 		}
 
-		c.Request = c.Request.WithContext(context.WithValue(c.Request.Context(), utils.UserSettingsContextKey, userSettings))
+		c.Request = c.Request.WithContext(auth.WithUserSettings(ctx, userSettings))
 		c.Next()
 	}
 }
@@ -1,24 +1,46 @@
 package router
 
 import (
+	"errors"
 	"github.com/amahdian/ai-assistant-be/domain/contracts/req"
 	"github.com/amahdian/ai-assistant-be/domain/contracts/resp"
+	"github.com/amahdian/ai-assistant-be/domain/model"
+	"github.com/amahdian/ai-assistant-be/domain/model/common"
+	"github.com/amahdian/ai-assistant-be/global/errs"
+	"github.com/amahdian/ai-assistant-be/pkg/fileutil"
+	"github.com/amahdian/ai-assistant-be/svc"
+	"github.com/gin-contrib/sse"
 	"github.com/gin-gonic/gin"
 	"io"
+	"net/http"
 )
 
 func (r *Router) listChats(ctx *gin.Context) {
 	reqCtx := req.GetRequestContext(ctx)
 	user := reqCtx.UserInfo.User()
 
+	request := &req.ListChats{}
+	if err := ctx.ShouldBindQuery(request); err != nil {
+		resp.AbortWithError(ctx, err)
+		return
+	}
+
 	chatSvc := r.svc.NewChatSvc(ctx)
 
-	res, err := chatSvc.ListChats(&user)
+	filter := &model.ChatFilter{
+		Sort:      request.Sort,
+		FolderId:  request.FolderId,
+		ProjectId: request.ProjectId,
+		TagId:     request.TagId,
+		Pinned:    request.Pinned,
+		Archived:  request.Archived,
+	}
+	res, err := chatSvc.ListChats(filter, &request.CursorPagination, &user)
 	if err != nil {
 		resp.AbortWithError(ctx, err)
 		return
 	}
-	resp.Ok(ctx, res)
+	resp.CursorPaginatedOk(ctx, res, &request.CursorPagination)
 }
 
 func (r *Router) getChat(ctx *gin.Context) {
@@ -41,6 +63,75 @@ func (r *Router) getChat(ctx *gin.Context) {
 	resp.Ok(ctx, res)
 }
 
+func (r *Router) listMessages(ctx *gin.Context) {
+	reqCtx := req.GetRequestContext(ctx)
+	user := reqCtx.UserInfo.User()
+
+	var reqUri req.IdUri
+	if err := ctx.ShouldBindUri(&reqUri); err != nil {
+		resp.AbortWithError(ctx, err)
+		return
+	}
+
+	request := &req.ListMessages{}
+	if err := ctx.ShouldBindQuery(request); err != nil {
+		resp.AbortWithError(ctx, err)
+		return
+	}
+
+	pagination := &common.CursorPagination{
+		Cursor: request.Before,
+		Limit:  request.Limit,
+	}
+	dSvc := r.svc.NewChatSvc(reqCtx.Ctx)
+	res, err := dSvc.ListMessages(reqUri.Id, pagination, &user)
+	if err != nil {
+		resp.AbortWithError(ctx, err)
+		return
+	}
+
+	resp.CursorPaginatedOk(ctx, res, pagination)
+}
+
+var exportContentTypes = map[model.ChatExportFormat]string{
+	model.ChatExportFormatMarkdown: "text/markdown; charset=utf-8",
+	model.ChatExportFormatHtml:     "text/html; charset=utf-8",
+}
+
+func (r *Router) exportChat(ctx *gin.Context) {
+	reqCtx := req.GetRequestContext(ctx)
+	user := reqCtx.UserInfo.User()
+
+	var reqUri req.IdUri
+	if err := ctx.ShouldBindUri(&reqUri); err != nil {
+		resp.AbortWithError(ctx, err)
+		return
+	}
+
+	request := &req.ExportChat{}
+	if err := ctx.ShouldBindQuery(request); err != nil {
+		resp.AbortWithError(ctx, err)
+		return
+	}
+
+	if request.Format == "" {
+		request.Format = model.ChatExportFormatMarkdown
+	}
+
+	dSvc := r.svc.NewChatSvc(reqCtx.Ctx)
+	file, err := dSvc.ExportChat(reqUri.Id, request.Format, &user)
+	if err != nil {
+		resp.AbortWithError(ctx, err)
+		return
+	}
+
+	if request.Format == model.ChatExportFormatJson {
+		resp.WriteJsonFileBytes(ctx, file.Bytes, file.Filename)
+		return
+	}
+	resp.WriteFileBytes(ctx, file.Bytes, file.Filename, exportContentTypes[request.Format])
+}
+
 func (r *Router) deleteChat(ctx *gin.Context) {
 	reqCtx := req.GetRequestContext(ctx)
 	user := reqCtx.UserInfo.User()
@@ -61,11 +152,72 @@ func (r *Router) deleteChat(ctx *gin.Context) {
 	resp.Ok(ctx, true)
 }
 
+func (r *Router) deleteChats(ctx *gin.Context) {
+	reqCtx := req.GetRequestContext(ctx)
+	user := reqCtx.UserInfo.User()
+
+	request := &req.DeleteChats{}
+	if err := ctx.ShouldBindJSON(request); err != nil {
+		resp.AbortWithError(ctx, err)
+		return
+	}
+
+	dSvc := r.svc.NewChatSvc(reqCtx.Ctx)
+	if err := dSvc.DeleteChats(request.ChatIds, &user); err != nil {
+		resp.AbortWithError(ctx, err)
+		return
+	}
+
+	resp.Ok(ctx, true)
+}
+
+func (r *Router) listDeletedChats(ctx *gin.Context) {
+	reqCtx := req.GetRequestContext(ctx)
+	user := reqCtx.UserInfo.User()
+
+	request := &common.CursorPagination{}
+	if err := ctx.ShouldBindQuery(request); err != nil {
+		resp.AbortWithError(ctx, err)
+		return
+	}
+
+	dSvc := r.svc.NewChatSvc(reqCtx.Ctx)
+	res, err := dSvc.ListDeletedChats(request, &user)
+	if err != nil {
+		resp.AbortWithError(ctx, err)
+		return
+	}
+	resp.CursorPaginatedOk(ctx, res, request)
+}
+
+func (r *Router) restoreChat(ctx *gin.Context) {
+	reqCtx := req.GetRequestContext(ctx)
+	user := reqCtx.UserInfo.User()
+
+	var reqUri req.IdUri
+	if err := ctx.ShouldBindUri(&reqUri); err != nil {
+		resp.AbortWithError(ctx, err)
+		return
+	}
+
+	dSvc := r.svc.NewChatSvc(reqCtx.Ctx)
+	res, err := dSvc.RestoreChat(reqUri.Id, &user)
+	if err != nil {
+		resp.AbortWithError(ctx, err)
+		return
+	}
+
+	resp.Ok(ctx, res)
+}
+
+// createChat creates a chat with its first message and answers it, the answer is streamed
+// like in sendMessage when the stream query parameter is set. The stream starts with a chat event.
+// Like sendMessage it accepts an Idempotency-Key header.
 func (r *Router) createChat(ctx *gin.Context) {
 	reqCtx := req.GetRequestContext(ctx)
 	user := reqCtx.UserInfo.User()
 
-	request := &req.SendMessage{}
+	request := &req.CreateChat{}
 	err := ctx.BindJSON(&request)
 	if err != nil {
 		resp.AbortWithError(ctx, err)
@@ -73,7 +225,116 @@ func (r *Router) createChat(ctx *gin.Context) {
 	}
 
 	dSvc := r.svc.NewChatSvc(reqCtx.Ctx)
-	res, err := dSvc.CreateChat(request.Message, &user)
+
+	if ctx.DefaultQuery("stream", "false") == "true" {
+		chat, streamChan, err := dSvc.CreateChatStream(request.Message, request.ProjectId, ctx.GetHeader(req.IdempotencyKeyHeader), &user)
+		if err != nil {
+			resp.AbortWithError(ctx, err)
+			return
+		}
+
+		writeEventStream(ctx, streamChan, &model.StreamedMessage{
+			Event: model.StreamEventChat,
+			Metadata: map[string]string{
+				"chat_id": chat.ID.String(),
+				"title":   chat.Title,
+			},
+		})
+		return
+	}
+
+	res, err := dSvc.CreateChat(request.Message, request.ProjectId, ctx.GetHeader(req.IdempotencyKeyHeader), &user)
+	if err != nil {
+		resp.AbortWithError(ctx, err)
+		return
+	}
+
+	resp.Ok(ctx, res)
+}
+
+// importChats imports a ChatGPT conversations.json file or a chat export.
+// Conversations which could not be imported are reported in the messages of the response.
+func (r *Router) importChats(ctx *gin.Context) {
+	reqCtx := req.GetRequestContext(ctx)
+	user := reqCtx.UserInfo.User()
+
+	// larger uploads are cut off while they are read instead of being buffered in full,
+	// the headroom is for the multipart envelope and the size of the file is checked by the service.
+	ctx.Request.Body = http.MaxBytesReader(ctx.Writer, ctx.Request.Body, svc.MaxImportFileSize+1<<20)
+	header, err := ctx.FormFile("file")
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			err = errs.Newf(errs.InvalidArgument, err, "The file can not be larger than %d MB.", svc.MaxImportFileSize>>20)
+		}
+		resp.AbortWithError(ctx, err)
+		return
+	}
+	file, err := fileutil.NewFileFromFileHeader(header)
+	if err != nil {
+		resp.AbortWithError(ctx, err)
+		return
+	}
+
+	dSvc := r.svc.NewChatSvc(reqCtx.Ctx)
+	chats, messages, err := dSvc.ImportChats(file, &user)
+	if err != nil {
+		resp.AbortWithError(ctx, err)
+		return
+	}
+	if len(chats) == 0 {
+		resp.AbortWithStatus(ctx, http.StatusBadRequest, messages)
+		return
+	}
+
+	resp.CreatedWithMessage(ctx, chats, messages)
+}
+
+func (r *Router) forkChat(ctx *gin.Context) {
+	reqCtx := req.GetRequestContext(ctx)
+	user := reqCtx.UserInfo.User()
+
+	var reqUri req.IdUri
+	if err := ctx.ShouldBindUri(&reqUri); err != nil {
+		resp.AbortWithError(ctx, err)
+		return
+	}
+
+	// the body is optional
+	request := &req.ForkChat{}
+	if err := ctx.ShouldBindJSON(request); err != nil && !errors.Is(err, io.EOF) {
+		resp.AbortWithError(ctx, err)
+		return
+	}
+
+	dSvc := r.svc.NewChatSvc(reqCtx.Ctx)
+	res, err := dSvc.ForkChat(reqUri.Id, request.MessageId, &user)
+	if err != nil {
+		resp.AbortWithError(ctx, err)
+		return
+	}
+
+	resp.Created(ctx, res)
+}
+
+func (r *Router) renameChat(ctx *gin.Context) {
+	reqCtx := req.GetRequestContext(ctx)
+	user := reqCtx.UserInfo.User()
+
+	var reqUri req.IdUri
+	if err := ctx.ShouldBindUri(&reqUri); err != nil {
+		resp.AbortWithError(ctx, err)
+		return
+	}
+
+	request := &req.RenameChat{}
+	if err := ctx.ShouldBindJSON(request); err != nil {
+		resp.AbortWithError(ctx, err)
+		return
+	}
+
+	dSvc := r.svc.NewChatSvc(reqCtx.Ctx)
+	res, err := dSvc.RenameChat(reqUri.Id, request.Title, &user)
 	if err != nil {
 		resp.AbortWithError(ctx, err)
 		return
@@ -82,6 +343,28 @@ func (r *Router) createChat(ctx *gin.Context) {
 	resp.Ok(ctx, res)
 }
 
+// regenerateTitle returns right away, the new title is pushed to the clients once it is generated.
+func (r *Router) regenerateTitle(ctx *gin.Context) {
+	reqCtx := req.GetRequestContext(ctx)
+	user := reqCtx.UserInfo.User()
+
+	var reqUri req.IdUri
+	if err := ctx.ShouldBindUri(&reqUri); err != nil {
+		resp.AbortWithError(ctx, err)
+		return
+	}
+
+	dSvc := r.svc.NewChatSvc(reqCtx.Ctx)
+	if err := dSvc.RegenerateTitle(reqUri.Id, &user); err != nil {
+		resp.AbortWithError(ctx, err)
+		return
+	}
+
+	resp.Ok(ctx, true)
+}
+
+// sendMessage answers the message. Clients retrying after a timeout send the same Idempotency-Key header,
+// so the retry returns the original reply, or streams it from its generation, instead of sending the message again.
 func (r *Router) sendMessage(ctx *gin.Context) {
 	reqCtx := req.GetRequestContext(ctx)
 	user := reqCtx.UserInfo.User()
@@ -105,30 +388,16 @@ func (r *Router) sendMessage(ctx *gin.Context) {
 
 	if useStream {
 		// --- Streaming Response ---
-		streamChan, err := chatSvc.SendMessageStream(reqUri.Id, request.Message, &user)
+		streamChan, err := chatSvc.SendMessageStream(reqUri.Id, request.Message, ctx.GetHeader(req.IdempotencyKeyHeader), &user)
 		if err != nil {
 			resp.AbortWithError(ctx, err)
 			return
 		}
 
-		ctx.Header("Content-Type", "text/event-stream")
-		ctx.Header("Cache-Control", "no-cache")
-		ctx.Header("Connection", "keep-alive")
-		ctx.Header("Access-Control-Allow-Origin", "*")
-
-		ctx.Stream(func(w io.Writer) bool {
-			if msg, ok := <-streamChan; ok {
-				ctx.SSEvent("message", gin.H{
-					"content":  msg.Content,
-					"metadata": msg.Metadata,
-				})
-				return true
-			}
-			return false
-		})
+		writeEventStream(ctx, streamChan)
 	} else {
 		// --- Non-streaming (standard JSON) Response ---
-		res, err := chatSvc.SendMessage(reqUri.Id, request.Message, &user)
+		res, err := chatSvc.SendMessage(reqUri.Id, request.Message, ctx.GetHeader(req.IdempotencyKeyHeader), &user)
 		if err != nil {
 			resp.AbortWithError(ctx, err)
 			return
@@ -136,3 +405,122 @@ func (r *Router) sendMessage(ctx *gin.Context) {
 		resp.Ok(ctx, res)
 	}
 }
+
+func (r *Router) stopGeneration(ctx *gin.Context) {
+	reqCtx := req.GetRequestContext(ctx)
+	user := reqCtx.UserInfo.User()
+
+	var reqUri req.IdUri
+	if err := ctx.ShouldBindUri(&reqUri); err != nil {
+		resp.AbortWithError(ctx, err)
+		return
+	}
+
+	// the body is optional
+	request := &req.StopGeneration{}
+	if err := ctx.ShouldBindJSON(request); err != nil && !errors.Is(err, io.EOF) {
+		resp.AbortWithError(ctx, err)
+		return
+	}
+
+	chatSvc := r.svc.NewChatSvc(reqCtx.Ctx)
+	if err := chatSvc.StopGeneration(reqUri.Id, request.MessageId, &user); err != nil {
+		resp.AbortWithError(ctx, err)
+		return
+	}
+
+	resp.Ok(ctx, true)
+}
+
+// streamChat attaches to the in-flight generation of a chat.
+// Reconnecting clients send the Last-Event-ID header to resume where they left off,
+// other clients (e.g. a second device) receive the generation from its beginning.
+func (r *Router) streamChat(ctx *gin.Context) {
+	reqCtx := req.GetRequestContext(ctx)
+	user := reqCtx.UserInfo.User()
+
+	var reqUri req.IdUri
+	if err := ctx.ShouldBindUri(&reqUri); err != nil {
+		resp.AbortWithError(ctx, err)
+		return
+	}
+
+	lastEventID := ctx.GetHeader("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = ctx.Query("last_event_id")
+	}
+
+	chatSvc := r.svc.NewChatSvc(reqCtx.Ctx)
+	streamChan, err := chatSvc.SubscribeStream(reqUri.Id, lastEventID, &user)
+	if err != nil {
+		resp.AbortWithError(ctx, err)
+		return
+	}
+
+	writeEventStream(ctx, streamChan)
+}
+
+// writeEventStream writes the leading events followed by the streamed ones as server-sent events.
+func writeEventStream(ctx *gin.Context, streamChan <-chan *model.StreamedMessage, leading ...*model.StreamedMessage) {
+	ctx.Header("Content-Type", "text/event-stream")
+	ctx.Header("Cache-Control", "no-cache")
+	ctx.Header("Connection", "keep-alive")
+	ctx.Header("Access-Control-Allow-Origin", "*")
+
+	for _, msg := range leading {
+		renderStreamEvent(ctx, msg)
+	}
+	if len(leading) > 0 {
+		ctx.Writer.Flush()
+	}
+	ctx.Stream(func(w io.Writer) bool {
+		if msg, ok := <-streamChan; ok {
+			renderStreamEvent(ctx, msg)
+			return true
+		}
+		return false
+	})
+}
+
+func renderStreamEvent(ctx *gin.Context, msg *model.StreamedMessage) {
+	ctx.Render(-1, sse.Event{
+		Id:    msg.ID,
+		Event: msg.Event,
+		Data: gin.H{
+			"content":  msg.Content,
+			"metadata": msg.Metadata,
+		},
+	})
+}
+
+func (r *Router) pinChat(ctx *gin.Context) {
+	r.setChatFlag(ctx, func(dSvc svc.ChatSvc, chatID string, user *model.User) error {
+		return dSvc.SetPinned(chatID, ctx.Request.Method != http.MethodDelete, user)
+	})
+}
+
+func (r *Router) archiveChat(ctx *gin.Context) {
+	r.setChatFlag(ctx, func(dSvc svc.ChatSvc, chatID string, user *model.User) error {
+		return dSvc.SetArchived(chatID, ctx.Request.Method != http.MethodDelete, user)
+	})
+}
+
+// setChatFlag handles the POST (set) and DELETE (unset) routes of a chat flag.
+func (r *Router) setChatFlag(ctx *gin.Context, set func(dSvc svc.ChatSvc, chatID string, user *model.User) error) {
+	reqCtx := req.GetRequestContext(ctx)
+	user := reqCtx.UserInfo.User()
+
+	var reqUri req.IdUri
+	if err := ctx.ShouldBindUri(&reqUri); err != nil {
+		resp.AbortWithError(ctx, err)
+		return
+	}
+
+	dSvc := r.svc.NewChatSvc(reqCtx.Ctx)
+	if err := set(dSvc, reqUri.Id, &user); err != nil {
+		resp.AbortWithError(ctx, err)
+		return
+	}
+
+	resp.Ok(ctx, true)
+}
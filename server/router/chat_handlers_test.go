@@ -0,0 +1,81 @@
+package router
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/amahdian/ai-assistant-be/domain/model"
+	"github.com/amahdian/ai-assistant-be/svc"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+)
+
+type streamSvc struct {
+	svc.Svc
+	released chan struct{}
+}
+
+func (s *streamSvc) NewChatSvc(ctx context.Context) svc.ChatSvc {
+	return &streamChatSvc{ctx: ctx, released: s.released}
+}
+
+// streamChatSvc streams an endless generation and, like the generation broker,
+// releases the subscription once the context of the service is done.
+type streamChatSvc struct {
+	svc.ChatSvc
+	ctx      context.Context
+	released chan struct{}
+}
+
+func (s *streamChatSvc) SubscribeStream(_, _ string, _ *model.User) (<-chan *model.StreamedMessage, error) {
+	out := make(chan *model.StreamedMessage)
+	go func() {
+		defer close(s.released)
+		defer close(out)
+		for i := 1; ; i++ {
+			select {
+			case out <- &model.StreamedMessage{ID: fmt.Sprint(i), Event: model.StreamEventMessage, Content: "x"}:
+			case <-s.ctx.Done():
+				return
+			}
+		}
+	}()
+	return out, nil
+}
+
+func TestStreamChat_AbruptDisconnect(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	released := make(chan struct{})
+	r := &Router{Engine: gin.New(), svc: &streamSvc{released: released}}
+
+	handlerDone := make(chan struct{})
+	r.GET("/chats/:id/stream", func(ctx *gin.Context) {
+		defer close(handlerDone)
+		r.streamChat(ctx)
+	})
+	server := httptest.NewServer(r)
+	defer server.Close()
+
+	res, err := http.Get(server.URL + "/chats/chat/stream")
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, res.StatusCode)
+
+	// read the first event and drop the connection in the middle of the stream
+	line, err := bufio.NewReader(res.Body).ReadString('\n')
+	require.NoError(t, err)
+	require.Equal(t, "id:1\n", line)
+	require.NoError(t, res.Body.Close())
+
+	for name, done := range map[string]chan struct{}{"handler": handlerDone, "subscription": released} {
+		select {
+		case <-done:
+		case <-time.After(2 * time.Second):
+			t.Fatalf("the %s outlived the disconnected client", name)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package router
+
+import (
+	"fmt"
+
+	"github.com/amahdian/ai-assistant-be/domain/contracts/req"
+	"github.com/amahdian/ai-assistant-be/domain/contracts/resp"
+	"github.com/amahdian/ai-assistant-be/domain/model"
+	"github.com/gin-gonic/gin"
+	"github.com/samber/lo"
+)
+
+func (r *Router) setFeedback(ctx *gin.Context) {
+	reqCtx := req.GetRequestContext(ctx)
+	user := reqCtx.UserInfo.User()
+
+	var reqUri req.MessageUri
+	if err := ctx.ShouldBindUri(&reqUri); err != nil {
+		resp.AbortWithError(ctx, err)
+		return
+	}
+
+	request := &req.SetFeedback{}
+	if err := ctx.ShouldBindJSON(request); err != nil {
+		resp.AbortWithError(ctx, err)
+		return
+	}
+
+	feedback := &model.MessageFeedback{
+		Rating:  request.Rating,
+		Reasons: request.Reasons,
+		Comment: request.Comment,
+	}
+	dSvc := r.svc.NewFeedbackSvc(reqCtx.Ctx)
+	res, err := dSvc.SetFeedback(reqUri.Id, reqUri.MsgId, feedback, &user)
+	if err != nil {
+		resp.AbortWithError(ctx, err)
+		return
+	}
+
+	resp.Ok(ctx, res)
+}
+
+func (r *Router) deleteFeedback(ctx *gin.Context) {
+	reqCtx := req.GetRequestContext(ctx)
+	user := reqCtx.UserInfo.User()
+
+	var reqUri req.MessageUri
+	if err := ctx.ShouldBindUri(&reqUri); err != nil {
+		resp.AbortWithError(ctx, err)
+		return
+	}
+
+	dSvc := r.svc.NewFeedbackSvc(reqCtx.Ctx)
+	if err := dSvc.DeleteFeedback(reqUri.Id, reqUri.MsgId, &user); err != nil {
+		resp.AbortWithError(ctx, err)
+		return
+	}
+
+	resp.Ok(ctx, true)
+}
+
+func (r *Router) feedbackReport(ctx *gin.Context) {
+	reqCtx := req.GetRequestContext(ctx)
+
+	request := &req.FeedbackReport{}
+	if err := ctx.ShouldBindQuery(request); err != nil {
+		resp.AbortWithError(ctx, err)
+		return
+	}
+
+	filter := &model.FeedbackReportFilter{
+		Period: request.Period,
+		Agent:  request.Agent,
+		Model:  request.Model,
+		From:   request.From,
+		To:     request.To,
+	}
+	dSvc := r.svc.NewFeedbackSvc(reqCtx.Ctx)
+	res, err := dSvc.Report(filter)
+	if err != nil {
+		resp.AbortWithError(ctx, err)
+		return
+	}
+
+	resp.Ok(ctx, res)
+}
+
+func (r *Router) feedbackDataset(ctx *gin.Context) {
+	reqCtx := req.GetRequestContext(ctx)
+
+	request := &req.FeedbackDataset{}
+	if err := ctx.ShouldBindQuery(request); err != nil {
+		resp.AbortWithError(ctx, err)
+		return
+	}
+
+	filter := &model.FeedbackReportFilter{
+		Agent: request.Agent,
+		Model: request.Model,
+		From:  request.From,
+		To:    request.To,
+	}
+	options := &model.DatasetOptions{
+		Format:          request.Format,
+		Split:           request.Split,
+		ValidationRatio: request.ValidationRatio,
+		ScrubPII:        lo.FromPtrOr(request.ScrubPII, true),
+	}
+	dSvc := r.svc.NewFeedbackSvc(reqCtx.Ctx)
+	data, err := dSvc.ExportDataset(filter, options)
+	if err != nil {
+		resp.AbortWithError(ctx, err)
+		return
+	}
+
+	resp.WriteFileBytes(ctx, data, fmt.Sprintf("%s-%s.jsonl", options.Format, options.Split), "application/jsonl")
+}
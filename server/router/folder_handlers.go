@@ -0,0 +1,105 @@
+package router
+
+import (
+	"github.com/amahdian/ai-assistant-be/domain/contracts/req"
+	"github.com/amahdian/ai-assistant-be/domain/contracts/resp"
+	"github.com/gin-gonic/gin"
+)
+
+func (r *Router) listFolders(ctx *gin.Context) {
+	reqCtx := req.GetRequestContext(ctx)
+	user := reqCtx.UserInfo.User()
+
+	dSvc := r.svc.NewFolderSvc(reqCtx.Ctx)
+	res, err := dSvc.ListFolders(&user)
+	if err != nil {
+		resp.AbortWithError(ctx, err)
+		return
+	}
+
+	resp.Ok(ctx, res)
+}
+
+func (r *Router) createFolder(ctx *gin.Context) {
+	reqCtx := req.GetRequestContext(ctx)
+	user := reqCtx.UserInfo.User()
+
+	request := &req.SaveFolder{}
+	if err := ctx.ShouldBindJSON(request); err != nil {
+		resp.AbortWithError(ctx, err)
+		return
+	}
+
+	dSvc := r.svc.NewFolderSvc(reqCtx.Ctx)
+	res, err := dSvc.CreateFolder(request.Name, &user)
+	if err != nil {
+		resp.AbortWithError(ctx, err)
+		return
+	}
+
+	resp.Created(ctx, res)
+}
+
+func (r *Router) renameFolder(ctx *gin.Context) {
+	reqCtx := req.GetRequestContext(ctx)
+	user := reqCtx.UserInfo.User()
+
+	var reqUri req.IdUri
+	if err := ctx.ShouldBindUri(&reqUri); err != nil {
+		resp.AbortWithError(ctx, err)
+		return
+	}
+
+	request := &req.SaveFolder{}
+	if err := ctx.ShouldBindJSON(request); err != nil {
+		resp.AbortWithError(ctx, err)
+		return
+	}
+
+	dSvc := r.svc.NewFolderSvc(reqCtx.Ctx)
+	res, err := dSvc.RenameFolder(reqUri.Id, request.Name, &user)
+	if err != nil {
+		resp.AbortWithError(ctx, err)
+		return
+	}
+
+	resp.Ok(ctx, res)
+}
+
+func (r *Router) deleteFolder(ctx *gin.Context) {
+	reqCtx := req.GetRequestContext(ctx)
+	user := reqCtx.UserInfo.User()
+
+	var reqUri req.IdUri
+	if err := ctx.ShouldBindUri(&reqUri); err != nil {
+		resp.AbortWithError(ctx, err)
+		return
+	}
+
+	dSvc := r.svc.NewFolderSvc(reqCtx.Ctx)
+	if err := dSvc.DeleteFolder(reqUri.Id, &user); err != nil {
+		resp.AbortWithError(ctx, err)
+		return
+	}
+
+	resp.Ok(ctx, true)
+}
+
+func (r *Router) moveChats(ctx *gin.Context) {
+	reqCtx := req.GetRequestContext(ctx)
+	user := reqCtx.UserInfo.User()
+
+	request := &req.MoveChats{}
+	if err := ctx.ShouldBindJSON(request); err != nil {
+		resp.AbortWithError(ctx, err)
+		return
+	}
+
+	dSvc := r.svc.NewFolderSvc(reqCtx.Ctx)
+	if err := dSvc.MoveChats(request.ChatIds, request.FolderId, &user); err != nil {
+		resp.AbortWithError(ctx, err)
+		return
+	}
+
+	resp.Ok(ctx, true)
+}
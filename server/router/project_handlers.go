@@ -0,0 +1,166 @@
+package router
+
+import (
+	"github.com/amahdian/ai-assistant-be/domain/contracts/req"
+	"github.com/amahdian/ai-assistant-be/domain/contracts/resp"
+	"github.com/amahdian/ai-assistant-be/domain/model"
+	"github.com/amahdian/ai-assistant-be/pkg/fileutil"
+	"github.com/gin-gonic/gin"
+)
+
+func (r *Router) listProjects(ctx *gin.Context) {
+	reqCtx := req.GetRequestContext(ctx)
+	user := reqCtx.UserInfo.User()
+
+	dSvc := r.svc.NewProjectSvc(reqCtx.Ctx)
+	res, err := dSvc.ListProjects(&user)
+	if err != nil {
+		resp.AbortWithError(ctx, err)
+		return
+	}
+
+	resp.Ok(ctx, res)
+}
+
+func (r *Router) getProject(ctx *gin.Context) {
+	reqCtx := req.GetRequestContext(ctx)
+	user := reqCtx.UserInfo.User()
+
+	var reqUri req.IdUri
+	if err := ctx.ShouldBindUri(&reqUri); err != nil {
+		resp.AbortWithError(ctx, err)
+		return
+	}
+
+	dSvc := r.svc.NewProjectSvc(reqCtx.Ctx)
+	res, err := dSvc.GetProject(reqUri.Id, &user)
+	if err != nil {
+		resp.AbortWithError(ctx, err)
+		return
+	}
+
+	resp.Ok(ctx, res)
+}
+
+func (r *Router) createProject(ctx *gin.Context) {
+	reqCtx := req.GetRequestContext(ctx)
+	user := reqCtx.UserInfo.User()
+
+	request := &req.SaveProject{}
+	if err := ctx.ShouldBindJSON(request); err != nil {
+		resp.AbortWithError(ctx, err)
+		return
+	}
+
+	dSvc := r.svc.NewProjectSvc(reqCtx.Ctx)
+	res, err := dSvc.CreateProject(projectFromRequest(request), &user)
+	if err != nil {
+		resp.AbortWithError(ctx, err)
+		return
+	}
+
+	resp.Created(ctx, res)
+}
+
+func (r *Router) updateProject(ctx *gin.Context) {
+	reqCtx := req.GetRequestContext(ctx)
+	user := reqCtx.UserInfo.User()
+
+	var reqUri req.IdUri
+	if err := ctx.ShouldBindUri(&reqUri); err != nil {
+		resp.AbortWithError(ctx, err)
+		return
+	}
+
+	request := &req.SaveProject{}
+	if err := ctx.ShouldBindJSON(request); err != nil {
+		resp.AbortWithError(ctx, err)
+		return
+	}
+
+	dSvc := r.svc.NewProjectSvc(reqCtx.Ctx)
+	res, err := dSvc.UpdateProject(reqUri.Id, projectFromRequest(request), &user)
+	if err != nil {
+		resp.AbortWithError(ctx, err)
+		return
+	}
+
+	resp.Ok(ctx, res)
+}
+
+func (r *Router) deleteProject(ctx *gin.Context) {
+	reqCtx := req.GetRequestContext(ctx)
+	user := reqCtx.UserInfo.User()
+
+	var reqUri req.IdUri
+	if err := ctx.ShouldBindUri(&reqUri); err != nil {
+		resp.AbortWithError(ctx, err)
+		return
+	}
+
+	dSvc := r.svc.NewProjectSvc(reqCtx.Ctx)
+	if err := dSvc.DeleteProject(reqUri.Id, &user); err != nil {
+		resp.AbortWithError(ctx, err)
+		return
+	}
+
+	resp.Ok(ctx, true)
+}
+
+func (r *Router) addProjectFile(ctx *gin.Context) {
+	reqCtx := req.GetRequestContext(ctx)
+	user := reqCtx.UserInfo.User()
+
+	var reqUri req.IdUri
+	if err := ctx.ShouldBindUri(&reqUri); err != nil {
+		resp.AbortWithError(ctx, err)
+		return
+	}
+
+	header, err := ctx.FormFile("file")
+	if err != nil {
+		resp.AbortWithError(ctx, err)
+		return
+	}
+	file, err := fileutil.NewFileFromFileHeader(header)
+	if err != nil {
+		resp.AbortWithError(ctx, err)
+		return
+	}
+
+	dSvc := r.svc.NewProjectSvc(reqCtx.Ctx)
+	res, err := dSvc.AddFile(reqUri.Id, file, &user)
+	if err != nil {
+		resp.AbortWithError(ctx, err)
+		return
+	}
+
+	resp.Created(ctx, res)
+}
+
+func (r *Router) deleteProjectFile(ctx *gin.Context) {
+	reqCtx := req.GetRequestContext(ctx)
+	user := reqCtx.UserInfo.User()
+
+	var reqUri req.ProjectFileUri
+	if err := ctx.ShouldBindUri(&reqUri); err != nil {
+		resp.AbortWithError(ctx, err)
+		return
+	}
+
+	dSvc := r.svc.NewProjectSvc(reqCtx.Ctx)
+	if err := dSvc.DeleteFile(reqUri.Id, reqUri.FileId, &user); err != nil {
+		resp.AbortWithError(ctx, err)
+		return
+	}
+
+	resp.Ok(ctx, true)
+}
+
+func projectFromRequest(request *req.SaveProject) *model.Project {
+	return &model.Project{
+		Name:         request.Name,
+		Instructions: request.Instructions,
+		DefaultAgent: request.DefaultAgent,
+	}
+}
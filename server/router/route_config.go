@@ -21,18 +21,17 @@ func (rc *routeConfig) withUserSettings(flag bool) *routeConfig {
 	clone := rc.clone()
 	clone.RequireUserSettings = flag
 	return clone
-
 }
 
 func (rc *routeConfig) withMiddlewares(middlewares ...gin.HandlerFunc) *routeConfig {
 	clone := rc.clone()
-	clone.Middlewares = append(rc.Middlewares, middlewares...)
+	clone.Middlewares = append(clone.Middlewares, middlewares...)
 	return clone
 }
 
 func (rc *routeConfig) withCompression() *routeConfig {
 	clone := rc.clone()
-	clone.Middlewares = append(rc.Middlewares, gzip.Gzip(gzip.DefaultCompression))
+	clone.Middlewares = append(clone.Middlewares, gzip.Gzip(gzip.DefaultCompression))
 	return clone
 }
 
@@ -41,6 +40,7 @@ func (rc *routeConfig) clone() *routeConfig {
 	copy(middlewares, rc.Middlewares)
 
 	return &routeConfig{
-		Middlewares: middlewares,
+		RequireUserSettings: rc.RequireUserSettings,
+		Middlewares:         middlewares,
 	}
 }
@@ -18,6 +18,7 @@ import (
 	"github.com/gin-contrib/cors"
 	"github.com/gin-contrib/pprof"
 	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
 	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
 )
 
@@ -30,6 +31,7 @@ type Router struct {
 	configs *env.Envs
 
 	authenticator auth.Authenticator
+	wsUpgrader    *websocket.Upgrader
 
 	publicGroup *gin.RouterGroup
 	authGroup   *gin.RouterGroup
@@ -4,6 +4,7 @@ import (
 	"net/http"
 
 	"github.com/amahdian/ai-assistant-be/server/middleware"
+	"github.com/amahdian/ai-assistant-be/server/ws"
 	"github.com/gin-gonic/gin"
 )
 
@@ -17,6 +18,13 @@ func (r *Router) setupRoutes() {
 	r.registerPublicRoutes()
 	r.registerUserRoutes()
 	r.registerChatRoutes()
+	r.registerFolderRoutes()
+	r.registerTagRoutes()
+	r.registerProjectRoutes()
+	r.registerSearchRoutes()
+	r.registerShareRoutes()
+	r.registerFeedbackRoutes()
+	r.registerWebSocketRoutes()
 }
 
 func (r *Router) registerPublicRoutes() {
@@ -29,15 +37,102 @@ func (r *Router) registerUserRoutes() {
 	config := newRouteConfig()
 	r.registerRoute(r.publicGroup, http.MethodPost, "/user/login", r.login, config)
 	r.registerRoute(r.publicGroup, http.MethodPost, "/user/register", r.register, config)
+
+	settingsConfig := config.withUserSettings(true)
+	r.registerRoute(r.authGroup, http.MethodGet, "/user/settings", r.getUserSettings, settingsConfig)
+	r.registerRoute(r.authGroup, http.MethodPut, "/user/settings", r.updateUserSettings, config)
+	r.registerRoute(r.authGroup, http.MethodGet, "/user/memories", r.listMemories, config)
+	r.registerRoute(r.authGroup, http.MethodDelete, "/user/memories", r.deleteMemories, config)
+	r.registerRoute(r.authGroup, http.MethodDelete, "/user/memories/:id", r.deleteMemory, config)
 }
 
 func (r *Router) registerChatRoutes() {
 	config := newRouteConfig()
+	// the routes answering messages get the user settings for the prompts
+	settingsConfig := config.withUserSettings(true)
 	r.registerRoute(r.authGroup, http.MethodGet, "/chat", r.listChats, config)
+	r.registerRoute(r.authGroup, http.MethodGet, "/chat/trash", r.listDeletedChats, config)
 	r.registerRoute(r.authGroup, http.MethodGet, "/chat/:id", r.getChat, config)
+	r.registerRoute(r.authGroup, http.MethodGet, "/chat/:id/messages", r.listMessages, config)
+	r.registerRoute(r.authGroup, http.MethodGet, "/chat/:id/export", r.exportChat, config)
 	r.registerRoute(r.authGroup, http.MethodDelete, "/chat/:id", r.deleteChat, config)
-	r.registerRoute(r.authGroup, http.MethodPost, "/chat", r.createChat, config)
-	r.registerRoute(r.authGroup, http.MethodPost, "/chat/:id", r.sendMessage, config)
+	r.registerRoute(r.authGroup, http.MethodPost, "/chat/delete", r.deleteChats, config)
+	r.registerRoute(r.authGroup, http.MethodPost, "/chat/:id/restore", r.restoreChat, config)
+	r.registerRoute(r.authGroup, http.MethodPatch, "/chat/:id", r.renameChat, config)
+	r.registerRoute(r.authGroup, http.MethodPost, "/chat", r.createChat, settingsConfig)
+	r.registerRoute(r.authGroup, http.MethodPost, "/chat/:id", r.sendMessage, settingsConfig)
+	r.registerRoute(r.authGroup, http.MethodGet, "/chat/:id/stream", r.streamChat, config)
+	r.registerRoute(r.authGroup, http.MethodPost, "/chat/:id/stop", r.stopGeneration, config)
+	r.registerRoute(r.authGroup, http.MethodPost, "/chat/:id/title/regenerate", r.regenerateTitle, config)
+	r.registerRoute(r.authGroup, http.MethodPost, "/chat/:id/fork", r.forkChat, config)
+	r.registerRoute(r.authGroup, http.MethodPost, "/chat/:id/pin", r.pinChat, config)
+	r.registerRoute(r.authGroup, http.MethodDelete, "/chat/:id/pin", r.pinChat, config)
+	r.registerRoute(r.authGroup, http.MethodPost, "/chat/:id/archive", r.archiveChat, config)
+	r.registerRoute(r.authGroup, http.MethodDelete, "/chat/:id/archive", r.archiveChat, config)
+	r.registerRoute(r.authGroup, http.MethodPost, "/chat/move", r.moveChats, config)
+	r.registerRoute(r.authGroup, http.MethodPost, "/chat/tags", r.tagChats, config)
+	r.registerRoute(r.authGroup, http.MethodPost, "/chat/import", r.importChats, config)
+}
+
+func (r *Router) registerFolderRoutes() {
+	config := newRouteConfig()
+	r.registerRoute(r.authGroup, http.MethodGet, "/folder", r.listFolders, config)
+	r.registerRoute(r.authGroup, http.MethodPost, "/folder", r.createFolder, config)
+	r.registerRoute(r.authGroup, http.MethodPatch, "/folder/:id", r.renameFolder, config)
+	r.registerRoute(r.authGroup, http.MethodDelete, "/folder/:id", r.deleteFolder, config)
+}
+
+func (r *Router) registerTagRoutes() {
+	config := newRouteConfig()
+	r.registerRoute(r.authGroup, http.MethodGet, "/tag", r.listTags, config)
+	r.registerRoute(r.authGroup, http.MethodPost, "/tag", r.createTag, config)
+	r.registerRoute(r.authGroup, http.MethodDelete, "/tag/:id", r.deleteTag, config)
+}
+
+func (r *Router) registerProjectRoutes() {
+	config := newRouteConfig()
+	r.registerRoute(r.authGroup, http.MethodGet, "/project", r.listProjects, config)
+	r.registerRoute(r.authGroup, http.MethodPost, "/project", r.createProject, config)
+	r.registerRoute(r.authGroup, http.MethodGet, "/project/:id", r.getProject, config)
+	r.registerRoute(r.authGroup, http.MethodPut, "/project/:id", r.updateProject, config)
+	r.registerRoute(r.authGroup, http.MethodDelete, "/project/:id", r.deleteProject, config)
+	r.registerRoute(r.authGroup, http.MethodPost, "/project/:id/files", r.addProjectFile, config)
+	r.registerRoute(r.authGroup, http.MethodDelete, "/project/:id/files/:fileId", r.deleteProjectFile, config)
+}
+
+func (r *Router) registerSearchRoutes() {
+	config := newRouteConfig()
+	r.registerRoute(r.authGroup, http.MethodGet, "/search", r.searchMessages, config)
+	r.registerRoute(r.authGroup, http.MethodGet, "/search/semantic", r.semanticSearch, config)
+}
+
+func (r *Router) registerWebSocketRoutes() {
+	r.wsUpgrader = ws.NewUpgrader(r.configs.Server.AllowedOrigins)
+
+	config := newRouteConfig().withMiddlewares(
+		middleware.WithProtocolToken(),
+		middleware.VerifyAuth(r.authenticator),
+	)
+	r.registerRoute(r.publicGroup, http.MethodGet, "/ws", r.webSocket, config)
+}
+
+func (r *Router) registerShareRoutes() {
+	config := newRouteConfig()
+	r.registerRoute(r.authGroup, http.MethodPost, "/chat/:id/share", r.shareChat, config)
+	r.registerRoute(r.authGroup, http.MethodGet, "/chat/:id/shares", r.listShares, config)
+	r.registerRoute(r.authGroup, http.MethodDelete, "/chat/:id/share/:shareId", r.revokeShare, config)
+	r.registerRoute(r.publicGroup, http.MethodGet, "/share/:token", r.viewSharedChat, config)
+	r.registerRoute(r.authGroup, http.MethodPost, "/share/:token/continue", r.continueSharedChat, config)
+}
+
+func (r *Router) registerFeedbackRoutes() {
+	config := newRouteConfig()
+	r.registerRoute(r.authGroup, http.MethodPut, "/chat/:id/messages/:msgId/feedback", r.setFeedback, config)
+	r.registerRoute(r.authGroup, http.MethodDelete, "/chat/:id/messages/:msgId/feedback", r.deleteFeedback, config)
+
+	adminConfig := config.withMiddlewares(middleware.RequireAdmin(r.configs.Server.AdminEmails))
+	r.registerRoute(r.authGroup, http.MethodGet, "/feedback/report", r.feedbackReport, adminConfig)
+	r.registerRoute(r.authGroup, http.MethodGet, "/feedback/dataset", r.feedbackDataset, adminConfig)
 }
 
 func (r *Router) registerRoute(routerGroup *gin.RouterGroup, method, path string, handler gin.HandlerFunc, configs ...*routeConfig) {
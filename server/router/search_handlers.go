@@ -0,0 +1,47 @@
+package router
+
+import (
+	"github.com/amahdian/ai-assistant-be/domain/contracts/req"
+	"github.com/amahdian/ai-assistant-be/domain/contracts/resp"
+	"github.com/gin-gonic/gin"
+)
+
+func (r *Router) searchMessages(ctx *gin.Context) {
+	reqCtx := req.GetRequestContext(ctx)
+	user := reqCtx.UserInfo.User()
+
+	request := &req.SearchMessages{}
+	if err := ctx.ShouldBindQuery(request); err != nil {
+		resp.AbortWithError(ctx, err)
+		return
+	}
+
+	dSvc := r.svc.NewSearchSvc(reqCtx.Ctx)
+	res, err := dSvc.SearchMessages(request.Query, &request.Pagination, &user)
+	if err != nil {
+		resp.AbortWithError(ctx, err)
+		return
+	}
+
+	resp.PaginatedOk(ctx, res, &request.Pagination)
+}
+
+func (r *Router) semanticSearch(ctx *gin.Context) {
+	reqCtx := req.GetRequestContext(ctx)
+	user := reqCtx.UserInfo.User()
+
+	request := &req.SemanticSearch{}
+	if err := ctx.ShouldBindQuery(request); err != nil {
+		resp.AbortWithError(ctx, err)
+		return
+	}
+
+	dSvc := r.svc.NewSearchSvc(reqCtx.Ctx)
+	res, err := dSvc.SemanticSearch(request.Query, request.Mode, request.Limit, &user)
+	if err != nil {
+		resp.AbortWithError(ctx, err)
+		return
+	}
+
+	resp.Ok(ctx, res)
+}
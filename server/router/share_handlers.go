@@ -0,0 +1,117 @@
+package router
+
+import (
+	"errors"
+	"io"
+
+	"github.com/amahdian/ai-assistant-be/domain/contracts/req"
+	"github.com/amahdian/ai-assistant-be/domain/contracts/resp"
+	"github.com/gin-gonic/gin"
+)
+
+func (r *Router) shareChat(ctx *gin.Context) {
+	reqCtx := req.GetRequestContext(ctx)
+	user := reqCtx.UserInfo.User()
+
+	var reqUri req.IdUri
+	if err := ctx.ShouldBindUri(&reqUri); err != nil {
+		resp.AbortWithError(ctx, err)
+		return
+	}
+
+	// the body is optional
+	request := &req.ShareChat{}
+	if err := ctx.ShouldBindJSON(request); err != nil && !errors.Is(err, io.EOF) {
+		resp.AbortWithError(ctx, err)
+		return
+	}
+
+	dSvc := r.svc.NewShareSvc(reqCtx.Ctx)
+	res, err := dSvc.ShareChat(reqUri.Id, request.ExpiresAt, &user)
+	if err != nil {
+		resp.AbortWithError(ctx, err)
+		return
+	}
+
+	resp.Created(ctx, res)
+}
+
+func (r *Router) listShares(ctx *gin.Context) {
+	reqCtx := req.GetRequestContext(ctx)
+	user := reqCtx.UserInfo.User()
+
+	var reqUri req.IdUri
+	if err := ctx.ShouldBindUri(&reqUri); err != nil {
+		resp.AbortWithError(ctx, err)
+		return
+	}
+
+	dSvc := r.svc.NewShareSvc(reqCtx.Ctx)
+	res, err := dSvc.ListShares(reqUri.Id, &user)
+	if err != nil {
+		resp.AbortWithError(ctx, err)
+		return
+	}
+
+	resp.Ok(ctx, res)
+}
+
+func (r *Router) revokeShare(ctx *gin.Context) {
+	reqCtx := req.GetRequestContext(ctx)
+	user := reqCtx.UserInfo.User()
+
+	var reqUri req.ShareUri
+	if err := ctx.ShouldBindUri(&reqUri); err != nil {
+		resp.AbortWithError(ctx, err)
+		return
+	}
+
+	dSvc := r.svc.NewShareSvc(reqCtx.Ctx)
+	if err := dSvc.RevokeShare(reqUri.Id, reqUri.ShareId, &user); err != nil {
+		resp.AbortWithError(ctx, err)
+		return
+	}
+
+	resp.Ok(ctx, true)
+}
+
+// viewSharedChat is public, anyone with the link can read the snapshot.
+func (r *Router) viewSharedChat(ctx *gin.Context) {
+	reqCtx := req.GetRequestContext(ctx)
+
+	var reqUri req.ShareTokenUri
+	if err := ctx.ShouldBindUri(&reqUri); err != nil {
+		resp.AbortWithError(ctx, err)
+		return
+	}
+
+	dSvc := r.svc.NewShareSvc(reqCtx.Ctx)
+	res, err := dSvc.ViewSharedChat(reqUri.Token)
+	if err != nil {
+		resp.AbortWithError(ctx, err)
+		return
+	}
+
+	resp.Ok(ctx, res)
+}
+
+// continueSharedChat forks the snapshot of the link into a new chat of the viewer.
+func (r *Router) continueSharedChat(ctx *gin.Context) {
+	reqCtx := req.GetRequestContext(ctx)
+	user := reqCtx.UserInfo.User()
+
+	var reqUri req.ShareTokenUri
+	if err := ctx.ShouldBindUri(&reqUri); err != nil {
+		resp.AbortWithError(ctx, err)
+		return
+	}
+
+	dSvc := r.svc.NewShareSvc(reqCtx.Ctx)
+	res, err := dSvc.ContinueSharedChat(reqUri.Token, &user)
+	if err != nil {
+		resp.AbortWithError(ctx, err)
+		return
+	}
+
+	resp.Created(ctx, res)
+}
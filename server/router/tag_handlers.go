@@ -0,0 +1,79 @@
+package router
+
+import (
+	"github.com/amahdian/ai-assistant-be/domain/contracts/req"
+	"github.com/amahdian/ai-assistant-be/domain/contracts/resp"
+	"github.com/gin-gonic/gin"
+)
+
+func (r *Router) listTags(ctx *gin.Context) {
+	reqCtx := req.GetRequestContext(ctx)
+	user := reqCtx.UserInfo.User()
+
+	dSvc := r.svc.NewTagSvc(reqCtx.Ctx)
+	res, err := dSvc.ListTags(&user)
+	if err != nil {
+		resp.AbortWithError(ctx, err)
+		return
+	}
+
+	resp.Ok(ctx, res)
+}
+
+func (r *Router) createTag(ctx *gin.Context) {
+	reqCtx := req.GetRequestContext(ctx)
+	user := reqCtx.UserInfo.User()
+
+	request := &req.SaveTag{}
+	if err := ctx.ShouldBindJSON(request); err != nil {
+		resp.AbortWithError(ctx, err)
+		return
+	}
+
+	dSvc := r.svc.NewTagSvc(reqCtx.Ctx)
+	res, err := dSvc.CreateTag(request.Name, &user)
+	if err != nil {
+		resp.AbortWithError(ctx, err)
+		return
+	}
+
+	resp.Created(ctx, res)
+}
+
+func (r *Router) deleteTag(ctx *gin.Context) {
+	reqCtx := req.GetRequestContext(ctx)
+	user := reqCtx.UserInfo.User()
+
+	var reqUri req.IdUri
+	if err := ctx.ShouldBindUri(&reqUri); err != nil {
+		resp.AbortWithError(ctx, err)
+		return
+	}
+
+	dSvc := r.svc.NewTagSvc(reqCtx.Ctx)
+	if err := dSvc.DeleteTag(reqUri.Id, &user); err != nil {
+		resp.AbortWithError(ctx, err)
+		return
+	}
+
+	resp.Ok(ctx, true)
+}
+
+func (r *Router) tagChats(ctx *gin.Context) {
+	reqCtx := req.GetRequestContext(ctx)
+	user := reqCtx.UserInfo.User()
+
+	request := &req.TagChats{}
+	if err := ctx.ShouldBindJSON(request); err != nil {
+		resp.AbortWithError(ctx, err)
+		return
+	}
+
+	dSvc := r.svc.NewTagSvc(reqCtx.Ctx)
+	if err := dSvc.TagChats(request.ChatIds, request.Add, request.Remove, &user); err != nil {
+		resp.AbortWithError(ctx, err)
+		return
+	}
+
+	resp.Ok(ctx, true)
+}
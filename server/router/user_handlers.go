@@ -3,6 +3,7 @@ package router
 import (
 	"github.com/amahdian/ai-assistant-be/domain/contracts/req"
 	"github.com/amahdian/ai-assistant-be/domain/contracts/resp"
+	"github.com/amahdian/ai-assistant-be/domain/model"
 	"github.com/gin-gonic/gin"
 )
 
@@ -71,3 +72,148 @@ func (r *Router) register(ctx *gin.Context) {
 
 	resp.Ok(ctx, res)
 }
+
+// getUserSettings returns the custom instructions and preferences of the user.
+//
+//	@Summary	get the settings of the user
+//	@Description
+//	@Tags		User
+//	@Produce	json
+//	@Success	200	{object}	resp.Response[model.UserSettings]
+//	@Failure	500	{object}	resp.ErrorResponse
+//	@Security	Bearer
+//	@Router		/api/v1/user/settings [get]
+func (r *Router) getUserSettings(ctx *gin.Context) {
+	reqCtx := req.GetRequestContext(ctx)
+	user := reqCtx.UserInfo.User()
+
+	dSvc := r.svc.NewUserSvc(reqCtx.Ctx)
+	res, err := dSvc.GetSettings(&user)
+	if err != nil {
+		resp.AbortWithError(ctx, err)
+		return
+	}
+
+	resp.Ok(ctx, res)
+}
+
+// updateUserSettings replaces the custom instructions and preferences of the user.
+//
+//	@Summary	update the settings of the user
+//	@Description
+//	@Tags		User
+//	@Accept		json
+//	@Produce	json
+//	@Param		request	body		req.SaveUserSettings	true	"all the settings of the user"
+//	@Success	200		{object}	resp.Response[model.UserSettings]
+//	@Failure	400		{object}	resp.ErrorResponse
+//	@Failure	500		{object}	resp.ErrorResponse
+//	@Security	Bearer
+//	@Router		/api/v1/user/settings [put]
+func (r *Router) updateUserSettings(ctx *gin.Context) {
+	reqCtx := req.GetRequestContext(ctx)
+	user := reqCtx.UserInfo.User()
+
+	request := &req.SaveUserSettings{}
+	if err := ctx.ShouldBindJSON(request); err != nil {
+		resp.AbortWithError(ctx, err)
+		return
+	}
+
+	settings := &model.UserSettings{
+		AboutMe:        request.AboutMe,
+		HowToRespond:   request.HowToRespond,
+		Language:       request.Language,
+		DefaultAgent:   request.DefaultAgent,
+		DefaultModel:   request.DefaultModel,
+		ResponseLength: request.ResponseLength,
+		ResponseStyle:  request.ResponseStyle,
+		MemoryEnabled:  request.MemoryEnabled == nil || *request.MemoryEnabled,
+	}
+	dSvc := r.svc.NewUserSvc(reqCtx.Ctx)
+	res, err := dSvc.UpdateSettings(settings, &user)
+	if err != nil {
+		resp.AbortWithError(ctx, err)
+		return
+	}
+
+	resp.Ok(ctx, res)
+}
+
+// listMemories lists what the assistant remembers about the user.
+//
+//	@Summary	list the memories of the user
+//	@Description
+//	@Tags		User
+//	@Produce	json
+//	@Success	200	{object}	resp.Response[[]model.Memory]
+//	@Failure	500	{object}	resp.ErrorResponse
+//	@Security	Bearer
+//	@Router		/api/v1/user/memories [get]
+func (r *Router) listMemories(ctx *gin.Context) {
+	reqCtx := req.GetRequestContext(ctx)
+	user := reqCtx.UserInfo.User()
+
+	dSvc := r.svc.NewUserSvc(reqCtx.Ctx)
+	res, err := dSvc.ListMemories(&user)
+	if err != nil {
+		resp.AbortWithError(ctx, err)
+		return
+	}
+
+	resp.Ok(ctx, res)
+}
+
+// deleteMemory makes the assistant forget one memory of the user.
+//
+//	@Summary	delete a memory of the user
+//	@Description
+//	@Tags		User
+//	@Produce	json
+//	@Param		id	path		string	true	"memory id"
+//	@Success	200	{object}	resp.Response[bool]
+//	@Failure	404	{object}	resp.ErrorResponse
+//	@Failure	500	{object}	resp.ErrorResponse
+//	@Security	Bearer
+//	@Router		/api/v1/user/memories/{id} [delete]
+func (r *Router) deleteMemory(ctx *gin.Context) {
+	reqCtx := req.GetRequestContext(ctx)
+	user := reqCtx.UserInfo.User()
+
+	var reqUri req.IdUri
+	if err := ctx.ShouldBindUri(&reqUri); err != nil {
+		resp.AbortWithError(ctx, err)
+		return
+	}
+
+	dSvc := r.svc.NewUserSvc(reqCtx.Ctx)
+	if err := dSvc.DeleteMemory(reqUri.Id, &user); err != nil {
+		resp.AbortWithError(ctx, err)
+		return
+	}
+
+	resp.Ok(ctx, true)
+}
+
+// deleteMemories makes the assistant forget everything it remembers about the user.
+//
+//	@Summary	delete all the memories of the user
+//	@Description
+//	@Tags		User
+//	@Produce	json
+//	@Success	200	{object}	resp.Response[bool]
+//	@Failure	500	{object}	resp.ErrorResponse
+//	@Security	Bearer
+//	@Router		/api/v1/user/memories [delete]
+func (r *Router) deleteMemories(ctx *gin.Context) {
+	reqCtx := req.GetRequestContext(ctx)
+	user := reqCtx.UserInfo.User()
+
+	dSvc := r.svc.NewUserSvc(reqCtx.Ctx)
+	if err := dSvc.DeleteMemories(&user); err != nil {
+		resp.AbortWithError(ctx, err)
+		return
+	}
+
+	resp.Ok(ctx, true)
+}
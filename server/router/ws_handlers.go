@@ -0,0 +1,24 @@
+package router
+
+import (
+	"github.com/amahdian/ai-assistant-be/domain/contracts/req"
+	"github.com/amahdian/ai-assistant-be/pkg/logger"
+	"github.com/amahdian/ai-assistant-be/server/ws"
+	"github.com/gin-gonic/gin"
+)
+
+// webSocket upgrades the connection and serves the chat protocol over it.
+// See the ws package for the protocol description.
+func (r *Router) webSocket(ctx *gin.Context) {
+	reqCtx := req.GetRequestContext(ctx)
+	user := reqCtx.UserInfo.User()
+
+	conn, err := r.wsUpgrader.Upgrade(ctx.Writer, ctx.Request, nil)
+	if err != nil {
+		// the upgrader has already replied with an http error
+		logger.Warnf("failed to upgrade to websocket: %v", err)
+		return
+	}
+
+	ws.NewConn(reqCtx.Ctx, conn, r.svc, user).Serve()
+}
@@ -1,7 +0,0 @@
-package utils
-
-type contextKey string
-
-const (
-	UserSettingsContextKey contextKey = "user_settings_key"
-)
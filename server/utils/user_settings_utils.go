@@ -4,16 +4,10 @@ import (
 	"context"
 
 	"github.com/amahdian/ai-assistant-be/domain/model"
-	"github.com/gin-gonic/gin"
+	"github.com/amahdian/ai-assistant-be/svc/auth"
 )
 
-func CurrentUserSettings(ctx context.Context) *model.User {
-	if ginCtx, ok := ctx.(*gin.Context); ok {
-		ctx = ginCtx.Request.Context()
-	}
-	us, ok := ctx.Value(UserSettingsContextKey).(*model.User)
-	if !ok {
-		return nil
-	}
-	return us
+// CurrentUserSettings returns the settings loaded by the WithUserSettings middleware, nil on the other routes.
+func CurrentUserSettings(ctx context.Context) *model.UserSettings {
+	return auth.UserSettingsFromCtx(ctx)
 }
@@ -0,0 +1,409 @@
+package ws
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/amahdian/ai-assistant-be/domain/model"
+	"github.com/amahdian/ai-assistant-be/global/errs"
+	"github.com/amahdian/ai-assistant-be/pkg/logger"
+	"github.com/amahdian/ai-assistant-be/pkg/msg"
+	"github.com/amahdian/ai-assistant-be/server/middleware"
+	"github.com/amahdian/ai-assistant-be/svc"
+	"github.com/gorilla/websocket"
+	"github.com/samber/lo"
+)
+
+const (
+	// time allowed to write a message to the peer
+	writeWait = 10 * time.Second
+	// time allowed to read the next pong message from the peer
+	pongWait = 60 * time.Second
+	// pings are sent with this period, it must be less than pongWait
+	pingPeriod     = (pongWait * 9) / 10
+	maxMessageSize = 64 * 1024
+	// outgoing messages a connection may lag behind before it's considered dead
+	sendBufferSize = 256
+
+	messagesPerSecond = 5
+	messagesBurst     = 20
+	// client messages of a chat that may wait for the previous ones to be handled
+	chatQueueSize = 16
+)
+
+// rateLimiters limits the messages of the users across all their connections.
+var rateLimiters = newUserRateLimiters(messagesPerSecond, messagesBurst)
+
+// NewUpgrader returns the upgrader of the HTTP connections.
+// CORS does not apply to WebSocket handshakes, so besides the same site only the allowed origins may connect,
+// otherwise any site could open connections on behalf of its visitors.
+func NewUpgrader(allowedOrigins []string) *websocket.Upgrader {
+	return &websocket.Upgrader{
+		ReadBufferSize:  1024,
+		WriteBufferSize: 1024,
+		// the token protocol is selected so browsers accept the handshake, the token itself is never echoed
+		Subprotocols: []string{middleware.WebSocketTokenProtocol},
+		CheckOrigin: func(r *http.Request) bool {
+			origin := r.Header.Get("Origin")
+			if origin == "" {
+				// not a browser
+				return true
+			}
+			u, err := url.Parse(origin)
+			if err != nil {
+				return false
+			}
+			if strings.EqualFold(u.Host, r.Host) {
+				return true
+			}
+			return lo.ContainsBy(allowedOrigins, func(allowed string) bool {
+				return strings.EqualFold(allowed, origin)
+			})
+		},
+	}
+}
+
+// Conn serves the chat protocol described in protocol.go over a single WebSocket connection.
+type Conn struct {
+	ws   *websocket.Conn
+	svc  svc.Svc
+	user model.User
+
+	ctx     context.Context
+	cancel  context.CancelFunc
+	send    chan *serverMessage
+	limiter *rateLimiter
+
+	mu sync.Mutex
+	// subscribed chat id -> assistant message id -> stops forwarding the generation
+	chats map[string]map[string]context.CancelFunc
+	// chat id -> client messages waiting to be handled
+	queues map[string]chan *clientMessage
+}
+
+func NewConn(ctx context.Context, ws *websocket.Conn, svc svc.Svc, user model.User) *Conn {
+	ctx, cancel := context.WithCancel(ctx)
+	return &Conn{
+		ws:     ws,
+		svc:    svc,
+		user:   user,
+		ctx:    ctx,
+		cancel: cancel,
+		send:   make(chan *serverMessage, sendBufferSize),
+		chats:  make(map[string]map[string]context.CancelFunc),
+		queues: make(map[string]chan *clientMessage),
+	}
+}
+
+// Serve blocks until the connection is closed by either side.
+func (c *Conn) Serve() {
+	defer c.cancel()
+
+	c.limiter = rateLimiters.acquire(c.user.ID)
+	defer rateLimiters.release(c.user.ID)
+
+	go c.writeLoop()
+	go c.watchChats()
+	c.readLoop()
+}
+
+func (c *Conn) readLoop() {
+	c.ws.SetReadLimit(maxMessageSize)
+	_ = c.ws.SetReadDeadline(time.Now().Add(pongWait))
+	c.ws.SetPongHandler(func(string) error {
+		return c.ws.SetReadDeadline(time.Now().Add(pongWait))
+	})
+
+	for {
+		var m clientMessage
+		if err := c.ws.ReadJSON(&m); err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseNormalClosure) {
+				logger.Debugf("websocket connection of user %s closed: %v", c.user.ID, err)
+			}
+			return
+		}
+		if !c.limiter.allow() {
+			c.push(&serverMessage{
+				Type:      serverMessageError,
+				RequestID: m.RequestID,
+				Error:     "Rate limit exceeded, slow down.",
+			})
+			continue
+		}
+		c.dispatch(&m)
+	}
+}
+
+// dispatch queues the message behind the other messages of its chat.
+// The messages of a chat are handled in order (e.g. a stop never overtakes its send),
+// while the chats do not wait for each other.
+func (c *Conn) dispatch(m *clientMessage) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	queue, ok := c.queues[m.ChatID]
+	if !ok {
+		queue = make(chan *clientMessage, chatQueueSize)
+		c.queues[m.ChatID] = queue
+		go c.process(m.ChatID, queue)
+	}
+	select {
+	case queue <- m:
+	default:
+		c.push(&serverMessage{
+			Type:      serverMessageError,
+			RequestID: m.RequestID,
+			ChatID:    m.ChatID,
+			Error:     "Too many pending requests in the chat, slow down.",
+		})
+	}
+}
+
+// process handles the queued messages of a chat and exits once the queue is drained.
+func (c *Conn) process(chatID string, queue chan *clientMessage) {
+	for {
+		select {
+		case m := <-queue:
+			if c.ctx.Err() != nil {
+				return
+			}
+			c.handle(m)
+		default:
+			c.mu.Lock()
+			// dispatch only queues while holding the lock, so nothing can be queued after this check
+			if len(queue) > 0 {
+				c.mu.Unlock()
+				continue
+			}
+			delete(c.queues, chatID)
+			c.mu.Unlock()
+			return
+		}
+	}
+}
+
+func (c *Conn) writeLoop() {
+	ticker := time.NewTicker(pingPeriod)
+	defer func() {
+		ticker.Stop()
+		c.cancel()
+		_ = c.ws.Close()
+	}()
+
+	for {
+		select {
+		case <-c.ctx.Done():
+			_ = c.ws.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(writeWait))
+			return
+		case m := <-c.send:
+			_ = c.ws.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := c.ws.WriteJSON(m); err != nil {
+				return
+			}
+		case <-ticker.C:
+			_ = c.ws.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := c.ws.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// push queues a message for the peer, a peer that does not keep up gets disconnected.
+func (c *Conn) push(m *serverMessage) {
+	select {
+	case c.send <- m:
+	case <-c.ctx.Done():
+	default:
+		logger.Warnf("closing the websocket connection of user %s since it does not keep up", c.user.ID)
+		c.cancel()
+	}
+}
+
+func (c *Conn) pushError(requestID string, err error) {
+	c.push(&serverMessage{
+		Type:      serverMessageError,
+		RequestID: requestID,
+		Error:     msg.MakePlainText(err.Error()),
+	})
+}
+
+func (c *Conn) handle(m *clientMessage) {
+	chatSvc := c.svc.NewChatSvc(c.ctx)
+	ack := &serverMessage{Type: serverMessageAck, RequestID: m.RequestID, ChatID: m.ChatID}
+
+	var err error
+	switch m.Type {
+	case clientMessageSend:
+		c.subscribe(m.ChatID)
+		ack.MessageID, err = chatSvc.SendMessageAsync(m.ChatID, m.Message, &c.user)
+	case clientMessageRegenerate:
+		c.subscribe(m.ChatID)
+		ack.MessageID, err = chatSvc.Regenerate(m.ChatID, &c.user)
+	case clientMessageStop:
+		err = chatSvc.StopGeneration(m.ChatID, m.MessageID, &c.user)
+	case clientMessageSubscribe:
+		c.subscribe(m.ChatID)
+		err = c.attach(m.ChatID, m.LastEventID)
+		if errs.Code(err) == errs.NotFound {
+			// nothing is being generated at the moment
+			err = nil
+		}
+		if err != nil {
+			c.unsubscribe(m.ChatID)
+		}
+	case clientMessageUnsubscribe:
+		c.unsubscribe(m.ChatID)
+	case clientMessageTyping:
+		err = chatSvc.NotifyTyping(m.ChatID, &c.user)
+	default:
+		err = errs.Newf(errs.InvalidArgument, nil, "Unknown message type %q.", m.Type)
+	}
+
+	if err != nil {
+		c.pushError(m.RequestID, err)
+		return
+	}
+	c.push(ack)
+}
+
+// watchChats pushes the events of the subscribed chats and attaches to the generations started in them.
+func (c *Conn) watchChats() {
+	events, unsubscribe := c.svc.NewChatSvc(c.ctx).WatchChats(&c.user)
+	defer unsubscribe()
+
+	for {
+		select {
+		case <-c.ctx.Done():
+			return
+		case e, ok := <-events:
+			if !ok {
+				return
+			}
+			if !c.isSubscribed(e.ChatID) {
+				continue
+			}
+			switch e.Type {
+			case model.ChatEventGeneration:
+				if c.isAttached(e.ChatID, e.MessageID) {
+					continue
+				}
+				if err := c.attach(e.ChatID, e.EventID); err != nil {
+					c.pushError("", err)
+				}
+			case model.ChatEventTitle:
+				c.push(&serverMessage{Type: serverMessageTitle, ChatID: e.ChatID, Title: e.Title})
+			case model.ChatEventTyping:
+				c.push(&serverMessage{Type: serverMessageTyping, ChatID: e.ChatID})
+			}
+		}
+	}
+}
+
+// attach starts forwarding the generation of the chat that resumes from lastEventID.
+func (c *Conn) attach(chatID, lastEventID string) error {
+	ctx, cancel := context.WithCancel(c.ctx)
+	stream, err := c.svc.NewChatSvc(ctx).SubscribeStream(chatID, lastEventID, &c.user)
+	if err != nil {
+		cancel()
+		return err
+	}
+	go c.forward(ctx, cancel, chatID, stream)
+	return nil
+}
+
+func (c *Conn) forward(ctx context.Context, cancel context.CancelFunc, chatID string, stream <-chan *model.StreamedMessage) {
+	defer cancel()
+
+	messageID := ""
+	for e := range stream {
+		if messageID == "" {
+			messageID = e.Metadata["message_id"]
+			// the same generation might be attached twice, e.g. by a subscribe request and a generation event
+			if !c.register(chatID, messageID, cancel) {
+				return
+			}
+			defer c.deregister(chatID, messageID)
+		}
+
+		m := &serverMessage{
+			ChatID:    chatID,
+			MessageID: messageID,
+			EventID:   e.ID,
+			Content:   e.Content,
+			Metadata:  e.Metadata,
+		}
+		switch e.Event {
+		case model.StreamEventMessage:
+			m.Type = serverMessageDelta
+		case model.StreamEventDone:
+			m.Type = serverMessageDone
+			m.Status = e.Metadata["status"]
+		default:
+			m.Type = serverMessageTool
+			m.Event = e.Event
+		}
+		c.push(m)
+	}
+}
+
+func (c *Conn) subscribe(chatID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.chats[chatID]; !ok {
+		c.chats[chatID] = make(map[string]context.CancelFunc)
+	}
+}
+
+func (c *Conn) unsubscribe(chatID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, cancel := range c.chats[chatID] {
+		cancel()
+	}
+	delete(c.chats, chatID)
+}
+
+func (c *Conn) isSubscribed(chatID string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	_, ok := c.chats[chatID]
+	return ok
+}
+
+func (c *Conn) isAttached(chatID, messageID string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	_, ok := c.chats[chatID][messageID]
+	return ok
+}
+
+func (c *Conn) register(chatID, messageID string, cancel context.CancelFunc) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	generations, ok := c.chats[chatID]
+	if !ok {
+		return false
+	}
+	if _, attached := generations[messageID]; attached {
+		return false
+	}
+	generations[messageID] = cancel
+	return true
+}
+
+func (c *Conn) deregister(chatID, messageID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.chats[chatID], messageID)
+}
@@ -0,0 +1,146 @@
+package ws
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/amahdian/ai-assistant-be/domain/model"
+	"github.com/amahdian/ai-assistant-be/server/middleware"
+	"github.com/amahdian/ai-assistant-be/svc"
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeSvc struct {
+	svc.Svc
+	chats *fakeChatSvc
+}
+
+func (s *fakeSvc) NewChatSvc(context.Context) svc.ChatSvc {
+	return s.chats
+}
+
+// fakeChatSvc records the calls of the connections.
+type fakeChatSvc struct {
+	svc.ChatSvc
+
+	mu    sync.Mutex
+	calls []string
+}
+
+func (s *fakeChatSvc) record(call string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.calls = append(s.calls, call)
+}
+
+func (s *fakeChatSvc) SendMessageAsync(chatID, _ string, _ *model.User) (string, error) {
+	// a slow send, a stop handled concurrently would overtake it
+	time.Sleep(50 * time.Millisecond)
+	s.record("send " + chatID)
+	return "msg", nil
+}
+
+func (s *fakeChatSvc) StopGeneration(chatID, _ string, _ *model.User) error {
+	s.record("stop " + chatID)
+	return nil
+}
+
+func (s *fakeChatSvc) NotifyTyping(string, *model.User) error {
+	return nil
+}
+
+func (s *fakeChatSvc) WatchChats(*model.User) (<-chan *model.ChatEvent, func()) {
+	return make(chan *model.ChatEvent), func() {}
+}
+
+func newTestServer(t *testing.T, chats *fakeChatSvc, user model.User) string {
+	upgrader := NewUpgrader([]string{"https://app.example.com"})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		NewConn(r.Context(), conn, &fakeSvc{chats: chats}, user).Serve()
+	}))
+	t.Cleanup(server.Close)
+	return "ws" + strings.TrimPrefix(server.URL, "http")
+}
+
+func dial(t *testing.T, url string) *websocket.Conn {
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+	return conn
+}
+
+func read(t *testing.T, conn *websocket.Conn) *serverMessage {
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(2*time.Second)))
+	var m serverMessage
+	require.NoError(t, conn.ReadJSON(&m))
+	return &m
+}
+
+func TestConn_HandlesTheMessagesOfAChatInOrder(t *testing.T) {
+	chats := &fakeChatSvc{}
+	conn := dial(t, newTestServer(t, chats, model.User{ID: uuid.New()}))
+
+	require.NoError(t, conn.WriteJSON(&clientMessage{Type: clientMessageSend, RequestID: "1", ChatID: "a", Message: "hi"}))
+	require.NoError(t, conn.WriteJSON(&clientMessage{Type: clientMessageStop, RequestID: "2", ChatID: "a"}))
+	require.NoError(t, conn.WriteJSON(&clientMessage{Type: clientMessageStop, RequestID: "3", ChatID: "b"}))
+	require.NoError(t, conn.WriteJSON(&clientMessage{Type: "unknown", RequestID: "4", ChatID: "c"}))
+
+	acks := make([]string, 0)
+	for i := 0; i < 4; i++ {
+		m := read(t, conn)
+		if m.RequestID == "4" {
+			require.Equal(t, serverMessageError, m.Type)
+			continue
+		}
+		require.Equal(t, serverMessageAck, m.Type)
+		acks = append(acks, m.RequestID)
+	}
+
+	// the stop waits for the send of its chat, but the other chats do not
+	require.Equal(t, []string{"3", "1", "2"}, acks)
+	require.Equal(t, []string{"stop b", "send a", "stop a"}, chats.calls)
+}
+
+func TestConn_RateLimitsTheUser(t *testing.T) {
+	url := newTestServer(t, &fakeChatSvc{}, model.User{ID: uuid.New()})
+	conns := []*websocket.Conn{dial(t, url), dial(t, url)}
+
+	// the connections of the user share the limit
+	for i := 0; i <= messagesBurst; i++ {
+		require.NoError(t, conns[i%2].WriteJSON(&clientMessage{Type: clientMessageTyping, ChatID: "a"}))
+	}
+
+	errors := 0
+	for i := 0; i <= messagesBurst; i++ {
+		if read(t, conns[i%2]).Type == serverMessageError {
+			errors++
+		}
+	}
+	require.Equal(t, 1, errors)
+}
+
+func TestUpgrader_ChecksTheOrigin(t *testing.T) {
+	url := newTestServer(t, &fakeChatSvc{}, model.User{ID: uuid.New()})
+
+	_, res, err := websocket.DefaultDialer.Dial(url, http.Header{"Origin": {"https://evil.example.com"}})
+	require.ErrorIs(t, err, websocket.ErrBadHandshake)
+	require.Equal(t, http.StatusForbidden, res.StatusCode)
+
+	dialer := websocket.Dialer{Subprotocols: []string{middleware.WebSocketTokenProtocol, "jwt"}}
+	conn, _, err := dialer.Dial(url, http.Header{"Origin": {"https://app.example.com"}})
+	require.NoError(t, err)
+	defer conn.Close()
+	// the token protocol is selected without echoing the token
+	require.Equal(t, middleware.WebSocketTokenProtocol, conn.Subprotocol())
+}
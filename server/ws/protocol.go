@@ -0,0 +1,69 @@
+package ws
+
+// The WebSocket protocol exchanges JSON text messages, every message has a "type".
+//
+// Client messages:
+//   - send:        {"type":"send","request_id":"1","chat_id":"...","message":"..."}
+//   - regenerate:  {"type":"regenerate","request_id":"2","chat_id":"..."}
+//   - stop:        {"type":"stop","request_id":"3","chat_id":"...","message_id":"..."} (message_id is optional)
+//   - subscribe:   {"type":"subscribe","request_id":"4","chat_id":"...","last_event_id":"..."} (last_event_id is optional)
+//   - unsubscribe: {"type":"unsubscribe","request_id":"5","chat_id":"..."}
+//   - typing:      {"type":"typing","chat_id":"..."}
+//
+// Server messages, multiplexed across all the subscribed chats:
+//   - ack:    the request was accepted, for send/regenerate it carries the id of the assistant message.
+//   - delta:  a chunk of an assistant reply.
+//   - done:   the generation of an assistant reply finished with the given status,
+//     failed generations also tell why in the error metadata. Only tool messages of the reply can follow it.
+//   - tool:   any other generation event (e.g. tool calls or follow-up suggestions), the event name is in "event".
+//   - title:  the title of a chat changed.
+//   - typing: the user is typing in a chat on another device.
+//   - error:  the request failed or the connection is misbehaving (e.g. rate limited).
+//
+// Sending or regenerating in a chat subscribes the connection to it.
+// The client messages of a chat are handled in the order they were sent, the chats are handled concurrently.
+// The client messages are rate limited per user, across all the connections of the user.
+//
+// Browsers authenticate the handshake by offering the JWT as a subprotocol, see middleware.WithProtocolToken.
+
+const (
+	clientMessageSend        = "send"
+	clientMessageRegenerate  = "regenerate"
+	clientMessageStop        = "stop"
+	clientMessageSubscribe   = "subscribe"
+	clientMessageUnsubscribe = "unsubscribe"
+	clientMessageTyping      = "typing"
+)
+
+const (
+	serverMessageAck    = "ack"
+	serverMessageDelta  = "delta"
+	serverMessageDone   = "done"
+	serverMessageTool   = "tool"
+	serverMessageTitle  = "title"
+	serverMessageTyping = "typing"
+	serverMessageError  = "error"
+)
+
+type clientMessage struct {
+	Type        string `json:"type"`
+	RequestID   string `json:"request_id"`
+	ChatID      string `json:"chat_id"`
+	MessageID   string `json:"message_id"`
+	Message     string `json:"message"`
+	LastEventID string `json:"last_event_id"`
+}
+
+type serverMessage struct {
+	Type      string            `json:"type"`
+	RequestID string            `json:"request_id,omitempty"`
+	ChatID    string            `json:"chat_id,omitempty"`
+	MessageID string            `json:"message_id,omitempty"`
+	EventID   string            `json:"event_id,omitempty"`
+	Event     string            `json:"event,omitempty"`
+	Content   string            `json:"content,omitempty"`
+	Status    string            `json:"status,omitempty"`
+	Title     string            `json:"title,omitempty"`
+	Metadata  map[string]string `json:"metadata,omitempty"`
+	Error     string            `json:"error,omitempty"`
+}
@@ -0,0 +1,106 @@
+package ws
+
+import (
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// rateLimiter is a token bucket limiting the messages a user may send.
+type rateLimiter struct {
+	mu     sync.Mutex
+	rate   float64 // tokens per second
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newRateLimiter(ratePerSecond float64, burst int) *rateLimiter {
+	return &rateLimiter{
+		rate:   ratePerSecond,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+	}
+}
+
+func (l *rateLimiter) allow() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	l.tokens += now.Sub(l.last).Seconds() * l.rate
+	if l.tokens > l.burst {
+		l.tokens = l.burst
+	}
+	l.last = now
+
+	if l.tokens < 1 {
+		return false
+	}
+	l.tokens--
+	return true
+}
+
+// idle reports whether the bucket has refilled, i.e. forgetting the limiter would not raise the limit.
+func (l *rateLimiter) idle() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.tokens+time.Since(l.last).Seconds()*l.rate >= l.burst
+}
+
+// userRateLimiters shares a rate limiter between all the connections of a user,
+// so opening more connections does not raise the limit.
+type userRateLimiters struct {
+	ratePerSecond float64
+	burst         int
+
+	mu       sync.Mutex
+	limiters map[uuid.UUID]*userRateLimiter
+}
+
+type userRateLimiter struct {
+	*rateLimiter
+	conns int
+}
+
+func newUserRateLimiters(ratePerSecond float64, burst int) *userRateLimiters {
+	return &userRateLimiters{
+		ratePerSecond: ratePerSecond,
+		burst:         burst,
+		limiters:      make(map[uuid.UUID]*userRateLimiter),
+	}
+}
+
+// acquire returns the rate limiter of the user, every call must be paired with release.
+func (u *userRateLimiters) acquire(userID uuid.UUID) *rateLimiter {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	// the limiters are kept after the last connection is gone until they refill,
+	// otherwise reconnecting would reset the limit
+	for id, l := range u.limiters {
+		if l.conns == 0 && l.idle() {
+			delete(u.limiters, id)
+		}
+	}
+
+	l, ok := u.limiters[userID]
+	if !ok {
+		l = &userRateLimiter{rateLimiter: newRateLimiter(u.ratePerSecond, u.burst)}
+		u.limiters[userID] = l
+	}
+	l.conns++
+	return l.rateLimiter
+}
+
+func (u *userRateLimiters) release(userID uuid.UUID) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	if l, ok := u.limiters[userID]; ok && l.conns > 0 {
+		l.conns--
+	}
+}
@@ -0,0 +1,43 @@
+package ws
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRateLimiter(t *testing.T) {
+	l := newRateLimiter(100, 2)
+	require.True(t, l.allow())
+	require.True(t, l.allow())
+	require.False(t, l.allow())
+	require.False(t, l.idle())
+
+	time.Sleep(20 * time.Millisecond)
+	require.True(t, l.allow())
+}
+
+func TestUserRateLimiters(t *testing.T) {
+	limiters := newUserRateLimiters(100, 1)
+	user := uuid.New()
+
+	// the connections of a user share the limit
+	first := limiters.acquire(user)
+	second := limiters.acquire(user)
+	require.True(t, first.allow())
+	require.False(t, second.allow())
+	require.True(t, limiters.acquire(uuid.New()).allow())
+
+	// reconnecting does not reset the limit
+	limiters.release(user)
+	limiters.release(user)
+	require.False(t, limiters.acquire(user).allow())
+	limiters.release(user)
+
+	// the limiters of the users that are gone are forgotten once they refill
+	time.Sleep(20 * time.Millisecond)
+	limiters.acquire(uuid.New())
+	require.NotContains(t, limiters.limiters, user)
+}
@@ -0,0 +1,15 @@
+package storage
+
+import (
+	"github.com/amahdian/ai-assistant-be/domain/model"
+)
+
+type ChatShareStorage interface {
+	CrudStorage[*model.ChatShare]
+
+	// FindByToken returns the link of the token unless its chat is in the trash.
+	FindByToken(token string) (*model.ChatShare, error)
+	// ListByChatId returns the links of the chat without their snapshots.
+	ListByChatId(chatId string) ([]*model.ChatShare, error)
+	IncrementViews(id string) error
+}
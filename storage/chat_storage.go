@@ -1,11 +1,46 @@
 package storage
 
 import (
+	"time"
+
 	"github.com/amahdian/ai-assistant-be/domain/model"
+	"github.com/amahdian/ai-assistant-be/domain/model/common"
 )
 
 type ChatStorage interface {
 	CrudStorage[*model.Chat]
 
-	ListByUserId(userId string) ([]*model.Chat, error)
+	// ListByUserId returns a page of the user's chats and sets the cursor of the next page when there is one.
+	ListByUserId(userId string, filter *model.ChatFilter, pagination *common.CursorPagination) ([]*model.Chat, error)
+	// SetFolder moves the chats to the folder, a nil folder moves them out of any folder.
+	SetFolder(chatIds []string, folderId *string) error
+	SetPinned(chatId string, pinned bool) error
+	SetArchived(chatId string, archived bool) error
+	SetTitle(chatId, title string, state model.ChatTitleState) error
+	// SetPlaceholderTitle replaces the title only while the chat still has the placeholder one,
+	// it reports whether the title was replaced.
+	SetPlaceholderTitle(chatId, title string) (bool, error)
+	// SetLastMessageAt overrides the activity of the chat, which is otherwise maintained on every message insert.
+	SetLastMessageAt(chatId string, at time.Time) error
+	// LockGeneration marks the chat as generating the message, unless it is generating another one
+	// which started after staleBefore. It reports whether the chat was locked.
+	LockGeneration(chatId, messageId string, staleBefore time.Time) (bool, error)
+	// UnlockGeneration marks the chat idle if it is still generating the message.
+	UnlockGeneration(chatId, messageId string) error
+	// RequestGenerationStop asks the instance generating the message to stop it, any message when messageId is empty.
+	// Locks taken before staleBefore are ignored. It reports whether the chat is generating the message.
+	RequestGenerationStop(chatId, messageId string, staleBefore time.Time) (bool, error)
+	// GenerationStopRequested reports whether the chat was asked to stop generating the message.
+	GenerationStopRequested(chatId, messageId string) (bool, error)
+
+	// ListDeletedByUserId returns a page of the user's chats in the trash, the most recently deleted first.
+	ListDeletedByUserId(userId string, pagination *common.CursorPagination) ([]*model.Chat, error)
+	// FindDeletedById returns the chat only if it is in the trash.
+	FindDeletedById(id string) (*model.Chat, error)
+	// Restore moves the chat out of the trash.
+	Restore(chatId string) error
+	// PurgeDeleted permanently removes the chats deleted before the given time along with their messages.
+	PurgeDeleted(before time.Time) (int64, error)
+	// Purge permanently removes the chat along with its messages without moving it to the trash.
+	Purge(chatId string) error
 }
@@ -0,0 +1,13 @@
+package storage
+
+import (
+	"github.com/amahdian/ai-assistant-be/domain/model"
+	"github.com/amahdian/ai-assistant-be/domain/model/common"
+)
+
+// EmbeddingStorage is the vector index of the message embeddings.
+type EmbeddingStorage interface {
+	UpsertMany(embeddings []*model.MessageEmbedding) error
+	// SearchByUserId returns the messages of the user closest to the vector by cosine similarity, the closest first.
+	SearchByUserId(userId string, vector common.Vector, limit int) ([]*model.EmbeddingMatch, error)
+}
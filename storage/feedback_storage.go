@@ -0,0 +1,22 @@
+package storage
+
+import (
+	"github.com/amahdian/ai-assistant-be/domain/model"
+)
+
+type FeedbackStorage interface {
+	CrudStorage[*model.MessageFeedback]
+
+	// Upsert creates the feedback of the message or replaces its previous one.
+	Upsert(feedback *model.MessageFeedback) error
+	DeleteByMessageId(messageId string) error
+	// ListByFilter returns the feedback matching the agent, model and dates of the filter, the least recently rated first.
+	ListByFilter(filter *model.FeedbackReportFilter) ([]*model.MessageFeedback, error)
+	// ReportRows counts the ratings per period, agent and model, the most recent periods first.
+	// The ratings count in the period they were last changed in.
+	ReportRows(filter *model.FeedbackReportFilter) ([]*model.FeedbackReportRow, error)
+	// ReportReasons counts the reasons of the negative ratings per period, agent and model.
+	ReportReasons(filter *model.FeedbackReportFilter) ([]*model.FeedbackReasonCount, error)
+	// ReportExamples returns the latest negatively rated replies of every period, agent and model.
+	ReportExamples(filter *model.FeedbackReportFilter, perRow int) ([]*model.FeedbackExample, error)
+}
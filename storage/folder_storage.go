@@ -0,0 +1,11 @@
+package storage
+
+import (
+	"github.com/amahdian/ai-assistant-be/domain/model"
+)
+
+type FolderStorage interface {
+	CrudStorage[*model.Folder]
+
+	ListByUserId(userId string) ([]*model.Folder, error)
+}
@@ -0,0 +1,18 @@
+package storage
+
+import (
+	"time"
+
+	"github.com/amahdian/ai-assistant-be/domain/model"
+)
+
+type IdempotencyKeyStorage interface {
+	// Claim creates the key unless the user already has it unexpired, it reports whether the key was created.
+	Claim(key *model.IdempotencyKey) (bool, error)
+	FindByKey(userId, key string) (*model.IdempotencyKey, error)
+	// Complete stores the result of the request of the key.
+	Complete(key *model.IdempotencyKey) error
+	DeleteByKey(userId, key string) error
+	// DeleteExpired deletes the keys which expired before the given time and returns their count.
+	DeleteExpired(before time.Time) (int64, error)
+}
@@ -0,0 +1,74 @@
+// Package memory provides in-process storage implementations for tests.
+package memory
+
+import (
+	"math"
+	"sort"
+	"sync"
+
+	"github.com/amahdian/ai-assistant-be/domain/model"
+	"github.com/amahdian/ai-assistant-be/domain/model/common"
+)
+
+// EmbeddingStg is a vector index that scans every embedding of the user, it is only meant for tests.
+type EmbeddingStg struct {
+	mu         sync.RWMutex
+	embeddings map[string]*model.MessageEmbedding
+}
+
+func NewEmbeddingStg() *EmbeddingStg {
+	return &EmbeddingStg{
+		embeddings: make(map[string]*model.MessageEmbedding),
+	}
+}
+
+func (stg *EmbeddingStg) UpsertMany(embeddings []*model.MessageEmbedding) error {
+	stg.mu.Lock()
+	defer stg.mu.Unlock()
+
+	for _, e := range embeddings {
+		stg.embeddings[e.MessageID.String()] = e
+	}
+	return nil
+}
+
+func (stg *EmbeddingStg) SearchByUserId(userId string, vector common.Vector, limit int) ([]*model.EmbeddingMatch, error) {
+	stg.mu.RLock()
+	defer stg.mu.RUnlock()
+
+	matches := make([]*model.EmbeddingMatch, 0)
+	for _, e := range stg.embeddings {
+		if e.UserId != userId {
+			continue
+		}
+		matches = append(matches, &model.EmbeddingMatch{
+			MessageID:  e.MessageID.String(),
+			ChatID:     e.ChatID,
+			Similarity: cosineSimilarity(vector, e.Embedding),
+		})
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		return matches[i].Similarity > matches[j].Similarity
+	})
+	if len(matches) > limit {
+		matches = matches[:limit]
+	}
+	return matches, nil
+}
+
+func cosineSimilarity(a, b common.Vector) float64 {
+	if len(a) != len(b) {
+		return 0
+	}
+	var dot, normA, normB float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
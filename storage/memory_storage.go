@@ -0,0 +1,18 @@
+package storage
+
+import (
+	"github.com/amahdian/ai-assistant-be/domain/model"
+	"github.com/amahdian/ai-assistant-be/domain/model/common"
+)
+
+type MemoryStorage interface {
+	CrudStorage[*model.Memory]
+
+	// ListByUserId returns the memories of the user, the most recently learned first.
+	ListByUserId(userId string) ([]*model.Memory, error)
+	// SearchByUserId returns the limit memories of the user closest to the vector, with their similarity.
+	SearchByUserId(userId string, vector common.Vector, limit int) ([]*model.Memory, error)
+	// DeleteByUserId deletes the memory if it belongs to the user.
+	DeleteByUserId(userId, id string) error
+	DeleteAllByUserId(userId string) error
+}
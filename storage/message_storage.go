@@ -2,10 +2,20 @@ package storage
 
 import (
 	"github.com/amahdian/ai-assistant-be/domain/model"
+	"github.com/amahdian/ai-assistant-be/domain/model/common"
 )
 
 type MessageStorage interface {
 	CrudStorage[*model.Message]
 
 	ListByChatId(chatId string) ([]*model.Message, error)
+	// ListPageByChatId returns the active messages of the chat older than the cursor, in chronological order.
+	// The cursor of the next, older page is set when there is one.
+	ListPageByChatId(chatId string, pagination *common.CursorPagination) ([]*model.Message, error)
+	// SearchByUserId runs a full-text search over the messages of the user's chats and returns the matching chats.
+	SearchByUserId(userId, query string, pagination *common.Pagination) ([]*model.ChatSearchResult, error)
+	// ListWithoutEmbedding returns the oldest messages that are not in the vector index yet.
+	ListWithoutEmbedding(limit int) ([]*model.Message, error)
+	// SetMetadata sets a single metadata key of the message, leaving the other keys untouched.
+	SetMetadata(id, key, value string) error
 }
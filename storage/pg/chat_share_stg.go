@@ -0,0 +1,55 @@
+package pg
+
+import (
+	"errors"
+
+	"github.com/amahdian/ai-assistant-be/domain/model"
+	"github.com/amahdian/ai-assistant-be/global/errs"
+	"gorm.io/gorm"
+)
+
+type ChatShareStg struct {
+	crudStg[*model.ChatShare]
+}
+
+func NewChatShareStg(ses *ormSession) *ChatShareStg {
+	return &ChatShareStg{
+		crudStg: crudStg[*model.ChatShare]{db: ses.db},
+	}
+}
+
+func (stg *ChatShareStg) FindByToken(token string) (*model.ChatShare, error) {
+	share := &model.ChatShare{}
+	// the links of the chats in the trash are not served, they work again once the chat is restored
+	err := stg.db.
+		Table(withAlias(&model.ChatShare{}, "s")).
+		Select("s.*").
+		Joins("JOIN chats c ON c.id = s.chat_id AND c.deleted_at IS NULL").
+		Where("s.token = ?", token).
+		First(share).
+		Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, errs.Newf(errs.NotFound, nil, "The shared chat could not be found.")
+	}
+	return share, err
+}
+
+func (stg *ChatShareStg) ListByChatId(chatId string) ([]*model.ChatShare, error) {
+	var shares []*model.ChatShare
+	err := stg.db.
+		Omit("snapshot").
+		Where("chat_id = ?", chatId).
+		Order("created_at DESC").
+		Find(&shares).
+		Error
+
+	return shares, err
+}
+
+func (stg *ChatShareStg) IncrementViews(id string) error {
+	return stg.db.
+		Model(&model.ChatShare{}).
+		Where("id = ?", id).
+		UpdateColumn("view_count", gorm.Expr("view_count + 1")).
+		Error
+}
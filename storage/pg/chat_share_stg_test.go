@@ -0,0 +1,30 @@
+package pg
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// dryRunDb builds the statements without a database and reports the SQL of the last query.
+func dryRunDb(t *testing.T) (*gorm.DB, func() string) {
+	db, err := gorm.Open(postgres.New(postgres.Config{DSN: "host=localhost"}), &gorm.Config{DryRun: true, DisableAutomaticPing: true})
+	require.NoError(t, err)
+	var sql string
+	err = db.Callback().Query().After("gorm:query").Register("test_capture_sql", func(db *gorm.DB) {
+		sql = db.Statement.SQL.String()
+	})
+	require.NoError(t, err)
+	return db, func() string { return sql }
+}
+
+func TestChatShareStg_FindByTokenSkipsDeletedChats(t *testing.T) {
+	db, lastSql := dryRunDb(t)
+
+	_, err := NewChatShareStg(&ormSession{db: db}).FindByToken("token")
+	require.NoError(t, err)
+	require.Contains(t, lastSql(), "JOIN chats c ON c.id = s.chat_id AND c.deleted_at IS NULL")
+	require.Contains(t, lastSql(), "s.token = $1")
+}
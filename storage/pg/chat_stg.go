@@ -1,7 +1,14 @@
 package pg
 
 import (
+	"errors"
+	"fmt"
+	"time"
+
 	"github.com/amahdian/ai-assistant-be/domain/model"
+	"github.com/amahdian/ai-assistant-be/domain/model/common"
+	"github.com/amahdian/ai-assistant-be/global/errs"
+	"gorm.io/gorm"
 )
 
 type ChatStg struct {
@@ -14,12 +21,223 @@ func NewChatStg(ses *ormSession) *ChatStg {
 	}
 }
 
-func (stg *ChatStg) ListByUserId(userId string) ([]*model.Chat, error) {
+func (stg *ChatStg) ListByUserId(userId string, filter *model.ChatFilter, pagination *common.CursorPagination) ([]*model.Chat, error) {
+	sortColumn := "last_message_at"
+	if filter.Sort == model.ChatSortCreated {
+		sortColumn = "created_at"
+	}
+
+	db := stg.db.
+		Scopes(withChatFilter(userId, filter)).
+		Order(fmt.Sprintf("%s DESC, id DESC", sortColumn))
+
+	if pagination.Cursor != "" {
+		cursor, err := common.DecodeCursor(pagination.Cursor)
+		if err != nil {
+			return nil, errs.Newf(errs.InvalidArgument, err, "Invalid cursor %q.", pagination.Cursor)
+		}
+		db = db.Where(fmt.Sprintf("(%s, id) < (?, ?)", sortColumn), cursor.At, cursor.ID)
+	}
+
+	// one extra chat tells whether there is a next page
 	var chats []*model.Chat
+	if err := db.Limit(pagination.Limit + 1).Find(&chats).Error; err != nil {
+		return nil, err
+	}
+
+	pagination.NextCursor = ""
+	if len(chats) > pagination.Limit {
+		chats = chats[:pagination.Limit]
+		last := chats[len(chats)-1]
+		at := last.LastMessageAt
+		if filter.Sort == model.ChatSortCreated {
+			at = last.CreatedAt
+		}
+		pagination.NextCursor = (&common.Cursor{At: at, ID: last.ID.String()}).Encode()
+	}
+	return chats, nil
+}
+
+func (stg *ChatStg) SetFolder(chatIds []string, folderId *string) error {
+	return stg.db.
+		Model(&model.Chat{}).
+		Where("id IN ?", chatIds).
+		Update("folder_id", folderId).
+		Error
+}
+
+func (stg *ChatStg) SetPinned(chatId string, pinned bool) error {
+	return stg.db.
+		Model(&model.Chat{}).
+		Where("id = ?", chatId).
+		Update("pinned", pinned).
+		Error
+}
+
+func (stg *ChatStg) SetArchived(chatId string, archived bool) error {
+	return stg.db.
+		Model(&model.Chat{}).
+		Where("id = ?", chatId).
+		Update("archived", archived).
+		Error
+}
+
+func (stg *ChatStg) SetTitle(chatId, title string, state model.ChatTitleState) error {
+	return stg.db.
+		Model(&model.Chat{}).
+		Where("id = ?", chatId).
+		Updates(map[string]interface{}{"title": title, "title_state": state}).
+		Error
+}
+
+func (stg *ChatStg) SetPlaceholderTitle(chatId, title string) (bool, error) {
+	res := stg.db.
+		Model(&model.Chat{}).
+		Where("id = ? AND title_state = ?", chatId, model.ChatTitleStatePlaceholder).
+		Updates(map[string]interface{}{"title": title, "title_state": model.ChatTitleStateGenerated})
+	return res.RowsAffected > 0, res.Error
+}
+
+func (stg *ChatStg) SetLastMessageAt(chatId string, at time.Time) error {
+	// the column is read-only in the model, so the table is updated without it
+	return stg.db.
+		Table("chats").
+		Where("id = ?", chatId).
+		UpdateColumn("last_message_at", at).
+		Error
+}
+
+func (stg *ChatStg) LockGeneration(chatId, messageId string, staleBefore time.Time) (bool, error) {
+	// the row lock taken by the update serializes the concurrent attempts, only one of them matches the conditions
+	// the columns are read-only in the model, so the table is updated without it
+	res := stg.db.
+		Table("chats").
+		Where("id = ?", chatId).
+		Where("generation_state = ? OR generation_started_at < ?", model.ChatGenerationStateIdle, staleBefore).
+		UpdateColumns(map[string]interface{}{
+			"generation_state":          model.ChatGenerationStateGenerating,
+			"generation_id":             messageId,
+			"generation_started_at":     time.Now(),
+			"generation_stop_requested": false,
+		})
+	return res.RowsAffected > 0, res.Error
+}
+
+func (stg *ChatStg) UnlockGeneration(chatId, messageId string) error {
+	return stg.db.
+		Table("chats").
+		Where("id = ? AND generation_id = ?", chatId, messageId).
+		UpdateColumns(map[string]interface{}{
+			"generation_state":          model.ChatGenerationStateIdle,
+			"generation_id":             nil,
+			"generation_started_at":     nil,
+			"generation_stop_requested": false,
+		}).
+		Error
+}
+
+func (stg *ChatStg) RequestGenerationStop(chatId, messageId string, staleBefore time.Time) (bool, error) {
+	db := stg.db.
+		Table("chats").
+		Where("id = ? AND generation_state = ?", chatId, model.ChatGenerationStateGenerating).
+		Where("generation_started_at >= ?", staleBefore)
+	if messageId != "" {
+		db = db.Where("generation_id = ?", messageId)
+	}
+	res := db.UpdateColumn("generation_stop_requested", true)
+	return res.RowsAffected > 0, res.Error
+}
+
+func (stg *ChatStg) GenerationStopRequested(chatId, messageId string) (bool, error) {
+	var count int64
 	err := stg.db.
+		Table("chats").
+		Where("id = ? AND generation_id = ? AND generation_stop_requested", chatId, messageId).
+		Count(&count).
+		Error
+	return count > 0, err
+}
+
+func (stg *ChatStg) ListDeletedByUserId(userId string, pagination *common.CursorPagination) ([]*model.Chat, error) {
+	db := stg.deleted().
 		Where("user_id = ?", userId).
-		Find(&chats).
+		Order("deleted_at DESC, id DESC")
+
+	if pagination.Cursor != "" {
+		cursor, err := common.DecodeCursor(pagination.Cursor)
+		if err != nil {
+			return nil, errs.Newf(errs.InvalidArgument, err, "Invalid cursor %q.", pagination.Cursor)
+		}
+		db = db.Where("(deleted_at, id) < (?, ?)", cursor.At, cursor.ID)
+	}
+
+	// one extra chat tells whether there is a next page
+	var chats []*model.Chat
+	if err := db.Limit(pagination.Limit + 1).Find(&chats).Error; err != nil {
+		return nil, err
+	}
+
+	pagination.NextCursor = ""
+	if len(chats) > pagination.Limit {
+		chats = chats[:pagination.Limit]
+		last := chats[len(chats)-1]
+		pagination.NextCursor = (&common.Cursor{At: last.DeletedAt.Time, ID: last.ID.String()}).Encode()
+	}
+	return chats, nil
+}
+
+func (stg *ChatStg) FindDeletedById(id string) (*model.Chat, error) {
+	var chat *model.Chat
+	err := stg.deleted().First(&chat, "id = ?", id).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, stg.entityNotFoundErr(id)
+		}
+		return nil, errs.Wrapf(err, "Failed to get chats.")
+	}
+	return chat, nil
+}
+
+func (stg *ChatStg) Restore(chatId string) error {
+	return stg.deleted().
+		Model(&model.Chat{}).
+		Where("id = ?", chatId).
+		Update("deleted_at", nil).
 		Error
+}
 
-	return chats, err
+func (stg *ChatStg) PurgeDeleted(before time.Time) (int64, error) {
+	// the messages and everything else of the chats are removed by the cascading foreign keys
+	db := stg.db.Unscoped().Delete(&model.Chat{}, "deleted_at <= ?", before)
+	return db.RowsAffected, db.Error
+}
+
+func (stg *ChatStg) Purge(chatId string) error {
+	return stg.db.Unscoped().Delete(&model.Chat{}, "id = ?", chatId).Error
+}
+
+// deleted lifts the default scope of the chats to the ones in the trash.
+func (stg *ChatStg) deleted() *gorm.DB {
+	return stg.db.Unscoped().Where("deleted_at IS NOT NULL")
+}
+
+func withChatFilter(userId string, filter *model.ChatFilter) gormScope {
+	return func(db *gorm.DB) *gorm.DB {
+		db = db.
+			Where("user_id = ?", userId).
+			Where("archived = ?", filter.Archived)
+		if filter.FolderId != "" {
+			db = db.Where("folder_id = ?", filter.FolderId)
+		}
+		if filter.ProjectId != "" {
+			db = db.Where("project_id = ?", filter.ProjectId)
+		}
+		if filter.TagId != "" {
+			db = db.Where("EXISTS (SELECT 1 FROM chat_tags ct WHERE ct.chat_id = chats.id AND ct.tag_id = ?)", filter.TagId)
+		}
+		if filter.Pinned != nil {
+			db = db.Where("pinned = ?", *filter.Pinned)
+		}
+		return db
+	}
 }
@@ -19,6 +19,8 @@ import (
 
 var pluralizer = pluralize.NewClient()
 
+// crudStg implements the common functionalities of the storages.
+// Models with a gorm.DeletedAt field are soft deleted, all the queries are scoped to the ones which are not deleted.
 type crudStg[M schema.Tabler] struct {
 	db *gorm.DB
 
@@ -231,7 +233,7 @@ func (stg *crudStg[M]) DeleteByIds(ids []string) error {
 	} else {
 		err := stg.db.Transaction(func(tx *gorm.DB) (err error) {
 			for _, chunk := range lo.Chunk(ids, maxAllowedParams) {
-				err = stg.db.Where("id in ?", chunk).Delete(&model).Error
+				err = tx.Where("id in ?", chunk).Delete(&model).Error
 				if err != nil {
 					return
 				}
@@ -0,0 +1,33 @@
+package pg
+
+import (
+	"github.com/amahdian/ai-assistant-be/domain/model"
+	"github.com/amahdian/ai-assistant-be/domain/model/common"
+	"gorm.io/gorm/clause"
+)
+
+// EmbeddingStg is the pgvector backed vector index.
+type EmbeddingStg struct {
+	crudStg[*model.MessageEmbedding]
+}
+
+func NewEmbeddingStg(ses *ormSession) *EmbeddingStg {
+	return &EmbeddingStg{
+		crudStg: crudStg[*model.MessageEmbedding]{db: ses.db},
+	}
+}
+
+func (stg *EmbeddingStg) SearchByUserId(userId string, vector common.Vector, limit int) ([]*model.EmbeddingMatch, error) {
+	var matches []*model.EmbeddingMatch
+	// <=> is the cosine distance, ordering by it directly lets the hnsw index serve the query
+	err := stg.db.
+		Model(&model.MessageEmbedding{}).
+		Select("message_id, chat_id, 1 - (embedding <=> ?::vector) AS similarity", vector).
+		Where("user_id = ?", userId).
+		Order(clause.OrderBy{Expression: clause.Expr{SQL: "embedding <=> ?::vector", Vars: []interface{}{vector}}}).
+		Limit(limit).
+		Scan(&matches).
+		Error
+
+	return matches, err
+}
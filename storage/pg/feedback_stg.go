@@ -0,0 +1,133 @@
+package pg
+
+import (
+	"github.com/amahdian/ai-assistant-be/domain/model"
+	"github.com/amahdian/ai-assistant-be/global"
+	"github.com/amahdian/ai-assistant-be/global/errs"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var periodTruncUnits = map[global.PeriodType]string{
+	global.MONTHLY: "month",
+	global.YEARLY:  "year",
+}
+
+type FeedbackStg struct {
+	crudStg[*model.MessageFeedback]
+}
+
+func NewFeedbackStg(ses *ormSession) *FeedbackStg {
+	return &FeedbackStg{
+		crudStg: crudStg[*model.MessageFeedback]{db: ses.db},
+	}
+}
+
+func (stg *FeedbackStg) Upsert(feedback *model.MessageFeedback) error {
+	return stg.db.
+		Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "message_id"}},
+			DoUpdates: clause.AssignmentColumns([]string{"rating", "reasons", "comment", "agent", "model", "updated_at"}),
+		}).
+		Create(feedback).
+		Error
+}
+
+func (stg *FeedbackStg) DeleteByMessageId(messageId string) error {
+	db := stg.db.Delete(&model.MessageFeedback{}, "message_id = ?", messageId)
+	if db.Error != nil {
+		return db.Error
+	}
+	if db.RowsAffected < 1 {
+		return errs.Newf(errs.NotFound, nil, "Message %q has no feedback.", messageId)
+	}
+	return nil
+}
+
+func (stg *FeedbackStg) ListByFilter(filter *model.FeedbackReportFilter) ([]*model.MessageFeedback, error) {
+	var feedback []*model.MessageFeedback
+	err := stg.db.
+		Scopes(withFeedbackFilter(filter)).
+		Order("updated_at, message_id").
+		Find(&feedback).
+		Error
+
+	return feedback, err
+}
+
+func (stg *FeedbackStg) ReportRows(filter *model.FeedbackReportFilter) ([]*model.FeedbackReportRow, error) {
+	var rows []*model.FeedbackReportRow
+	err := stg.db.
+		Table("message_feedback").
+		Select(
+			"date_trunc(?, updated_at) AS period, agent, model, "+
+				"COUNT(*) AS total, "+
+				"COUNT(*) FILTER (WHERE rating > 0) AS positive, "+
+				"COUNT(*) FILTER (WHERE rating < 0) AS negative",
+			periodTruncUnits[filter.Period]).
+		Scopes(withFeedbackFilter(filter)).
+		Group("1, agent, model").
+		Order("period DESC, total DESC").
+		Scan(&rows).
+		Error
+
+	return rows, err
+}
+
+func (stg *FeedbackStg) ReportReasons(filter *model.FeedbackReportFilter) ([]*model.FeedbackReasonCount, error) {
+	var reasons []*model.FeedbackReasonCount
+	err := stg.db.
+		Table("message_feedback, jsonb_array_elements_text(reasons) AS reason").
+		Select("date_trunc(?, updated_at) AS period, agent, model, reason, COUNT(*) AS count", periodTruncUnits[filter.Period]).
+		Scopes(withFeedbackFilter(filter)).
+		Where("rating < 0").
+		Group("1, agent, model, reason").
+		Order("count DESC, reason").
+		Scan(&reasons).
+		Error
+
+	return reasons, err
+}
+
+func (stg *FeedbackStg) ReportExamples(filter *model.FeedbackReportFilter, perRow int) ([]*model.FeedbackExample, error) {
+	ranked := stg.db.
+		Table("message_feedback f").
+		Select(
+			"date_trunc(?, f.updated_at) AS period, f.agent, f.model, f.message_id, f.chat_id, f.reasons, f.comment, f.updated_at, "+
+				"ROW_NUMBER() OVER (PARTITION BY date_trunc(?, f.updated_at), f.agent, f.model ORDER BY f.updated_at DESC) AS rank",
+			periodTruncUnits[filter.Period], periodTruncUnits[filter.Period]).
+		Scopes(withFeedbackFilter(filter)).
+		Where("f.rating < 0")
+
+	var examples []*model.FeedbackExample
+	err := stg.db.
+		Table("(?) AS e", ranked).
+		Select("e.period, e.agent, e.model, e.message_id, e.chat_id, e.reasons, e.comment, e.updated_at, m.content").
+		Joins("JOIN messages m ON m.id = e.message_id").
+		Where("e.rank <= ?", perRow).
+		Order("e.updated_at DESC").
+		Scan(&examples).
+		Error
+
+	return examples, err
+}
+
+// withFeedbackFilter narrows the feedback down by the filter. The feedback is dated by its last rating,
+// so a rating changed later counts in the period it was changed in.
+func withFeedbackFilter(filter *model.FeedbackReportFilter) gormScope {
+	return func(db *gorm.DB) *gorm.DB {
+		if filter.Agent != "" {
+			db = db.Where("agent = ?", filter.Agent)
+		}
+		if filter.Model != "" {
+			db = db.Where("model = ?", filter.Model)
+		}
+		if filter.From != nil {
+			db = db.Where("updated_at >= ?", *filter.From)
+		}
+		if filter.To != nil {
+			db = db.Where("updated_at < ?", *filter.To)
+		}
+		return db
+	}
+}
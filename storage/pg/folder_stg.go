@@ -0,0 +1,26 @@
+package pg
+
+import (
+	"github.com/amahdian/ai-assistant-be/domain/model"
+)
+
+type FolderStg struct {
+	crudStg[*model.Folder]
+}
+
+func NewFolderStg(ses *ormSession) *FolderStg {
+	return &FolderStg{
+		crudStg: crudStg[*model.Folder]{db: ses.db},
+	}
+}
+
+func (stg *FolderStg) ListByUserId(userId string) ([]*model.Folder, error) {
+	var folders []*model.Folder
+	err := stg.db.
+		Where("user_id = ?", userId).
+		Order("name").
+		Find(&folders).
+		Error
+
+	return folders, err
+}
@@ -0,0 +1,63 @@
+package pg
+
+import (
+	"errors"
+	"time"
+
+	"github.com/amahdian/ai-assistant-be/domain/model"
+	"github.com/amahdian/ai-assistant-be/global/errs"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type IdempotencyKeyStg struct {
+	db *gorm.DB
+}
+
+func NewIdempotencyKeyStg(ses *ormSession) *IdempotencyKeyStg {
+	return &IdempotencyKeyStg{db: ses.db}
+}
+
+func (stg *IdempotencyKeyStg) Claim(key *model.IdempotencyKey) (bool, error) {
+	// an expired key is taken over as if it never existed
+	db := stg.db.
+		Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "user_id"}, {Name: "key"}},
+			DoUpdates: clause.AssignmentColumns([]string{"fingerprint", "state", "chat_id", "message_id", "response", "created_at", "expires_at"}),
+			Where: clause.Where{Exprs: []clause.Expression{
+				clause.Expr{SQL: "idempotency_keys.expires_at <= ?", Vars: []interface{}{key.CreatedAt}},
+			}},
+		}).
+		Create(key)
+	if db.Error != nil {
+		return false, db.Error
+	}
+	return db.RowsAffected > 0, nil
+}
+
+func (stg *IdempotencyKeyStg) FindByKey(userId, key string) (*model.IdempotencyKey, error) {
+	record := &model.IdempotencyKey{}
+	err := stg.db.Where("user_id = ? AND key = ?", userId, key).First(record).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, errs.Newf(errs.NotFound, nil, "Idempotency key %q could not be found.", key)
+	}
+	return record, err
+}
+
+func (stg *IdempotencyKeyStg) Complete(key *model.IdempotencyKey) error {
+	return stg.db.
+		Model(&model.IdempotencyKey{}).
+		Where("user_id = ? AND key = ?", key.UserId, key.Key).
+		Select("state", "chat_id", "message_id", "response").
+		Updates(key).
+		Error
+}
+
+func (stg *IdempotencyKeyStg) DeleteByKey(userId, key string) error {
+	return stg.db.Delete(&model.IdempotencyKey{}, "user_id = ? AND key = ?", userId, key).Error
+}
+
+func (stg *IdempotencyKeyStg) DeleteExpired(before time.Time) (int64, error) {
+	db := stg.db.Delete(&model.IdempotencyKey{}, "expires_at <= ?", before)
+	return db.RowsAffected, db.Error
+}
@@ -0,0 +1,60 @@
+package pg
+
+import (
+	"github.com/amahdian/ai-assistant-be/domain/model"
+	"github.com/amahdian/ai-assistant-be/domain/model/common"
+	"gorm.io/gorm/clause"
+)
+
+// memoryColumns are the columns of the memories without their embedding.
+const memoryColumns = "id, user_id, content, source_chat_id, source_message_id, created_at, updated_at"
+
+type MemoryStg struct {
+	crudStg[*model.Memory]
+}
+
+func NewMemoryStg(ses *ormSession) *MemoryStg {
+	return &MemoryStg{
+		crudStg: crudStg[*model.Memory]{db: ses.db},
+	}
+}
+
+func (stg *MemoryStg) ListByUserId(userId string) ([]*model.Memory, error) {
+	var memories []*model.Memory
+	err := stg.db.
+		Select(memoryColumns).
+		Where("user_id = ?", userId).
+		Order("updated_at DESC, id").
+		Find(&memories).
+		Error
+	return memories, err
+}
+
+func (stg *MemoryStg) SearchByUserId(userId string, vector common.Vector, limit int) ([]*model.Memory, error) {
+	var memories []*model.Memory
+	// <=> is the cosine distance, ordering by it directly lets the hnsw index serve the query
+	err := stg.db.
+		Model(&model.Memory{}).
+		Select(memoryColumns+", 1 - (embedding <=> ?::vector) AS similarity", vector).
+		Where("user_id = ?", userId).
+		Order(clause.OrderBy{Expression: clause.Expr{SQL: "embedding <=> ?::vector", Vars: []interface{}{vector}}}).
+		Limit(limit).
+		Find(&memories).
+		Error
+	return memories, err
+}
+
+func (stg *MemoryStg) DeleteByUserId(userId, id string) error {
+	db := stg.db.Delete(&model.Memory{}, "id = ? AND user_id = ?", id, userId)
+	if db.Error != nil {
+		return db.Error
+	}
+	if db.RowsAffected < 1 {
+		return stg.entityNotFoundErr(id)
+	}
+	return nil
+}
+
+func (stg *MemoryStg) DeleteAllByUserId(userId string) error {
+	return stg.db.Delete(&model.Memory{}, "user_id = ?", userId).Error
+}
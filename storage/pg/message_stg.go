@@ -2,6 +2,16 @@ package pg
 
 import (
 	"github.com/amahdian/ai-assistant-be/domain/model"
+	"github.com/amahdian/ai-assistant-be/domain/model/common"
+	"github.com/amahdian/ai-assistant-be/global/errs"
+	"github.com/samber/lo"
+	"gorm.io/gorm"
+)
+
+const (
+	// searchConfig must match the text search configuration used by the messages search vector trigger
+	searchConfig    = "english"
+	headlineOptions = "StartSel=<mark>, StopSel=</mark>, MaxWords=35, MinWords=15, MaxFragments=2"
 )
 
 type MessageStg struct {
@@ -18,8 +28,97 @@ func (stg *MessageStg) ListByChatId(chatId string) ([]*model.Message, error) {
 	var messages []*model.Message
 	err := stg.db.
 		Where("chat_id = ?", chatId).
+		Order("created_at, id").
+		Find(&messages).
+		Error
+
+	return messages, err
+}
+
+func (stg *MessageStg) ListPageByChatId(chatId string, pagination *common.CursorPagination) ([]*model.Message, error) {
+	db := stg.db.
+		Where("chat_id = ?", chatId).
+		Where("metadata->>? IS NULL", model.MetadataSupersededBy).
+		Order("created_at DESC, id DESC")
+
+	if pagination.Cursor != "" {
+		cursor, err := common.DecodeCursor(pagination.Cursor)
+		if err != nil {
+			return nil, errs.Newf(errs.InvalidArgument, err, "Invalid cursor %q.", pagination.Cursor)
+		}
+		db = db.Where("(created_at, id) < (?, ?)", cursor.At, cursor.ID)
+	}
+
+	// one extra message tells whether there is an older page
+	var messages []*model.Message
+	if err := db.Limit(pagination.Limit + 1).Find(&messages).Error; err != nil {
+		return nil, err
+	}
+
+	pagination.NextCursor = ""
+	if len(messages) > pagination.Limit {
+		messages = messages[:pagination.Limit]
+		oldest := messages[len(messages)-1]
+		pagination.NextCursor = (&common.Cursor{At: oldest.CreatedAt, ID: oldest.ID.String()}).Encode()
+	}
+	return lo.Reverse(messages), nil
+}
+
+func (stg *MessageStg) SearchByUserId(userId, query string, pagination *common.Pagination) ([]*model.ChatSearchResult, error) {
+	tsQuery := gorm.Expr("plainto_tsquery(?, ?)", searchConfig, query)
+
+	// the best matching message of every chat
+	bestMatches := stg.db.
+		Table(withAlias(&model.Message{}, "m")).
+		Select("DISTINCT ON (m.chat_id) m.chat_id, m.id AS message_id, m.role, m.content, m.created_at, ts_rank(m.search_vector, ?) AS rank", tsQuery).
+		Joins("JOIN chats c ON c.id = m.chat_id").
+		Where("c.user_id = ? AND c.deleted_at IS NULL", userId).
+		Where("m.search_vector @@ ?", tsQuery).
+		Where("m.metadata->>? IS NULL", model.MetadataSupersededBy).
+		Order("m.chat_id, rank DESC, m.created_at DESC")
+
+	db := stg.db.
+		Table("(?) AS r", bestMatches).
+		Select("r.chat_id, c.title AS chat_title, r.message_id, r.role, r.rank, r.created_at, ts_headline(?, r.content, ?, ?) AS snippet", searchConfig, tsQuery, headlineOptions).
+		Joins("JOIN chats c ON c.id = r.chat_id").
+		Order("r.rank DESC, r.created_at DESC")
+
+	if pagination != nil && pagination != common.InternalPagination() {
+		err := stg.db.Table("(?) AS r", bestMatches).Count(&pagination.TotalCount).Error
+		if err != nil {
+			return nil, err
+		}
+		db = db.Limit(pagination.PageSize).Offset(pagination.PageSize * pagination.Page)
+	}
+
+	var results []*model.ChatSearchResult
+	err := db.Scan(&results).Error
+	return results, err
+}
+
+func (stg *MessageStg) ListWithoutEmbedding(limit int) ([]*model.Message, error) {
+	var messages []*model.Message
+	err := stg.db.
+		Table(withAlias(&model.Message{}, "m")).
+		Select("m.*").
+		Joins("LEFT JOIN message_embeddings e ON e.message_id = m.id").
+		// the chats in the trash are indexed once they are restored
+		Joins("JOIN chats c ON c.id = m.chat_id AND c.deleted_at IS NULL").
+		Where("e.message_id IS NULL").
+		Where("m.content <> ''").
+		Order("m.created_at").
+		Limit(limit).
 		Find(&messages).
 		Error
 
 	return messages, err
 }
+
+func (stg *MessageStg) SetMetadata(id, key, value string) error {
+	// merging in the database keeps the keys written concurrently, e.g. superseded_by by a regeneration
+	return stg.db.
+		Model(&model.Message{}).
+		Where("id = ?", id).
+		Update("metadata", gorm.Expr("COALESCE(metadata, '{}'::jsonb) || jsonb_build_object(?::text, ?::text)", key, value)).
+		Error
+}
@@ -0,0 +1,50 @@
+package pg
+
+import (
+	"github.com/amahdian/ai-assistant-be/domain/model"
+	"github.com/amahdian/ai-assistant-be/domain/model/common"
+	"gorm.io/gorm/clause"
+)
+
+type ProjectFileStg struct {
+	crudStg[*model.ProjectFile]
+}
+
+func NewProjectFileStg(ses *ormSession) *ProjectFileStg {
+	return &ProjectFileStg{
+		crudStg: crudStg[*model.ProjectFile]{db: ses.db},
+	}
+}
+
+func (stg *ProjectFileStg) ListByProjectId(projectId string) ([]*model.ProjectFile, error) {
+	var files []*model.ProjectFile
+	err := stg.db.
+		Where("project_id = ?", projectId).
+		Order("created_at").
+		Find(&files).
+		Error
+
+	return files, err
+}
+
+func (stg *ProjectFileStg) CreateChunks(chunks []*model.ProjectFileChunk) error {
+	if len(chunks) == 0 {
+		return nil
+	}
+	return stg.db.CreateInBatches(chunks, CalcBestBatchSize(chunks)).Error
+}
+
+func (stg *ProjectFileStg) SearchChunks(projectId string, vector common.Vector, limit int) ([]*model.ProjectFileChunk, error) {
+	var chunks []*model.ProjectFileChunk
+	err := stg.db.
+		Table(withAlias(&model.ProjectFileChunk{}, "c")).
+		Select("c.id, c.file_id, c.project_id, c.chunk_index, c.content, f.name AS file_name").
+		Joins("JOIN project_files f ON f.id = c.file_id").
+		Where("c.project_id = ?", projectId).
+		Order(clause.OrderBy{Expression: clause.Expr{SQL: "c.embedding <=> ?::vector", Vars: []interface{}{vector}}}).
+		Limit(limit).
+		Find(&chunks).
+		Error
+
+	return chunks, err
+}
@@ -0,0 +1,26 @@
+package pg
+
+import (
+	"github.com/amahdian/ai-assistant-be/domain/model"
+)
+
+type ProjectStg struct {
+	crudStg[*model.Project]
+}
+
+func NewProjectStg(ses *ormSession) *ProjectStg {
+	return &ProjectStg{
+		crudStg: crudStg[*model.Project]{db: ses.db},
+	}
+}
+
+func (stg *ProjectStg) ListByUserId(userId string) ([]*model.Project, error) {
+	var projects []*model.Project
+	err := stg.db.
+		Where("user_id = ?", userId).
+		Order("name").
+		Find(&projects).
+		Error
+
+	return projects, err
+}
@@ -26,7 +26,7 @@ func (stg *Stg) WithContext(ctx context.Context) storage.Storage {
 func (stg *Stg) Atomic(fn func(atomicStorage storage.Storage) error) (err error) {
 	tx := stg.db.Begin()
 	if tx.Error != nil {
-		return err
+		return tx.Error
 	}
 
 	defer func() {
@@ -112,3 +112,43 @@ func (stg *Stg) Chat(ctx context.Context) storage.ChatStorage {
 func (stg *Stg) Message(ctx context.Context) storage.MessageStorage {
 	return NewMessageStg(stg.mustOrmSession(ctx))
 }
+
+func (stg *Stg) Embedding(ctx context.Context) storage.EmbeddingStorage {
+	return NewEmbeddingStg(stg.mustOrmSession(ctx))
+}
+
+func (stg *Stg) Folder(ctx context.Context) storage.FolderStorage {
+	return NewFolderStg(stg.mustOrmSession(ctx))
+}
+
+func (stg *Stg) Tag(ctx context.Context) storage.TagStorage {
+	return NewTagStg(stg.mustOrmSession(ctx))
+}
+
+func (stg *Stg) Project(ctx context.Context) storage.ProjectStorage {
+	return NewProjectStg(stg.mustOrmSession(ctx))
+}
+
+func (stg *Stg) ProjectFile(ctx context.Context) storage.ProjectFileStorage {
+	return NewProjectFileStg(stg.mustOrmSession(ctx))
+}
+
+func (stg *Stg) ChatShare(ctx context.Context) storage.ChatShareStorage {
+	return NewChatShareStg(stg.mustOrmSession(ctx))
+}
+
+func (stg *Stg) Feedback(ctx context.Context) storage.FeedbackStorage {
+	return NewFeedbackStg(stg.mustOrmSession(ctx))
+}
+
+func (stg *Stg) IdempotencyKey(ctx context.Context) storage.IdempotencyKeyStorage {
+	return NewIdempotencyKeyStg(stg.mustOrmSession(ctx))
+}
+
+func (stg *Stg) UserSettings(ctx context.Context) storage.UserSettingsStorage {
+	return NewUserSettingsStg(stg.mustOrmSession(ctx))
+}
+
+func (stg *Stg) Memory(ctx context.Context) storage.MemoryStorage {
+	return NewMemoryStg(stg.mustOrmSession(ctx))
+}
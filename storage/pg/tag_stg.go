@@ -0,0 +1,77 @@
+package pg
+
+import (
+	"github.com/amahdian/ai-assistant-be/domain/model"
+	"gorm.io/gorm/clause"
+)
+
+type TagStg struct {
+	crudStg[*model.Tag]
+}
+
+func NewTagStg(ses *ormSession) *TagStg {
+	return &TagStg{
+		crudStg: crudStg[*model.Tag]{db: ses.db},
+	}
+}
+
+func (stg *TagStg) ListByUserId(userId string) ([]*model.Tag, error) {
+	var tags []*model.Tag
+	err := stg.db.
+		Where("user_id = ?", userId).
+		Order("name").
+		Find(&tags).
+		Error
+
+	return tags, err
+}
+
+func (stg *TagStg) ListByChatIds(chatIds []string) (map[string][]*model.Tag, error) {
+	var rows []struct {
+		ChatID string
+		model.Tag
+	}
+	err := stg.db.
+		Table(withAlias(&model.Tag{}, "t")).
+		Select("ct.chat_id, t.*").
+		Joins("JOIN chat_tags ct ON ct.tag_id = t.id").
+		Where("ct.chat_id IN ?", chatIds).
+		Order("t.name").
+		Scan(&rows).
+		Error
+	if err != nil {
+		return nil, err
+	}
+
+	tagsByChat := make(map[string][]*model.Tag)
+	for i := range rows {
+		tagsByChat[rows[i].ChatID] = append(tagsByChat[rows[i].ChatID], &rows[i].Tag)
+	}
+	return tagsByChat, nil
+}
+
+func (stg *TagStg) AddToChats(chatIds, tagIds []string) error {
+	chatTags := make([]*model.ChatTag, 0, len(chatIds)*len(tagIds))
+	for _, chatId := range chatIds {
+		for _, tagId := range tagIds {
+			chatTags = append(chatTags, &model.ChatTag{ChatID: chatId, TagID: tagId})
+		}
+	}
+	if len(chatTags) == 0 {
+		return nil
+	}
+	return stg.db.
+		Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&chatTags).
+		Error
+}
+
+func (stg *TagStg) RemoveFromChats(chatIds, tagIds []string) error {
+	if len(chatIds) == 0 || len(tagIds) == 0 {
+		return nil
+	}
+	return stg.db.
+		Where("chat_id IN ? AND tag_id IN ?", chatIds, tagIds).
+		Delete(&model.ChatTag{}).
+		Error
+}
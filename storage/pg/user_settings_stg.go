@@ -0,0 +1,39 @@
+package pg
+
+import (
+	"errors"
+
+	"github.com/amahdian/ai-assistant-be/domain/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type UserSettingsStg struct {
+	db *gorm.DB
+}
+
+func NewUserSettingsStg(ses *ormSession) *UserSettingsStg {
+	return &UserSettingsStg{db: ses.db}
+}
+
+func (stg *UserSettingsStg) FindByUserId(userId string) (*model.UserSettings, error) {
+	settings := &model.UserSettings{}
+	err := stg.db.Where("user_id = ?", userId).First(settings).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return model.NewUserSettings(userId), nil
+		}
+		return nil, err
+	}
+	return settings, nil
+}
+
+func (stg *UserSettingsStg) Save(settings *model.UserSettings) error {
+	return stg.db.
+		Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "user_id"}},
+			UpdateAll: true,
+		}).
+		Create(settings).
+		Error
+}
@@ -0,0 +1,15 @@
+package storage
+
+import (
+	"github.com/amahdian/ai-assistant-be/domain/model"
+	"github.com/amahdian/ai-assistant-be/domain/model/common"
+)
+
+type ProjectFileStorage interface {
+	CrudStorage[*model.ProjectFile]
+
+	ListByProjectId(projectId string) ([]*model.ProjectFile, error)
+	CreateChunks(chunks []*model.ProjectFileChunk) error
+	// SearchChunks returns the chunks of the project files closest to the vector by cosine similarity, the closest first.
+	SearchChunks(projectId string, vector common.Vector, limit int) ([]*model.ProjectFileChunk, error)
+}
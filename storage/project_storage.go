@@ -0,0 +1,11 @@
+package storage
+
+import (
+	"github.com/amahdian/ai-assistant-be/domain/model"
+)
+
+type ProjectStorage interface {
+	CrudStorage[*model.Project]
+
+	ListByUserId(userId string) ([]*model.Project, error)
+}
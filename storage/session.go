@@ -6,6 +6,25 @@ type Storage interface {
 	User(ctx context.Context) UserStorage
 	Chat(ctx context.Context) ChatStorage
 	Message(ctx context.Context) MessageStorage
+	Embedding(ctx context.Context) EmbeddingStorage
+	Folder(ctx context.Context) FolderStorage
+	Tag(ctx context.Context) TagStorage
+	Project(ctx context.Context) ProjectStorage
+	ProjectFile(ctx context.Context) ProjectFileStorage
+	ChatShare(ctx context.Context) ChatShareStorage
+	Feedback(ctx context.Context) FeedbackStorage
+	IdempotencyKey(ctx context.Context) IdempotencyKeyStorage
+	UserSettings(ctx context.Context) UserSettingsStorage
+	Memory(ctx context.Context) MemoryStorage
+
+	// Atomic runs fn as a single unit of work: the changes made through the storage given to fn
+	// are committed if fn returns nil and rolled back otherwise.
+	Atomic(fn func(atomicStorage Storage) error) error
+	// Session returns the session of the context, a new one if the context has none.
+	Session(ctx context.Context) Session
+	// Begin starts a transactional session and returns a context carrying it,
+	// the storages created with that context take part in the transaction.
+	Begin(ctx context.Context) (context.Context, Session, error)
 }
 
 type Session interface {
@@ -0,0 +1,16 @@
+package storage
+
+import (
+	"github.com/amahdian/ai-assistant-be/domain/model"
+)
+
+type TagStorage interface {
+	CrudStorage[*model.Tag]
+
+	ListByUserId(userId string) ([]*model.Tag, error)
+	// ListByChatIds returns the tags of every chat by chat id.
+	ListByChatIds(chatIds []string) (map[string][]*model.Tag, error)
+	// AddToChats tags every chat with every tag, tags a chat already has are skipped.
+	AddToChats(chatIds, tagIds []string) error
+	RemoveFromChats(chatIds, tagIds []string) error
+}
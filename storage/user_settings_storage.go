@@ -0,0 +1,12 @@
+package storage
+
+import (
+	"github.com/amahdian/ai-assistant-be/domain/model"
+)
+
+type UserSettingsStorage interface {
+	// FindByUserId returns the settings of the user, the default ones if the user never saved any.
+	FindByUserId(userId string) (*model.UserSettings, error)
+	// Save creates or replaces the settings of the user.
+	Save(settings *model.UserSettings) error
+}
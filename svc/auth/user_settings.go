@@ -0,0 +1,27 @@
+package auth
+
+import (
+	"context"
+
+	"github.com/amahdian/ai-assistant-be/domain/model"
+	"github.com/gin-gonic/gin"
+)
+
+type userSettingsCtx struct{}
+
+// WithUserSettings returns a context carrying the settings of the authenticated user.
+func WithUserSettings(ctx context.Context, settings *model.UserSettings) context.Context {
+	return context.WithValue(ctx, userSettingsCtx{}, settings)
+}
+
+// UserSettingsFromCtx returns the settings carried by the context, nil if the route did not load them.
+func UserSettingsFromCtx(ctx context.Context) *model.UserSettings {
+	if ginCtx, ok := ctx.(*gin.Context); ok {
+		ctx = ginCtx.Request.Context()
+	}
+	settings, ok := ctx.Value(userSettingsCtx{}).(*model.UserSettings)
+	if !ok {
+		return nil
+	}
+	return settings
+}
@@ -0,0 +1,193 @@
+package svc
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"strings"
+	"time"
+
+	"github.com/amahdian/ai-assistant-be/domain/model"
+	"github.com/amahdian/ai-assistant-be/global/errs"
+	"github.com/amahdian/ai-assistant-be/pkg/fileutil"
+	"github.com/samber/lo"
+)
+
+// maxExportFileNameLength is the maximum length of the exported file name without its extension.
+const maxExportFileNameLength = 80
+
+var roleNames = map[string]string{
+	"user":      "You",
+	"assistant": "Assistant",
+}
+
+var chatExportTemplate = template.Must(template.New("chat").Funcs(template.FuncMap{
+	"role":      roleName,
+	"timestamp": exportTimestamp,
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Title}}</title>
+<style>
+body { font-family: -apple-system, "Segoe UI", Roboto, sans-serif; max-width: 48rem; margin: 2rem auto; padding: 0 1rem; color: #1f2328; }
+header { border-bottom: 1px solid #d0d7de; margin-bottom: 1.5rem; }
+.exported { color: #656d76; font-size: .875rem; }
+.message { margin: 1rem 0; padding: .75rem 1rem; border-radius: .5rem; }
+.user { background: #ddf4ff; }
+.assistant { background: #f6f8fa; }
+.meta { color: #656d76; font-size: .75rem; margin-bottom: .5rem; }
+.content { white-space: pre-wrap; word-wrap: break-word; }
+.status { color: #9a6700; font-size: .75rem; margin-top: .5rem; }
+.citations { color: #656d76; font-size: .75rem; margin: .5rem 0 0; padding-left: 1.25rem; }
+</style>
+</head>
+<body>
+<header>
+<h1>{{.Title}}</h1>
+<p class="exported">Exported on {{timestamp .ExportedAt}}</p>
+</header>
+{{range .Messages}}<section class="message {{.Role}}">
+<div class="meta"><strong>{{role .Role}}</strong> · {{timestamp .CreatedAt}}</div>
+<div class="content">{{.Content}}</div>
+{{if ne .Status "completed"}}<div class="status">This reply is {{.Status}}.</div>
+{{end}}{{with .Citations}}<ol class="citations">
+{{range .}}<li><strong>{{.FileName}}</strong>: {{.Excerpt}}</li>
+{{end}}</ol>
+{{end}}</section>
+{{end}}</body>
+</html>
+`))
+
+// ExportChat renders the chat into a file of the format, the JSON format is described by model.ChatExport.
+// The Markdown and HTML formats only contain the active replies of the chat.
+// Messages have no attachments, the project documents a reply drew on are exported as its citations.
+func (s *chatSvc) ExportChat(chatID string, format model.ChatExportFormat, user *model.User) (*fileutil.File, error) {
+	chat, err := s.stg.Chat(s.ctx).FindById(chatID)
+	if err != nil {
+		return nil, errs.Wrapf(err, "failed to find chat")
+	}
+	if chat.UserId != user.ID.String() {
+		return nil, errors.New("permission denied")
+	}
+	messages, err := s.stg.Message(s.ctx).ListByChatId(chatID)
+	if err != nil {
+		return nil, errs.Wrapf(err, "failed to list messages")
+	}
+
+	export := newChatExport(chat, messages, time.Now())
+	var data []byte
+	switch format {
+	case model.ChatExportFormatJson:
+		data, err = json.MarshalIndent(export, "", "  ")
+	case model.ChatExportFormatHtml:
+		data, err = renderChatHtml(activeExport(export))
+	case model.ChatExportFormatMarkdown, "":
+		format = model.ChatExportFormatMarkdown
+		data = renderChatMarkdown(activeExport(export))
+	default:
+		return nil, errs.Newf(errs.InvalidArgument, nil, "Unsupported export format %q.", format)
+	}
+	if err != nil {
+		return nil, errs.Wrapf(err, "failed to render chat")
+	}
+
+	return &fileutil.File{
+		Filename: fmt.Sprintf("%s.%s", exportFileName(chat.Title), format),
+		Bytes:    data,
+		Size:     int64(len(data)),
+	}, nil
+}
+
+func newChatExport(chat *model.Chat, messages []*model.Message, exportedAt time.Time) *model.ChatExport {
+	return &model.ChatExport{
+		Version:    model.ChatExportVersion,
+		ExportedAt: exportedAt.UTC(),
+		Title:      chat.Title,
+		Agent:      chat.Agent,
+		CreatedAt:  chat.CreatedAt.UTC(),
+		Messages: lo.Map(messages, func(m *model.Message, _ int) *model.ChatExportMessage {
+			return &model.ChatExportMessage{
+				ID:        m.ID.String(),
+				Role:      m.Role,
+				Content:   m.Content,
+				Status:    m.Status,
+				CreatedAt: m.CreatedAt.UTC(),
+				Metadata:  lo.OmitByKeys(m.Metadata, []string{model.MetadataCitations}),
+				Citations: m.Citations(),
+			}
+		}),
+	}
+}
+
+// activeExport returns a copy of the export without the superseded replies.
+func activeExport(export *model.ChatExport) *model.ChatExport {
+	active := *export
+	active.Messages = lo.Filter(export.Messages, func(m *model.ChatExportMessage, _ int) bool {
+		_, superseded := m.Metadata[model.MetadataSupersededBy]
+		return !superseded
+	})
+	return &active
+}
+
+func renderChatMarkdown(export *model.ChatExport) []byte {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("# %s\n\n", export.Title))
+	sb.WriteString(fmt.Sprintf("_Exported on %s_\n", exportTimestamp(export.ExportedAt)))
+	for _, m := range export.Messages {
+		sb.WriteString(fmt.Sprintf("\n---\n\n**%s** · %s\n\n", roleName(m.Role), exportTimestamp(m.CreatedAt)))
+		sb.WriteString(strings.TrimSpace(m.Content))
+		sb.WriteString("\n")
+		if m.Status != "" && m.Status != model.MessageStatusCompleted {
+			sb.WriteString(fmt.Sprintf("\n_This reply is %s._\n", m.Status))
+		}
+		if len(m.Citations) > 0 {
+			sb.WriteString("\nSources:\n")
+			for i, c := range m.Citations {
+				excerpt := strings.Join(strings.Fields(c.Excerpt), " ")
+				sb.WriteString(fmt.Sprintf("%d. **%s**: %s\n", i+1, c.FileName, excerpt))
+			}
+		}
+	}
+	return []byte(sb.String())
+}
+
+func renderChatHtml(export *model.ChatExport) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := chatExportTemplate.Execute(&buf, export); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func roleName(role string) string {
+	if name, ok := roleNames[role]; ok {
+		return name
+	}
+	return role
+}
+
+func exportTimestamp(t time.Time) string {
+	return t.UTC().Format("2006-01-02 15:04 UTC")
+}
+
+// exportFileName turns the title into a portable file name.
+func exportFileName(title string) string {
+	name := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_':
+			return r
+		case r == ' ':
+			return '-'
+		}
+		return -1
+	}, strings.TrimSpace(title))
+	name = strings.Trim(truncateRunes(name, maxExportFileNameLength), "-")
+	if name == "" {
+		return "chat"
+	}
+	return name
+}
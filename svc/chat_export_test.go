@@ -0,0 +1,47 @@
+package svc
+
+import (
+	"testing"
+	"time"
+
+	"github.com/amahdian/ai-assistant-be/domain/model"
+	"github.com/amahdian/ai-assistant-be/domain/model/common"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChatExport(t *testing.T) {
+	at := time.Date(2024, 5, 1, 10, 30, 0, 0, time.UTC)
+	chat := &model.Chat{ID: uuid.New(), Title: "Scripts <b>& tags</b>", CreatedAt: at}
+	messages := []*model.Message{
+		{ID: uuid.New(), Role: "user", Content: "<script>alert(1)</script>", Status: model.MessageStatusCompleted, CreatedAt: at},
+		{ID: uuid.New(), Role: "assistant", Content: "first", Status: model.MessageStatusCompleted, CreatedAt: at, Metadata: common.Metadata{model.MetadataSupersededBy: "x"}},
+		{ID: uuid.New(), Role: "assistant", Content: "second", Status: model.MessageStatusStopped, CreatedAt: at, Metadata: common.Metadata{
+			model.MetadataCitations: model.EncodeCitations([]*model.Citation{{FileName: "handbook.pdf", Excerpt: "Chapter\n<one>"}}),
+		}},
+	}
+
+	export := newChatExport(chat, messages, at)
+	require.Len(t, export.Messages, 3)
+	require.Equal(t, model.ChatExportVersion, export.Version)
+	require.Equal(t, "handbook.pdf", export.Messages[2].Citations[0].FileName)
+	require.NotContains(t, export.Messages[2].Metadata, model.MetadataCitations)
+
+	active := activeExport(export)
+	require.Len(t, active.Messages, 2)
+	require.Len(t, export.Messages, 3)
+
+	html, err := renderChatHtml(active)
+	require.NoError(t, err)
+	require.Contains(t, string(html), "&lt;script&gt;alert(1)&lt;/script&gt;")
+	require.NotContains(t, string(html), "first")
+	require.Contains(t, string(html), "This reply is stopped.")
+	require.Contains(t, string(html), "<li><strong>handbook.pdf</strong>: Chapter\n&lt;one&gt;</li>")
+
+	md := string(renderChatMarkdown(active))
+	require.Contains(t, md, "**Assistant** · 2024-05-01 10:30 UTC\n\nsecond")
+	require.Contains(t, md, "Sources:\n1. **handbook.pdf**: Chapter <one>\n")
+
+	require.Equal(t, "Scripts-b-tagsb", exportFileName(chat.Title))
+	require.Equal(t, "chat", exportFileName("گفتگو"))
+}
@@ -0,0 +1,94 @@
+package svc
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/amahdian/ai-assistant-be/domain/model"
+	"github.com/amahdian/ai-assistant-be/global/errs"
+	"github.com/amahdian/ai-assistant-be/storage"
+	"github.com/google/uuid"
+	"github.com/samber/lo"
+)
+
+// ForkChat copies the messages of the chat up to and including the message into a new chat,
+// the whole chat is copied when messageID is empty. Forking from a superseded reply makes it the
+// active reply of the fork. The fork keeps the agent, project and summary of the chat.
+// Chats can not be forked while a reply is generated, the fork would miss the reply.
+func (s *chatSvc) ForkChat(chatID, messageID string, user *model.User) (*model.Chat, error) {
+	chat, err := s.stg.Chat(s.ctx).FindById(chatID)
+	if err != nil {
+		return nil, errs.Wrapf(err, "failed to find chat")
+	}
+	if chat.UserId != user.ID.String() {
+		return nil, errors.New("permission denied")
+	}
+	// the lock is held while the messages are copied, so no reply can be generated on any instance meanwhile
+	lockID := uuid.New().String()
+	if err = s.lockChat(chatID, lockID); err != nil {
+		return nil, err
+	}
+	defer s.unlockChat(context.WithoutCancel(s.ctx), chatID, lockID)
+
+	messages, err := s.stg.Message(s.ctx).ListByChatId(chatID)
+	if err != nil {
+		return nil, errs.Wrapf(err, "failed to list messages")
+	}
+	fork, err := newFork(chat, messages, messageID, user)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.stg.Atomic(func(stg storage.Storage) error {
+		if err := stg.Chat(s.ctx).CreateOne(fork.chat); err != nil {
+			return errs.Wrapf(err, "failed to create fork")
+		}
+		if err := stg.Message(s.ctx).CreateInBatches(fork.messages); err != nil {
+			return errs.Wrapf(err, "failed to copy messages")
+		}
+		// the copied messages keep their timestamps, the fork itself is new activity
+		if err := stg.Chat(s.ctx).SetLastMessageAt(fork.chat.ID.String(), fork.chat.CreatedAt); err != nil {
+			return errs.Wrapf(err, "failed to update fork activity")
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	fork.chat.LastMessageAt = fork.chat.CreatedAt
+	for _, m := range fork.messages {
+		s.embeddings.enqueue(m, user.ID.String())
+	}
+	return fork.chat, nil
+}
+
+// newFork builds the fork of the chat from its messages up to and including the message.
+func newFork(chat *model.Chat, messages []*model.Message, messageID string, user *model.User) (*importedChat, error) {
+	chatID := chat.ID.String()
+	if len(messages) == 0 {
+		return nil, errs.Newf(errs.FailedPrecondition, nil, "Chat %q has no messages to fork.", chatID)
+	}
+	if messageID != "" {
+		_, index, ok := lo.FindIndexOf(messages, func(m *model.Message) bool { return m.ID.String() == messageID })
+		if !ok {
+			return nil, errs.Newf(errs.NotFound, nil, "Message %q could not be found in chat %q.", messageID, chatID)
+		}
+		messages = messages[:index+1]
+	}
+	forkedFrom := messages[len(messages)-1].ID.String()
+
+	// the copy goes through the export format, which remaps the ids of the regenerated replies
+	// and drops the references to the replies left out of the fork
+	fork, err := importChatExport(newChatExport(chat, messages, chat.CreatedAt), user)
+	if err != nil {
+		return nil, errs.Wrapf(err, "failed to copy chat")
+	}
+	fork.chat.CreatedAt = time.Now()
+	fork.chat.TitleState = chat.TitleState
+	fork.chat.Summary = chat.Summary
+	fork.chat.ProjectId = chat.ProjectId
+	fork.chat.ForkedFromChatId = &chatID
+	fork.chat.ForkedFromMessageId = &forkedFrom
+	return fork, nil
+}
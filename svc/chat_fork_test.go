@@ -0,0 +1,45 @@
+package svc
+
+import (
+	"testing"
+	"time"
+
+	"github.com/amahdian/ai-assistant-be/domain/model"
+	"github.com/amahdian/ai-assistant-be/domain/model/common"
+	"github.com/amahdian/ai-assistant-be/global/errs"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewFork(t *testing.T) {
+	at := time.Date(2024, 5, 1, 10, 30, 0, 0, time.UTC)
+	chat := &model.Chat{ID: uuid.New(), Title: "Original", CreatedAt: at}
+	first, second := uuid.New(), uuid.New()
+	messages := []*model.Message{
+		{ID: uuid.New(), Role: "user", Content: "question", CreatedAt: at},
+		{ID: first, Role: "assistant", Content: "first", CreatedAt: at, Metadata: common.Metadata{model.MetadataSupersededBy: second.String()}},
+		{ID: second, Role: "assistant", Content: "second", CreatedAt: at, Metadata: common.Metadata{model.MetadataRegeneratedFrom: first.String()}},
+		{ID: uuid.New(), Role: "user", Content: "follow-up", CreatedAt: at},
+	}
+	user := &model.User{ID: uuid.New()}
+
+	// forking from the superseded reply makes it the active one
+	fork, err := newFork(chat, messages, first.String(), user)
+	require.NoError(t, err)
+	require.Len(t, fork.messages, 2)
+	require.Equal(t, "first", fork.messages[1].Content)
+	require.NotContains(t, fork.messages[1].Metadata, model.MetadataSupersededBy)
+	require.Equal(t, first.String(), *fork.chat.ForkedFromMessageId)
+	require.Equal(t, chat.ID.String(), *fork.chat.ForkedFromChatId)
+	// the fork is a new chat, only its messages keep their timestamps
+	require.WithinDuration(t, time.Now(), fork.chat.CreatedAt, time.Minute)
+	require.Equal(t, at, fork.messages[0].CreatedAt)
+
+	whole, err := newFork(chat, messages, "", user)
+	require.NoError(t, err)
+	require.Len(t, whole.messages, 4)
+	require.Equal(t, whole.messages[2].ID.String(), whole.messages[1].Metadata[model.MetadataSupersededBy])
+
+	_, err = newFork(chat, messages, uuid.New().String(), user)
+	require.Equal(t, errs.NotFound, errs.Code(err))
+}
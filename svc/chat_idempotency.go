@@ -0,0 +1,189 @@
+package svc
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+
+	"github.com/amahdian/ai-assistant-be/domain/model"
+	"github.com/amahdian/ai-assistant-be/global/errs"
+	"github.com/amahdian/ai-assistant-be/pkg/logger"
+	"github.com/amahdian/ai-assistant-be/storage"
+)
+
+const (
+	// maxIdempotencyKeyLength is the maximum length of an Idempotency-Key.
+	maxIdempotencyKeyLength = 255
+	// idempotencyPollInterval is how often a retry checks whether the original request completed.
+	idempotencyPollInterval = 500 * time.Millisecond
+	// idempotencyPurgeInterval is how often the expired keys are deleted.
+	idempotencyPurgeInterval = time.Hour
+)
+
+// requestFingerprint hashes the parts of a request, a key can only be retried with the same request.
+func requestFingerprint(parts ...string) string {
+	h := sha256.New()
+	for _, part := range parts {
+		h.Write([]byte(part))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// acquireKey claims the key for the request, the request must then either complete or release it.
+// If an earlier request claimed the key it waits for that request to complete and returns its record
+// with claimed set to false, so the caller replays it. An empty key returns a nil record and claimed set to true.
+func (s *chatSvc) acquireKey(key, fingerprint string, user *model.User) (record *model.IdempotencyKey, claimed bool, err error) {
+	if key == "" {
+		return nil, true, nil
+	}
+	if len(key) > maxIdempotencyKeyLength {
+		return nil, false, errs.Newf(errs.InvalidArgument, nil, "The Idempotency-Key can not be longer than %d characters.", maxIdempotencyKeyLength)
+	}
+
+	ctx, cancel := context.WithTimeout(s.ctx, generationTimeout)
+	defer cancel()
+	ticker := time.NewTicker(idempotencyPollInterval)
+	defer ticker.Stop()
+
+	keyStg := s.stg.IdempotencyKey(s.ctx)
+	for {
+		now := time.Now()
+		record = &model.IdempotencyKey{
+			UserId:      user.ID.String(),
+			Key:         key,
+			Fingerprint: fingerprint,
+			State:       model.IdempotencyStatePending,
+			CreatedAt:   now,
+			ExpiresAt:   now.Add(s.idempotencyTTL),
+		}
+		if claimed, err = keyStg.Claim(record); err != nil {
+			return nil, false, errs.Wrapf(err, "failed to claim idempotency key")
+		}
+		if claimed {
+			return record, true, nil
+		}
+
+		existing, err := keyStg.FindByKey(user.ID.String(), key)
+		// a key which is not found was released by its failed request in the meantime
+		if err != nil && errs.Code(err) != errs.NotFound {
+			return nil, false, errs.Wrapf(err, "failed to find idempotency key")
+		}
+		if existing != nil {
+			if existing.Fingerprint != fingerprint {
+				return nil, false, errs.Newf(errs.InvalidArgument, nil, "The Idempotency-Key %q was already used for a different request.", key)
+			}
+			if existing.Completed() {
+				return existing, false, nil
+			}
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return nil, false, errs.Newf(errs.DeadlineExceeded, ctx.Err(), "The request with the Idempotency-Key %q is still in progress.", key)
+		}
+	}
+}
+
+// completeKey stores the result of the request of the key. Streamed requests have no response
+// and complete as soon as their generation started, their retries attach to the generation.
+func (s *chatSvc) completeKey(record *model.IdempotencyKey, chatID, messageID string, response any) {
+	if record == nil {
+		return
+	}
+	record.State = model.IdempotencyStateCompleted
+	record.ChatId = &chatID
+	record.MessageId = &messageID
+	if response != nil {
+		encoded, err := json.Marshal(response)
+		if err != nil {
+			logger.Errorf("failed to encode the response of idempotency key %q: %v", record.Key, err)
+			return
+		}
+		record.Response = encoded
+	}
+	// the request already ran, failing it now would only make the client retry it with a new key
+	if err := s.stg.IdempotencyKey(context.WithoutCancel(s.ctx)).Complete(record); err != nil {
+		logger.Errorf("failed to complete idempotency key %q: %v", record.Key, err)
+	}
+}
+
+// releaseKey deletes the key of a failed request, so its retries run the request again.
+func (s *chatSvc) releaseKey(record *model.IdempotencyKey) {
+	if record == nil {
+		return
+	}
+	if err := s.stg.IdempotencyKey(context.WithoutCancel(s.ctx)).DeleteByKey(record.UserId, record.Key); err != nil {
+		logger.Errorf("failed to release idempotency key %q: %v", record.Key, err)
+	}
+}
+
+// replayMessage returns the reply of the request of the key.
+func (s *chatSvc) replayMessage(record *model.IdempotencyKey) (*model.Message, error) {
+	if record.Response != nil {
+		message := &model.Message{}
+		if err := json.Unmarshal(record.Response, message); err != nil {
+			return nil, errs.Wrapf(err, "failed to decode the response of idempotency key %q", record.Key)
+		}
+		return message, nil
+	}
+	return s.awaitReply(*record.ChatId, *record.MessageId)
+}
+
+// replayStream streams the reply of the request of the key, from the generation if it is still running.
+func (s *chatSvc) replayStream(record *model.IdempotencyKey) (<-chan *model.StreamedMessage, error) {
+	if gen := s.generations.find(*record.ChatId, *record.MessageId); gen != nil {
+		return gen.subscribe(s.ctx, 0), nil
+	}
+	message, err := s.findReply(*record.MessageId)
+	if err != nil {
+		return nil, err
+	}
+	return finishedGeneration(message).subscribe(s.ctx, 0), nil
+}
+
+// awaitReply waits for the generation of the reply, if it is running, and returns the saved reply.
+func (s *chatSvc) awaitReply(chatID, messageID string) (*model.Message, error) {
+	if gen := s.generations.find(chatID, messageID); gen != nil {
+		for range gen.subscribe(s.ctx, 0) {
+		}
+		if err := s.ctx.Err(); err != nil {
+			return nil, err
+		}
+	}
+	return s.findReply(messageID)
+}
+
+func (s *chatSvc) findReply(messageID string) (*model.Message, error) {
+	message, err := s.stg.Message(s.ctx).FindById(messageID)
+	if errs.Code(err) == errs.NotFound {
+		// the generation runs on another instance
+		return nil, errs.Newf(errs.FailedPrecondition, err, "The reply to the request is still being generated.")
+	}
+	if err != nil {
+		return nil, errs.Wrapf(err, "failed to find message")
+	}
+	return message, nil
+}
+
+// purgeIdempotencyKeys periodically deletes the expired keys until ctx is done.
+func purgeIdempotencyKeys(ctx context.Context, stg storage.Storage) {
+	ticker := time.NewTicker(idempotencyPurgeInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			count, err := stg.IdempotencyKey(ctx).DeleteExpired(time.Now())
+			if err != nil {
+				logger.Errorf("failed to purge expired idempotency keys: %v", err)
+				continue
+			}
+			logger.Debugf("purged %d expired idempotency keys", count)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
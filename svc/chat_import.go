@@ -0,0 +1,319 @@
+package svc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/amahdian/ai-assistant-be/domain/model"
+	"github.com/amahdian/ai-assistant-be/domain/model/common"
+	"github.com/amahdian/ai-assistant-be/global/errs"
+	"github.com/amahdian/ai-assistant-be/pkg/fileutil"
+	"github.com/amahdian/ai-assistant-be/pkg/msg"
+	"github.com/amahdian/ai-assistant-be/storage"
+	"github.com/google/uuid"
+	"github.com/samber/lo"
+)
+
+// MaxImportFileSize is the maximum size of an imported file.
+const MaxImportFileSize = 100 << 20
+
+// chatGPTConversation is a conversation of the conversations.json file of a ChatGPT data export.
+// Its messages form a tree, every edit or regeneration branches it, current_node is the leaf of the active branch.
+type chatGPTConversation struct {
+	Title       string                 `json:"title"`
+	CreateTime  *float64               `json:"create_time"`
+	CurrentNode string                 `json:"current_node"`
+	Mapping     map[string]chatGPTNode `json:"mapping"`
+}
+
+type chatGPTNode struct {
+	Message *chatGPTMessage `json:"message"`
+	Parent  *string         `json:"parent"`
+}
+
+type chatGPTMessage struct {
+	Author struct {
+		Role string `json:"role"`
+	} `json:"author"`
+	CreateTime *float64 `json:"create_time"`
+	Content    struct {
+		ContentType string `json:"content_type"`
+		// strings for text, objects for images and other attachments
+		Parts []json.RawMessage `json:"parts"`
+	} `json:"content"`
+	Metadata struct {
+		IsVisuallyHidden bool `json:"is_visually_hidden_from_conversation"`
+	} `json:"metadata"`
+}
+
+// importedChat is a conversation ready to be created.
+type importedChat struct {
+	chat     *model.Chat
+	messages []*model.Message
+}
+
+// ImportChats creates the conversations of a ChatGPT conversations.json file or of one of our JSON exports.
+// Conversations which can not be imported are reported in the message container and the others are still imported.
+func (s *chatSvc) ImportChats(file *fileutil.File, user *model.User) ([]*model.Chat, *msg.MessageContainer, error) {
+	if file == nil {
+		return nil, nil, errs.Newf(errs.InvalidArgument, nil, "A file is required.")
+	}
+	if file.Size > MaxImportFileSize {
+		return nil, nil, errs.Newf(errs.InvalidArgument, nil, "The file can not be larger than %d MB.", MaxImportFileSize>>20)
+	}
+
+	mc := msg.NewMessageContainer()
+	var imported []*importedChat
+	data := bytes.TrimSpace(file.Bytes)
+	switch {
+	case bytes.HasPrefix(data, []byte("[")):
+		var conversations []json.RawMessage
+		if err := json.Unmarshal(data, &conversations); err != nil {
+			return nil, nil, errs.Newf(errs.InvalidArgument, err, "The file is not a valid conversations.json file.")
+		}
+		if len(conversations) == 0 {
+			return nil, nil, errs.Newf(errs.InvalidArgument, nil, "The file has no conversations.")
+		}
+		for i, raw := range conversations {
+			var conversation chatGPTConversation
+			if err := json.Unmarshal(raw, &conversation); err != nil {
+				mc.AddErrorf(importGroup(i, ""), "The conversation is malformed: %v", err)
+				continue
+			}
+			chat, err := importChatGPTConversation(&conversation, user)
+			if err != nil {
+				mc.AddError(importGroup(i, conversation.Title), err.Error())
+				continue
+			}
+			imported = append(imported, chat)
+		}
+	case bytes.HasPrefix(data, []byte("{")):
+		var export model.ChatExport
+		if err := json.Unmarshal(data, &export); err != nil {
+			return nil, nil, errs.Newf(errs.InvalidArgument, err, "The file is not a valid chat export.")
+		}
+		chat, err := importChatExport(&export, user)
+		if err != nil {
+			mc.AddError(importGroup(0, export.Title), err.Error())
+			break
+		}
+		imported = append(imported, chat)
+	default:
+		return nil, nil, errs.Newf(errs.InvalidArgument, nil, "The file is neither a conversations.json file nor a chat export.")
+	}
+
+	if len(imported) == 0 {
+		return nil, mc, nil
+	}
+
+	chats, err := createImportedChats(s.ctx, s.stg, s.embeddings, imported, user)
+	if err != nil {
+		return nil, nil, err
+	}
+	return chats, mc, nil
+}
+
+// createImportedChats creates the chats and their messages in bulk.
+func createImportedChats(ctx context.Context, stg storage.Storage, embeddings *embeddingIndexer, imported []*importedChat, user *model.User) ([]*model.Chat, error) {
+	chats := lo.Map(imported, func(c *importedChat, _ int) *model.Chat { return c.chat })
+	messages := lo.FlatMap(imported, func(c *importedChat, _ int) []*model.Message { return c.messages })
+	err := stg.Atomic(func(stg storage.Storage) error {
+		if err := stg.Chat(ctx).CreateInBatches(chats); err != nil {
+			return errs.Wrapf(err, "failed to create imported chats")
+		}
+		if err := stg.Message(ctx).CreateInBatches(messages); err != nil {
+			return errs.Wrapf(err, "failed to create imported messages")
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, m := range messages {
+		embeddings.enqueue(m, user.ID.String())
+	}
+	return chats, nil
+}
+
+// importChatGPTConversation flattens the conversation along its active branch, from the root to current_node.
+func importChatGPTConversation(conversation *chatGPTConversation, user *model.User) (*importedChat, error) {
+	node, ok := conversation.Mapping[conversation.CurrentNode]
+	if !ok {
+		return nil, fmt.Errorf("the current node %q of the conversation is missing", conversation.CurrentNode)
+	}
+
+	var path []*chatGPTMessage
+	visited := map[string]bool{conversation.CurrentNode: true}
+	for {
+		if node.Message != nil {
+			path = append(path, node.Message)
+		}
+		if node.Parent == nil {
+			break
+		}
+		if visited[*node.Parent] {
+			return nil, fmt.Errorf("the messages of the conversation form a cycle")
+		}
+		visited[*node.Parent] = true
+		if node, ok = conversation.Mapping[*node.Parent]; !ok {
+			return nil, fmt.Errorf("the conversation refers to a missing message")
+		}
+	}
+
+	createdAt := unixTime(conversation.CreateTime, time.Now())
+	chat := newImportedChat(conversation.Title, "", createdAt, user)
+	at := createdAt
+	for _, m := range lo.Reverse(path) {
+		if m.Metadata.IsVisuallyHidden || (m.Author.Role != "user" && m.Author.Role != "assistant") {
+			continue
+		}
+		content := chatGPTText(m)
+		if content == "" {
+			continue
+		}
+		// messages without a timestamp take the one of the previous message
+		at = unixTime(m.CreateTime, at)
+		chat.messages = append(chat.messages, &model.Message{
+			ID:        uuid.New(),
+			ChatID:    chat.chat.ID.String(),
+			Role:      m.Author.Role,
+			Content:   content,
+			Status:    model.MessageStatusCompleted,
+			CreatedAt: at,
+			Metadata:  common.Metadata{},
+		})
+	}
+	if len(chat.messages) == 0 {
+		return nil, fmt.Errorf("the conversation has no text messages")
+	}
+	return chat, nil
+}
+
+// chatGPTText joins the text parts of the message, images and other attachments are skipped.
+func chatGPTText(m *chatGPTMessage) string {
+	if m.Content.ContentType != "text" && m.Content.ContentType != "multimodal_text" {
+		return ""
+	}
+	var texts []string
+	for _, part := range m.Content.Parts {
+		var text string
+		if err := json.Unmarshal(part, &text); err == nil && strings.TrimSpace(text) != "" {
+			texts = append(texts, text)
+		}
+	}
+	return strings.Join(texts, "\n\n")
+}
+
+// importChatExport imports every message of the export, the ids of regenerated replies are remapped to the new ones
+// and references to messages missing from the export or to ids that are not unique are dropped.
+func importChatExport(export *model.ChatExport, user *model.User) (*importedChat, error) {
+	if export.Version < 1 || export.Version > model.ChatExportVersion {
+		return nil, fmt.Errorf("the export version %d is not supported", export.Version)
+	}
+	if _, ok := model.FindAgent(export.Agent); !ok {
+		return nil, fmt.Errorf("the agent %q does not exist", export.Agent)
+	}
+
+	chat := newImportedChat(export.Title, export.Agent, export.CreatedAt, user)
+	// every message gets its own id, the exported ids may be missing or repeated (e.g. hand-edited files)
+	// and only the unique ones can be referenced
+	newIds := make([]uuid.UUID, len(export.Messages))
+	ids := map[string]uuid.UUID{}
+	repeated := map[string]bool{}
+	for i, m := range export.Messages {
+		newIds[i] = uuid.New()
+		if m.ID == "" {
+			continue
+		}
+		if _, ok := ids[m.ID]; ok {
+			repeated[m.ID] = true
+		}
+		ids[m.ID] = newIds[i]
+	}
+	for id := range repeated {
+		delete(ids, id)
+	}
+	for i, m := range export.Messages {
+		if m.Role != "user" && m.Role != "assistant" {
+			return nil, fmt.Errorf("message %d has the unknown role %q", i+1, m.Role)
+		}
+		status := m.Status
+		if status == "" {
+			status = model.MessageStatusCompleted
+		}
+		metadata := common.Metadata{}
+		for key, value := range m.Metadata {
+			if key == model.MetadataRegeneratedFrom || key == model.MetadataSupersededBy {
+				// e.g. the superseded replies are left out of shared snapshots
+				id, ok := ids[value]
+				if !ok {
+					continue
+				}
+				value = id.String()
+			}
+			metadata[key] = value
+		}
+		if len(m.Citations) > 0 {
+			metadata[model.MetadataCitations] = model.EncodeCitations(m.Citations)
+		}
+		createdAt := m.CreatedAt
+		if createdAt.IsZero() {
+			createdAt = export.CreatedAt
+		}
+		chat.messages = append(chat.messages, &model.Message{
+			ID:        newIds[i],
+			ChatID:    chat.chat.ID.String(),
+			Role:      m.Role,
+			Content:   m.Content,
+			Status:    status,
+			CreatedAt: createdAt,
+			Metadata:  metadata,
+		})
+	}
+	if len(chat.messages) == 0 {
+		return nil, fmt.Errorf("the chat has no messages")
+	}
+	return chat, nil
+}
+
+func newImportedChat(title, agent string, createdAt time.Time, user *model.User) *importedChat {
+	title = truncateRunes(strings.TrimSpace(title), maxChatTitleLength)
+	titleState := model.ChatTitleStateGenerated
+	if title == "" {
+		title, titleState = model.PlaceholderChatTitle, model.ChatTitleStatePlaceholder
+	}
+	if createdAt.IsZero() {
+		createdAt = time.Now()
+	}
+	return &importedChat{
+		chat: &model.Chat{
+			ID:         uuid.New(),
+			UserId:     user.ID.String(),
+			Title:      title,
+			TitleState: titleState,
+			Agent:      agent,
+			CreatedAt:  createdAt,
+		},
+	}
+}
+
+// unixTime converts the fractional unix timestamp of ChatGPT exports, fallback is used when it is missing.
+func unixTime(timestamp *float64, fallback time.Time) time.Time {
+	if timestamp == nil || *timestamp <= 0 {
+		return fallback
+	}
+	sec, frac := math.Modf(*timestamp)
+	return time.Unix(int64(sec), int64(frac*1e9)).UTC()
+}
+
+func importGroup(index int, title string) string {
+	if title == "" {
+		return fmt.Sprintf("conversation %d", index+1)
+	}
+	return fmt.Sprintf("conversation %d (%s)", index+1, title)
+}
@@ -0,0 +1,94 @@
+package svc
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/amahdian/ai-assistant-be/domain/model"
+	"github.com/amahdian/ai-assistant-be/domain/model/common"
+	"github.com/google/uuid"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/require"
+)
+
+// the assistant reply was regenerated, "b2" is the reply on the active branch
+const chatGPTConversationJson = `{
+  "title": "Greetings",
+  "create_time": 1700000000.5,
+  "current_node": "b2",
+  "mapping": {
+    "root": {"message": null, "parent": null, "children": ["sys"]},
+    "sys": {"message": {"author": {"role": "system"}, "content": {"content_type": "text", "parts": [""]}, "metadata": {"is_visually_hidden_from_conversation": true}}, "parent": "root", "children": ["q"]},
+    "q": {"message": {"author": {"role": "user"}, "create_time": 1700000001, "content": {"content_type": "multimodal_text", "parts": [{"asset_pointer": "file-1"}, "Hello"]}}, "parent": "sys", "children": ["b1", "b2"]},
+    "b1": {"message": {"author": {"role": "assistant"}, "create_time": 1700000002, "content": {"content_type": "text", "parts": ["Hi"]}}, "parent": "q", "children": []},
+    "b2": {"message": {"author": {"role": "assistant"}, "create_time": null, "content": {"content_type": "text", "parts": ["Hello there"]}}, "parent": "q", "children": []}
+  }
+}`
+
+func TestImportChatGPTConversation(t *testing.T) {
+	var conversation chatGPTConversation
+	require.NoError(t, json.Unmarshal([]byte(chatGPTConversationJson), &conversation))
+
+	user := &model.User{ID: uuid.New()}
+	imported, err := importChatGPTConversation(&conversation, user)
+	require.NoError(t, err)
+	require.Equal(t, "Greetings", imported.chat.Title)
+	require.Equal(t, time.Unix(1700000000, 5e8).UTC(), imported.chat.CreatedAt)
+
+	require.Len(t, imported.messages, 2)
+	require.Equal(t, "Hello", imported.messages[0].Content)
+	require.Equal(t, "Hello there", imported.messages[1].Content)
+	// the reply without a timestamp takes the one of the question
+	require.Equal(t, time.Unix(1700000001, 0).UTC(), imported.messages[1].CreatedAt)
+
+	conversation.Mapping["q"] = chatGPTNode{Message: conversation.Mapping["q"].Message, Parent: lo.ToPtr("b2")}
+	_, err = importChatGPTConversation(&conversation, user)
+	require.Error(t, err)
+}
+
+func TestImportChatExport(t *testing.T) {
+	at := time.Date(2024, 5, 1, 10, 30, 0, 0, time.UTC)
+	chat := &model.Chat{ID: uuid.New(), Title: "Exported", CreatedAt: at}
+	first, second := uuid.New(), uuid.New()
+	messages := []*model.Message{
+		{ID: uuid.New(), Role: "user", Content: "question", CreatedAt: at},
+		{ID: first, Role: "assistant", Content: "first", CreatedAt: at, Metadata: common.Metadata{model.MetadataSupersededBy: second.String()}},
+		{ID: second, Role: "assistant", Content: "second", CreatedAt: at, Metadata: common.Metadata{
+			model.MetadataRegeneratedFrom: first.String(),
+			model.MetadataCitations:       model.EncodeCitations([]*model.Citation{{FileName: "notes.md", Excerpt: "notes"}}),
+		}},
+	}
+
+	imported, err := importChatExport(newChatExport(chat, messages, at), &model.User{ID: uuid.New()})
+	require.NoError(t, err)
+	require.Len(t, imported.messages, 3)
+	require.Equal(t, at, imported.messages[0].CreatedAt)
+	require.NotEqual(t, second, imported.messages[2].ID)
+	require.Equal(t, imported.messages[2].ID.String(), imported.messages[1].Metadata[model.MetadataSupersededBy])
+	require.Equal(t, imported.messages[1].ID.String(), imported.messages[2].Metadata[model.MetadataRegeneratedFrom])
+	require.Equal(t, "notes.md", imported.messages[2].Citations()[0].FileName)
+}
+
+func TestImportChatExport_MissingAndRepeatedIds(t *testing.T) {
+	export := &model.ChatExport{
+		Version: model.ChatExportVersion,
+		Title:   "Edited",
+		Messages: []*model.ChatExportMessage{
+			{Role: "user", Content: "question"},
+			{Role: "assistant", Content: "answer"},
+			{ID: "a", Role: "user", Content: "again"},
+			{ID: "a", Role: "assistant", Content: "first", Metadata: common.Metadata{model.MetadataSupersededBy: "b"}},
+			{ID: "b", Role: "assistant", Content: "second", Metadata: common.Metadata{model.MetadataRegeneratedFrom: "a"}},
+		},
+	}
+
+	imported, err := importChatExport(export, &model.User{ID: uuid.New()})
+	require.NoError(t, err)
+	require.Len(t, imported.messages, 5)
+	ids := lo.Uniq(lo.Map(imported.messages, func(m *model.Message, _ int) uuid.UUID { return m.ID }))
+	require.Len(t, ids, 5)
+	// "a" is ambiguous, so it can not be referenced
+	require.NotContains(t, imported.messages[4].Metadata, model.MetadataRegeneratedFrom)
+	require.Equal(t, imported.messages[4].ID.String(), imported.messages[3].Metadata[model.MetadataSupersededBy])
+}
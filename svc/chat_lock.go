@@ -0,0 +1,71 @@
+package svc
+
+import (
+	"context"
+	"time"
+
+	"github.com/amahdian/ai-assistant-be/global/errs"
+	"github.com/amahdian/ai-assistant-be/pkg/logger"
+)
+
+const (
+	// generationLockTimeout is how long a chat stays locked by a reply that was never saved,
+	// e.g. because its instance went down. It outlives the generations.
+	generationLockTimeout = generationTimeout + time.Minute
+	// stopRequestPollInterval is how often a generation checks whether it was asked to stop on another instance.
+	stopRequestPollInterval = 2 * time.Second
+)
+
+// lockChat makes the message the only reply being generated in the chat across all the instances,
+// so the messages of concurrent sends can not interleave. The chat must be unlocked once the reply is saved.
+func (s *chatSvc) lockChat(chatID, messageID string) error {
+	locked, err := s.stg.Chat(s.ctx).LockGeneration(chatID, messageID, time.Now().Add(-generationLockTimeout))
+	if err != nil {
+		return errs.Wrapf(err, "failed to lock chat")
+	}
+	if !locked {
+		return errs.Newf(errs.FailedPrecondition, nil, "A reply is still being generated in chat %q, wait for it or stop it.", chatID)
+	}
+	return nil
+}
+
+// unlockChat lets the next message be sent to the chat. It uses its own context since the chat
+// must be unlocked even when the request that locked it went away.
+func (s *chatSvc) unlockChat(ctx context.Context, chatID, messageID string) {
+	if err := s.stg.Chat(ctx).UnlockGeneration(chatID, messageID); err != nil {
+		logger.Errorf("failed to unlock chat %s after message %s: %v", chatID, messageID, err)
+	}
+}
+
+// requestStop asks the instance generating the reply in the chat to stop it, see watchStopRequests.
+// It reports whether the chat is generating the reply.
+func (s *chatSvc) requestStop(chatID, messageID string) (bool, error) {
+	requested, err := s.stg.Chat(s.ctx).RequestGenerationStop(chatID, messageID, time.Now().Add(-generationLockTimeout))
+	if err != nil {
+		return false, errs.Wrapf(err, "failed to request the generation stop")
+	}
+	return requested, nil
+}
+
+// watchStopRequests stops the generation of the message once another instance requested it,
+// until ctx is done.
+func (s *chatSvc) watchStopRequests(ctx context.Context, chatID, messageID string) {
+	ticker := time.NewTicker(stopRequestPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			requested, err := s.stg.Chat(ctx).GenerationStopRequested(chatID, messageID)
+			if err != nil {
+				logger.Errorf("failed to check the stop requests of message %s: %v", messageID, err)
+				continue
+			}
+			if requested {
+				s.generations.stop(chatID, messageID)
+				return
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
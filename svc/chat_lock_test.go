@@ -0,0 +1,80 @@
+package svc
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/amahdian/ai-assistant-be/domain/model"
+	"github.com/amahdian/ai-assistant-be/global/errs"
+	"github.com/amahdian/ai-assistant-be/storage"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+type lockStorage struct {
+	storage.Storage
+	chats *lockChatStg
+}
+
+func (s *lockStorage) Chat(context.Context) storage.ChatStorage {
+	return s.chats
+}
+
+// lockChatStg is a chat generating a reply, possibly on another instance.
+type lockChatStg struct {
+	storage.ChatStorage
+	chat *model.Chat
+
+	mu            sync.Mutex
+	generationId  string
+	stopRequested bool
+}
+
+func (s *lockChatStg) FindById(string) (*model.Chat, error) {
+	return s.chat, nil
+}
+
+func (s *lockChatStg) RequestGenerationStop(_, messageId string, _ time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.generationId == "" || (messageId != "" && messageId != s.generationId) {
+		return false, nil
+	}
+	s.stopRequested = true
+	return true, nil
+}
+
+func (s *lockChatStg) GenerationStopRequested(_, messageId string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.stopRequested && messageId == s.generationId, nil
+}
+
+func TestStopGeneration_OnAnotherInstance(t *testing.T) {
+	user := &model.User{ID: uuid.New()}
+	chat := &model.Chat{ID: uuid.New(), UserId: user.ID.String()}
+	chatID := chat.ID.String()
+	chats := &lockChatStg{chat: chat, generationId: "msg"}
+	stg := &lockStorage{chats: chats}
+
+	// the instance generating the reply
+	generating := &chatSvc{ctx: context.Background(), stg: stg, generations: newGenerationBroker()}
+	ctx, cancel := context.WithCancelCause(context.Background())
+	defer cancel(nil)
+	generating.generations.start(chatID, "msg", cancel)
+	go generating.watchStopRequests(ctx, chatID, "msg")
+
+	// the instance handling the stop request
+	other := &chatSvc{ctx: context.Background(), stg: stg, generations: newGenerationBroker()}
+	require.Equal(t, errs.NotFound, errs.Code(other.StopGeneration(chatID, "another", user)))
+	require.NoError(t, other.StopGeneration(chatID, "msg", user))
+
+	select {
+	case <-ctx.Done():
+		require.ErrorIs(t, context.Cause(ctx), errGenerationStopped)
+	case <-time.After(3 * stopRequestPollInterval):
+		t.Fatal("the generation was not stopped")
+	}
+}
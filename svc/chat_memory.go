@@ -0,0 +1,181 @@
+package svc
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/amahdian/ai-assistant-be/domain/model"
+	"github.com/amahdian/ai-assistant-be/domain/model/common"
+	"github.com/amahdian/ai-assistant-be/global/errs"
+	"github.com/amahdian/ai-assistant-be/pkg/logger"
+	"github.com/google/uuid"
+	"github.com/samber/lo"
+)
+
+const (
+	// memoryExtractionTimeout bounds learning the memories of an exchange.
+	memoryExtractionTimeout = 30 * time.Second
+	// memoryExchangeLength is the length in runes of the question and the reply given to the model.
+	memoryExchangeLength = 2000
+	// maxMemoryLength is the maximum length of a memory in runes, longer ones are not facts but summaries.
+	maxMemoryLength = 300
+	// maxExchangeMemories bounds the memories learned from a single exchange.
+	maxExchangeMemories = 5
+	// duplicateMemorySimilarity is the similarity above which a memory restates a known one and replaces it.
+	duplicateMemorySimilarity = 0.85
+	// promptMemories is the maximum number of memories added to a prompt.
+	promptMemories = 5
+	// relevantMemorySimilarity is the minimum similarity to the user's message of the memories added to a prompt.
+	relevantMemorySimilarity = 0.25
+	// lastMessageEmbeddingTimeout bounds embedding the user's message before the reply starts,
+	// the message is answered without the memories and the project documents once it is exceeded.
+	lastMessageEmbeddingTimeout = 2 * time.Second
+)
+
+const memoryExtractionPrompt = "You maintain the long-term memory of an assistant about its user. " +
+	"Extract the durable facts about the user which will still be useful in future conversations, " +
+	"e.g. their preferences, their circumstances and the tools or projects they work with. " +
+	"Write every fact as a short standalone sentence about the user, e.g. \"The user is vegetarian.\". " +
+	"Ignore questions, one-off requests, facts about other people or the world, what the assistant said " +
+	"and sensitive details like health or finances unless the user asks to remember them. " +
+	"Return an empty list when there is nothing worth remembering."
+
+// rememberExchange learns the memories of the user from the exchange in the background.
+// Failures are only logged, the reply is not affected.
+func (s *chatSvc) rememberExchange(chat *model.Chat, question, reply *model.Message, settings *model.UserSettings) {
+	if !settings.MemoryEnabled || question == nil || strings.TrimSpace(reply.Content) == "" {
+		return
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(s.ctx), memoryExtractionTimeout)
+		defer cancel()
+		if err := s.learnMemories(ctx, chat, question, reply); err != nil {
+			logger.Errorf("failed to learn memories from chat %s: %v", chat.ID, err)
+		}
+	}()
+}
+
+// learnMemories extracts the memories of the exchange and saves them. A memory restating a known one
+// replaces it, so the memories are kept free of duplicates and the newer statement of a fact wins.
+func (s *chatSvc) learnMemories(ctx context.Context, chat *model.Chat, question, reply *model.Message) error {
+	exchange := fmt.Sprintf("user: %s\nassistant: %s",
+		truncateRunes(question.Content, memoryExchangeLength), truncateRunes(reply.Content, memoryExchangeLength))
+	prompt := []*model.Message{
+		{Role: "system", Content: memoryExtractionPrompt},
+		{Role: "user", Content: fmt.Sprintf("Extract the facts worth remembering from this exchange:\n%s", exchange)},
+	}
+	extracted, err := s.gptClient.ExtractMemories(ctx, prompt)
+	if err != nil {
+		return errs.Wrapf(err, "failed to extract memories")
+	}
+	contents := cleanMemories(extracted)
+	if len(contents) == 0 {
+		return nil
+	}
+	vectors, err := s.embeddings.embedTexts(ctx, contents)
+	if err != nil {
+		return err
+	}
+
+	memories := s.stg.Memory(ctx)
+	for i, content := range contents {
+		memory := &model.Memory{
+			UserId:          chat.UserId,
+			Content:         content,
+			Embedding:       vectors[i],
+			SourceChatId:    lo.ToPtr(chat.ID.String()),
+			SourceMessageId: lo.ToPtr(question.ID.String()),
+		}
+		nearest, err := memories.SearchByUserId(chat.UserId, vectors[i], 1)
+		if err != nil {
+			return errs.Wrapf(err, "failed to search memories")
+		}
+		if len(nearest) > 0 && nearest[0].Similarity >= duplicateMemorySimilarity {
+			memory.ID = nearest[0].ID
+			err = memories.UpdateOne(memory, false)
+		} else {
+			err = memories.CreateOne(memory)
+		}
+		if err != nil {
+			return errs.Wrapf(err, "failed to save memory")
+		}
+	}
+	return nil
+}
+
+// cleanMemories drops the blank, too long and repeated memories and keeps at most maxExchangeMemories of them.
+func cleanMemories(memories []string) []string {
+	cleaned := make([]string, 0, len(memories))
+	seen := make(map[string]bool)
+	for _, memory := range memories {
+		memory = strings.TrimSpace(memory)
+		key := strings.ToLower(memory)
+		if memory == "" || len([]rune(memory)) > maxMemoryLength || seen[key] {
+			continue
+		}
+		seen[key] = true
+		cleaned = append(cleaned, memory)
+		if len(cleaned) == maxExchangeMemories {
+			break
+		}
+	}
+	return cleaned
+}
+
+// relevantMemories returns the memories of the user close enough to the vector of their message.
+// Failures are only logged, the message is still answered without the memories.
+func (s *chatSvc) relevantMemories(userId string, vector common.Vector) []*model.Memory {
+	if vector == nil {
+		return nil
+	}
+	memories, err := s.stg.Memory(s.ctx).SearchByUserId(userId, vector, promptMemories)
+	if err != nil {
+		logger.Errorf("failed to search memories of user %s: %v", userId, err)
+		return nil
+	}
+	return lo.Filter(memories, func(m *model.Memory, _ int) bool { return m.Similarity >= relevantMemorySimilarity })
+}
+
+// lastMessageVector returns the embedding of the last user message, nil if there is none or it failed.
+// It gives up after lastMessageEmbeddingTimeout, so a slow embedding never holds the reply back for long.
+func (s *chatSvc) lastMessageVector(chat *model.Chat, messages []*model.Message) common.Vector {
+	lastUserMessage, _, ok := lo.FindLastIndexOf(messages, func(m *model.Message) bool { return m.Role == "user" })
+	if !ok {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(s.ctx, lastMessageEmbeddingTimeout)
+	defer cancel()
+	vector, err := s.embeddings.embedQuery(ctx, lastUserMessage.Content)
+	if err != nil {
+		logger.Warnf("failed to embed the last message of chat %s, it is answered without memories and documents: %v", chat.ID, err)
+		return nil
+	}
+	return vector
+}
+
+func (s *userSvc) ListMemories(user *model.User) ([]*model.Memory, error) {
+	memories, err := s.stg.Memory(s.ctx).ListByUserId(user.ID.String())
+	if err != nil {
+		return nil, errs.Wrapf(err, "failed to list memories")
+	}
+	return memories, nil
+}
+
+func (s *userSvc) DeleteMemory(id string, user *model.User) error {
+	if _, err := uuid.Parse(id); err != nil {
+		return errs.Newf(errs.InvalidArgument, err, "Invalid memory id %q.", id)
+	}
+	if err := s.stg.Memory(s.ctx).DeleteByUserId(user.ID.String(), id); err != nil {
+		return errs.Wrapf(err, "failed to delete memory %s", id)
+	}
+	return nil
+}
+
+func (s *userSvc) DeleteMemories(user *model.User) error {
+	if err := s.stg.Memory(s.ctx).DeleteAllByUserId(user.ID.String()); err != nil {
+		return errs.Wrapf(err, "failed to delete memories")
+	}
+	return nil
+}
@@ -0,0 +1,183 @@
+package svc
+
+import (
+	"context"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/amahdian/ai-assistant-be/domain/model"
+	"github.com/amahdian/ai-assistant-be/domain/model/common"
+	"github.com/amahdian/ai-assistant-be/storage"
+	"github.com/google/uuid"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryStorage only has the memories of the users.
+type memoryStorage struct {
+	storage.Storage
+	memories *memoryStg
+}
+
+func (s *memoryStorage) Memory(context.Context) storage.MemoryStorage {
+	return s.memories
+}
+
+// memoryStg keeps the memories of the users in a slice.
+// Only the methods used to learn and recall the memories are implemented, the others panic.
+type memoryStg struct {
+	storage.MemoryStorage
+
+	mu       sync.Mutex
+	memories []*model.Memory
+}
+
+func (stg *memoryStg) CreateOne(memory *model.Memory) error {
+	stg.mu.Lock()
+	defer stg.mu.Unlock()
+
+	if memory.ID == uuid.Nil {
+		memory.ID = uuid.New()
+	}
+	memory.CreatedAt = time.Now()
+	memory.UpdatedAt = memory.CreatedAt
+	saved := *memory
+	stg.memories = append(stg.memories, &saved)
+	return nil
+}
+
+func (stg *memoryStg) UpdateOne(memory *model.Memory, _ bool) error {
+	stg.mu.Lock()
+	defer stg.mu.Unlock()
+
+	for i, m := range stg.memories {
+		if m.ID == memory.ID {
+			memory.CreatedAt = m.CreatedAt
+			memory.UpdatedAt = time.Now()
+			saved := *memory
+			stg.memories[i] = &saved
+		}
+	}
+	return nil
+}
+
+func (stg *memoryStg) ListByUserId(userId string) ([]*model.Memory, error) {
+	stg.mu.Lock()
+	defer stg.mu.Unlock()
+
+	memories := make([]*model.Memory, 0)
+	for _, m := range stg.memories {
+		if m.UserId == userId {
+			memory := *m
+			memories = append(memories, &memory)
+		}
+	}
+	sort.SliceStable(memories, func(i, j int) bool {
+		return memories[i].UpdatedAt.After(memories[j].UpdatedAt)
+	})
+	return memories, nil
+}
+
+func (stg *memoryStg) SearchByUserId(userId string, vector common.Vector, limit int) ([]*model.Memory, error) {
+	memories, _ := stg.ListByUserId(userId)
+	for _, m := range memories {
+		m.Similarity = cosineSimilarity(vector, m.Embedding)
+	}
+	sort.SliceStable(memories, func(i, j int) bool {
+		return memories[i].Similarity > memories[j].Similarity
+	})
+	if len(memories) > limit {
+		memories = memories[:limit]
+	}
+	return memories, nil
+}
+
+func cosineSimilarity(a, b common.Vector) float64 {
+	if len(a) != len(b) {
+		return 0
+	}
+	var dot, normA, normB float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
+
+// memoryClient embeds texts by topic and extracts the facts it was given.
+type memoryClient struct {
+	topicEmbeddingClient
+	facts []string
+}
+
+func (c *memoryClient) ExtractMemories(context.Context, []*model.Message) ([]string, error) {
+	return c.facts, nil
+}
+
+func TestCleanMemories(t *testing.T) {
+	memories := cleanMemories([]string{
+		" The user is vegetarian. ",
+		"",
+		"the user is vegetarian.",
+		strings.Repeat("a", maxMemoryLength+1),
+		"The user's project uses Go 1.24.",
+	})
+	require.Equal(t, []string{"The user is vegetarian.", "The user's project uses Go 1.24."}, memories)
+
+	many := make([]string, 0, maxExchangeMemories+2)
+	for i := 0; i < maxExchangeMemories+2; i++ {
+		many = append(many, strings.Repeat("x", i+1))
+	}
+	require.Len(t, cleanMemories(many), maxExchangeMemories)
+}
+
+func TestLearnAndRecallMemories(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	client := &memoryClient{topicEmbeddingClient: topicEmbeddingClient{topics: []string{"berlin", "vegetarian", "go"}}}
+	stg := &memoryStorage{memories: &memoryStg{}}
+	s := &chatSvc{
+		ctx:        ctx,
+		stg:        stg,
+		gptClient:  client,
+		embeddings: newEmbeddingIndexer(ctx, client, nil),
+	}
+	chat := &model.Chat{ID: uuid.New(), UserId: "user-a"}
+	question := &model.Message{ID: uuid.New(), Role: "user", Content: "question"}
+	reply := &model.Message{ID: uuid.New(), Role: "assistant", Content: "reply"}
+	learn := func(facts ...string) {
+		client.facts = facts
+		require.NoError(t, s.learnMemories(ctx, chat, question, reply))
+	}
+
+	learn("The user lives in Berlin.", "The user is vegetarian.")
+	// restating a known fact replaces it instead of adding a duplicate
+	learn("The user moved to Berlin last year.")
+	learn("The user writes Go at work.")
+
+	memories, err := stg.memories.ListByUserId("user-a")
+	require.NoError(t, err)
+	contents := lo.Map(memories, func(m *model.Memory, _ int) string { return m.Content })
+	require.ElementsMatch(t, []string{"The user moved to Berlin last year.", "The user is vegetarian.", "The user writes Go at work."}, contents)
+
+	// only the memories close enough to the message are recalled, the closest first
+	query, err := s.embeddings.embedQuery(ctx, "vegetarian restaurants in berlin")
+	require.NoError(t, err)
+	relevant := s.relevantMemories("user-a", query)
+	require.Len(t, relevant, 2)
+	require.GreaterOrEqual(t, relevant[0].Similarity, relevant[1].Similarity)
+	require.ElementsMatch(t, []string{"The user moved to Berlin last year.", "The user is vegetarian."},
+		lo.Map(relevant, func(m *model.Memory, _ int) string { return m.Content }))
+
+	require.Empty(t, s.relevantMemories("user-b", query))
+	require.Nil(t, s.relevantMemories("user-a", nil))
+}
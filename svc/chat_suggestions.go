@@ -0,0 +1,100 @@
+package svc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/amahdian/ai-assistant-be/domain/model"
+	"github.com/amahdian/ai-assistant-be/pkg/logger"
+	"github.com/samber/lo"
+)
+
+const (
+	// minSuggestions and maxSuggestions bound the number of follow-up questions of the agents.
+	minSuggestions = 2
+	maxSuggestions = 4
+	// maxSuggestionLength is the maximum length of a follow-up question in runes.
+	maxSuggestionLength = 200
+	// suggestionExchangeLength is the length in runes of the question and the reply given to the model.
+	suggestionExchangeLength = 2000
+	// suggestionTimeout bounds how long the subscribers of a reply wait for its follow-up questions.
+	suggestionTimeout = 15 * time.Second
+)
+
+// saveSuggestions stores the follow-up questions of the saved reply in its metadata and returns them as a JSON array.
+// Since the suggestions are optional it returns an empty string when the agent of the chat has them disabled
+// or when they could not be created or stored, the reply is left as is.
+func (s *chatSvc) saveSuggestions(ctx context.Context, chat *model.Chat, messages []*model.Message, messageID, reply string,
+	settings *model.UserSettings) string {
+	suggestions, err := s.suggestFollowUps(ctx, chat, messages, reply, settings)
+	if err != nil {
+		logger.Errorf("failed to suggest follow-up questions for chat %s: %v", chat.ID, err)
+		return ""
+	}
+	if len(suggestions) == 0 {
+		return ""
+	}
+	encoded, err := json.Marshal(suggestions)
+	if err != nil {
+		logger.Errorf("failed to encode follow-up questions for chat %s: %v", chat.ID, err)
+		return ""
+	}
+	if err = s.stg.Message(ctx).SetMetadata(messageID, model.MetadataSuggestions, string(encoded)); err != nil {
+		logger.Errorf("failed to save follow-up questions of message %s: %v", messageID, err)
+		return ""
+	}
+	return string(encoded)
+}
+
+// suggestFollowUps asks the cheaper model for the questions the user could ask after the reply.
+func (s *chatSvc) suggestFollowUps(ctx context.Context, chat *model.Chat, messages []*model.Message, reply string,
+	settings *model.UserSettings) ([]string, error) {
+	agent, ok := model.FindAgent(chat.Agent)
+	if !ok {
+		agent = model.DefaultAgent
+	}
+	if agent.Suggestions <= 0 || strings.TrimSpace(reply) == "" {
+		return nil, nil
+	}
+	count := min(max(agent.Suggestions, minSuggestions), maxSuggestions)
+
+	exchange := fmt.Sprintf("assistant: %s", truncateRunes(reply, suggestionExchangeLength))
+	if question, _, ok := lo.FindLastIndexOf(messages, func(m *model.Message) bool { return m.Role == "user" }); ok {
+		exchange = fmt.Sprintf("user: %s\n%s", truncateRunes(question.Content, suggestionExchangeLength), exchange)
+	}
+	language := "the language of the user"
+	if settings.Language != "" {
+		language = settings.Language
+	}
+	prompt := []*model.Message{
+		{Role: "system", Content: "You suggest short follow-up questions the user may want to ask the assistant next."},
+		{Role: "user", Content: fmt.Sprintf("Suggest %d short follow-up questions, in %s, for this exchange:\n%s", count, language, exchange)},
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, suggestionTimeout)
+	defer cancel()
+	suggestions, err := s.gptClient.SuggestFollowUps(ctx, prompt)
+	if err != nil {
+		return nil, err
+	}
+	return cleanSuggestions(suggestions, count), nil
+}
+
+// cleanSuggestions drops the blank and repeated suggestions and keeps at most count of them.
+// Less than minSuggestions are not worth showing, so they are dropped too.
+func cleanSuggestions(suggestions []string, count int) []string {
+	suggestions = lo.Uniq(lo.FilterMap(suggestions, func(suggestion string, _ int) (string, bool) {
+		suggestion = strings.TrimSpace(suggestion)
+		return truncateRunes(suggestion, maxSuggestionLength), suggestion != ""
+	}))
+	if len(suggestions) < minSuggestions {
+		return nil
+	}
+	if len(suggestions) > count {
+		suggestions = suggestions[:count]
+	}
+	return suggestions
+}
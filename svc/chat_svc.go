@@ -2,125 +2,450 @@ package svc
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/amahdian/ai-assistant-be/clients"
 	"github.com/amahdian/ai-assistant-be/domain/model"
 	"github.com/amahdian/ai-assistant-be/domain/model/common"
 	"github.com/amahdian/ai-assistant-be/global/errs"
+	"github.com/amahdian/ai-assistant-be/pkg/fileutil"
 	"github.com/amahdian/ai-assistant-be/pkg/logger"
+	"github.com/amahdian/ai-assistant-be/pkg/msg"
 	"github.com/amahdian/ai-assistant-be/storage"
+	"github.com/google/uuid"
+	"github.com/samber/lo"
+	"strings"
+	"time"
+)
+
+const (
+	// projectDocumentChunks is the number of project document excerpts added to the prompts.
+	projectDocumentChunks = 5
+	// maxCitationExcerptLength is the maximum length of the excerpts kept in the citations of a reply.
+	maxCitationExcerptLength = 300
 )
 
 // ChatSvc defines the interface for chat-related services.
 type ChatSvc interface {
+	// DeleteChat and DeleteChats move the chats to the trash, they are purged after the retention period.
 	DeleteChat(chatID string, user *model.User) error
-	CreateChat(message string, user *model.User) (*model.Chat, error)
-	SendMessage(chatID, message string, user *model.User) (*model.Message, error)
-	SendMessageStream(chatID, message string, user *model.User) (<-chan *model.StreamedMessage, error)
-	ListChats(user *model.User) ([]*model.Chat, error)
+	DeleteChats(chatIDs []string, user *model.User) error
+	ListDeletedChats(pagination *common.CursorPagination, user *model.User) ([]*model.Chat, error)
+	RestoreChat(chatID string, user *model.User) (*model.Chat, error)
+	// CreateChat, CreateChatStream, SendMessage and SendMessageStream run once per non-empty idempotency key,
+	// their retries with the same key replay the original result.
+	CreateChat(message, projectID, idempotencyKey string, user *model.User) (*model.Chat, error)
+	CreateChatStream(message, projectID, idempotencyKey string, user *model.User) (*model.Chat, <-chan *model.StreamedMessage, error)
+	RenameChat(chatID, title string, user *model.User) (*model.Chat, error)
+	RegenerateTitle(chatID string, user *model.User) error
+	SendMessage(chatID, message, idempotencyKey string, user *model.User) (*model.Message, error)
+	SendMessageStream(chatID, message, idempotencyKey string, user *model.User) (<-chan *model.StreamedMessage, error)
+	SendMessageAsync(chatID, message string, user *model.User) (messageID string, err error)
+	Regenerate(chatID string, user *model.User) (messageID string, err error)
+	SubscribeStream(chatID, lastEventID string, user *model.User) (<-chan *model.StreamedMessage, error)
+	StopGeneration(chatID, messageID string, user *model.User) error
+	WatchChats(user *model.User) (events <-chan *model.ChatEvent, unsubscribe func())
+	NotifyTyping(chatID string, user *model.User) error
+	ListChats(filter *model.ChatFilter, pagination *common.CursorPagination, user *model.User) ([]*model.Chat, error)
+	SetPinned(chatID string, pinned bool, user *model.User) error
+	SetArchived(chatID string, archived bool, user *model.User) error
 	GetChat(id string, user *model.User) (*model.Chat, error)
+	ListMessages(chatID string, pagination *common.CursorPagination, user *model.User) ([]*model.Message, error)
+	ExportChat(chatID string, format model.ChatExportFormat, user *model.User) (*fileutil.File, error)
+	ImportChats(file *fileutil.File, user *model.User) ([]*model.Chat, *msg.MessageContainer, error)
+	ForkChat(chatID, messageID string, user *model.User) (*model.Chat, error)
 }
 
 type chatSvc struct {
-	ctx       context.Context
-	stg       storage.Storage
-	gptClient clients.GPTClient
+	ctx            context.Context
+	stg            storage.Storage
+	gptClient      clients.GPTClient
+	generations    *generationBroker
+	events         *eventHub
+	embeddings     *embeddingIndexer
+	idempotencyTTL time.Duration
 }
 
-func newChatSvc(ctx context.Context, stg storage.Storage, gptClient clients.GPTClient) ChatSvc {
+func newChatSvc(ctx context.Context, stg storage.Storage, gptClient clients.GPTClient, generations *generationBroker, events *eventHub, embeddings *embeddingIndexer, idempotencyTTL time.Duration) ChatSvc {
 	return &chatSvc{
-		ctx:       ctx,
-		stg:       stg,
-		gptClient: gptClient,
+		ctx:            ctx,
+		stg:            stg,
+		gptClient:      gptClient,
+		generations:    generations,
+		events:         events,
+		embeddings:     embeddings,
+		idempotencyTTL: idempotencyTTL,
+	}
+}
+
+func (s *chatSvc) SendMessage(chatID, message, idempotencyKey string, user *model.User) (*model.Message, error) {
+	record, claimed, err := s.acquireKey(idempotencyKey, requestFingerprint("send", chatID, message), user)
+	if err != nil {
+		return nil, err
 	}
+	if !claimed {
+		return s.replayMessage(record)
+	}
+
+	reply, err := s.sendMessage(chatID, message, user)
+	if err != nil {
+		s.releaseKey(record)
+		return nil, err
+	}
+	s.completeKey(record, chatID, reply.ID.String(), reply)
+	return reply, nil
 }
 
-func (s *chatSvc) SendMessage(chatID, message string, user *model.User) (*model.Message, error) {
-	// 1. Validate chat ownership and save user message
-	chat, err := s.prepareMessage(chatID, message, user)
+func (s *chatSvc) sendMessage(chatID, message string, user *model.User) (*model.Message, error) {
+	// 1. Validate chat ownership and lock the chat for the reply
+	replyID := uuid.New()
+	chat, err := s.prepareMessage(chatID, replyID.String(), user)
 	if err != nil {
 		return nil, err
 	}
+	// the turn is saved even if the client goes away, saving it unlocks the chat
+	saveCtx := context.WithoutCancel(s.ctx)
+	defer s.unlockChat(saveCtx, chatID, replyID.String())
 
-	// 2. Get conversation history
+	// 2. Get conversation history, the user message is only saved together with the reply
+	userMessage := newUserMessage(chatID, message)
 	messages, err := s.stg.Message(s.ctx).ListByChatId(chatID)
 	if err != nil {
 		return nil, errs.Wrapf(err, "failed to list messages")
 	}
+	messages = append(activeMessages(messages), userMessage)
 
-	agent := model.DefaultAgent
-
-	// 3. Check for summarization
-	chatSummary := s.checkAndSummarizeIfNeeded(messages)
+	settings := s.userSettings(chat)
+	// 3. Build the system prompt and check for summarization
+	systemPrompt, citations, chatSummary := s.promptContext(chat, messages, settings)
 
-	var reply string
+	replyModel := chatModel(settings)
+	reply, gptErr := s.gptClient.SendToGPT(replyModel, systemPrompt, chatSummary, messages)
 
-	reply, err = s.gptClient.SendToGPT(agent.SystemPrompt, chatSummary, messages)
-	if err != nil {
-		return nil, errs.Wrapf(err, "failed to get GPT response")
+	// 4. Build the assistant's message
+	assistantMessage := &model.Message{
+		ID:       replyID,
+		ChatID:   chatID,
+		Role:     "assistant",
+		Content:  reply,
+		Status:   model.MessageStatusCompleted,
+		Metadata: withCitations(common.Metadata{model.MetadataModel: replyModel}, citations),
+		Chat:     chat,
+	}
+	if gptErr != nil {
+		// the failed reply is saved like the ones of the failed streams, so the message can be regenerated
+		assistantMessage.Content = ""
+		assistantMessage.Status = model.MessageStatusFailed
 	}
 
-	// 5. Save the assistant's message
-	assistantMessage := &model.Message{
-		ChatID:  chatID,
-		Role:    "assistant",
-		Content: reply,
-		Chat:    chat,
+	// 5. Save the user's and the assistant's messages
+	if err = s.saveTurn(saveCtx, nil, userMessage, assistantMessage); err != nil {
+		return nil, err
 	}
+	s.embeddings.enqueue(userMessage, user.ID.String())
+	s.embeddings.enqueue(assistantMessage, user.ID.String())
+	s.titleIfPlaceholder(chat, user)
 
-	if err = s.stg.Message(s.ctx).CreateOne(assistantMessage); err != nil {
-		return nil, errs.Wrapf(err, "failed to save assistant message")
+	if gptErr != nil {
+		return nil, errs.Wrapf(gptErr, "failed to get GPT response")
 	}
+	s.rememberExchange(chat, userMessage, assistantMessage, settings)
+
+	// 6. Suggest the follow-up questions, they are stored with the reply once ready
+	go s.saveSuggestions(saveCtx, chat, messages, replyID.String(), reply, settings)
 
 	return assistantMessage, nil
 }
 
-func (s *chatSvc) SendMessageStream(chatID, message string, user *model.User) (<-chan *model.StreamedMessage, error) {
-	_, err := s.prepareMessage(chatID, message, user)
+func (s *chatSvc) SendMessageStream(chatID, message, idempotencyKey string, user *model.User) (<-chan *model.StreamedMessage, error) {
+	record, claimed, err := s.acquireKey(idempotencyKey, requestFingerprint("send", chatID, message), user)
+	if err != nil {
+		return nil, err
+	}
+	if !claimed {
+		return s.replayStream(record)
+	}
+
+	gen, err := s.sendMessageAsync(chatID, message, user)
 	if err != nil {
+		s.releaseKey(record)
 		return nil, err
 	}
+	s.completeKey(record, chatID, gen.messageID, nil)
+	return gen.subscribe(s.ctx, 0), nil
+}
+
+func (s *chatSvc) SendMessageAsync(chatID, message string, user *model.User) (string, error) {
+	gen, err := s.sendMessageAsync(chatID, message, user)
+	if err != nil {
+		return "", err
+	}
+	return gen.messageID, nil
+}
+
+func (s *chatSvc) sendMessageAsync(chatID, message string, user *model.User) (*generation, error) {
+	replyID := uuid.New()
+	chat, err := s.prepareMessage(chatID, replyID.String(), user)
+	if err != nil {
+		return nil, err
+	}
+
+	// the user message is saved upfront, so the other devices see it while the reply is generated
+	userMessage := newUserMessage(chatID, message)
+	if err = s.stg.Message(s.ctx).CreateOne(userMessage); err != nil {
+		s.unlockChat(context.WithoutCancel(s.ctx), chatID, replyID.String())
+		return nil, errs.Wrapf(err, "failed to save user message")
+	}
+	s.embeddings.enqueue(userMessage, user.ID.String())
+	s.titleIfPlaceholder(chat, user)
 
 	messages, err := s.stg.Message(s.ctx).ListByChatId(chatID)
 	if err != nil {
+		s.failReply(chatID, replyID)
 		return nil, errs.Wrapf(err, "failed to list messages")
 	}
 
-	agent := model.DefaultAgent
-	chatSummary := s.checkAndSummarizeIfNeeded(messages)
+	gen, err := s.startGeneration(chat, user, replyID, activeMessages(messages), nil)
+	if err != nil {
+		s.failReply(chatID, replyID)
+		return nil, err
+	}
+	return gen, nil
+}
+
+// Regenerate replaces the last assistant reply of the chat with a new generation.
+// The previous reply is kept as a superseded sibling of the new one once the new one is saved.
+func (s *chatSvc) Regenerate(chatID string, user *model.User) (string, error) {
+	chat, err := s.stg.Chat(s.ctx).FindById(chatID)
+	if err != nil {
+		return "", errs.Wrapf(err, "failed to find chat")
+	}
+	if chat.UserId != user.ID.String() {
+		return "", errors.New("permission denied")
+	}
+
+	// the history is read under the lock, so the reply it ends with can not change meanwhile
+	replyID := uuid.New()
+	if err = s.lockChat(chatID, replyID.String()); err != nil {
+		return "", err
+	}
+	var gen *generation
+	defer func() {
+		// once started, the generation unlocks the chat
+		if gen == nil {
+			s.unlockChat(context.WithoutCancel(s.ctx), chatID, replyID.String())
+		}
+	}()
+
+	messages, err := s.stg.Message(s.ctx).ListByChatId(chatID)
+	if err != nil {
+		return "", errs.Wrapf(err, "failed to list messages")
+	}
+	// the previous reply may be a failed one, it is superseded like any other
+	messages = lo.Reject(messages, func(m *model.Message, _ int) bool { return superseded(m) })
+
+	var previous *model.Message
+	if n := len(messages); n > 0 && messages[n-1].Role == "assistant" {
+		previous = messages[n-1]
+		messages = messages[:n-1]
+	}
+	messages = activeMessages(messages)
+	if n := len(messages); n == 0 || messages[n-1].Role != "user" {
+		return "", errs.Newf(errs.FailedPrecondition, nil, "There is no user message to regenerate a reply for in chat %q.", chatID)
+	}
+
+	// the previous reply stays active if the generation can not start
+	gen, err = s.startGeneration(chat, user, replyID, messages, previous)
+	if err != nil {
+		return "", err
+	}
+	return gen.messageID, nil
+}
+
+// startGeneration starts streaming the assistant reply to the given history in the background,
+// the reply supersedes the previous one if it is not nil. The chat must be locked for the reply,
+// it is unlocked once the reply is saved. If the generation can not start, the chat stays locked for the caller.
+func (s *chatSvc) startGeneration(chat *model.Chat, user *model.User, messageID uuid.UUID, messages []*model.Message, previous *model.Message) (*generation, error) {
+	chatID := chat.ID.String()
+	settings := s.userSettings(chat)
+	replyModel := chatModel(settings)
+	metadata := common.Metadata{model.MetadataModel: replyModel}
+	if previous != nil {
+		metadata[model.MetadataRegeneratedFrom] = previous.ID.String()
+	}
+	systemPrompt, citations, chatSummary := s.promptContext(chat, messages, settings)
+	metadata = withCitations(metadata, citations)
+
+	// the generation is detached from the request, so it completes and gets persisted
+	// even if the client goes away. clients can reconnect and resume it.
+	detachedCtx := context.WithoutCancel(s.ctx)
+	stopCtx, cancel := context.WithCancelCause(detachedCtx)
+	genCtx, cancelTimeout := context.WithTimeout(stopCtx, generationTimeout)
 
 	// Streaming mode for other agents
-	stream, err := s.gptClient.SendToGPTStream(agent.SystemPrompt, chatSummary, messages)
+	stream, err := s.gptClient.SendToGPTStream(genCtx, replyModel, systemPrompt, chatSummary, messages)
 	if err != nil {
+		cancelTimeout()
+		cancel(nil)
 		return nil, errs.Wrapf(err, "failed to start GPT stream")
 	}
 
-	msgChan := make(chan *model.StreamedMessage)
+	gen := s.generations.start(chatID, messageID.String(), cancel)
+	go s.watchStopRequests(genCtx, chatID, messageID.String())
 	go func() {
-		defer close(msgChan)
-		var fullReply string
-		for chunk := range stream {
-			fullReply += chunk
-			msgChan <- &model.StreamedMessage{Content: chunk}
-		}
-
-		if fullReply != "" {
-			_ = s.stg.Message(s.ctx).CreateOne(&model.Message{
-				ChatID:  chatID,
-				Role:    "assistant",
-				Content: fullReply,
-			})
-		}
+		defer cancelTimeout()
+		// the chat is unlocked before the terminal event, so clients can send the next message as soon as they receive it
+		s.generations.run(genCtx, gen, stream, func(reply string, status model.MessageStatus) error {
+			assistantMessage := &model.Message{
+				ID:       messageID,
+				ChatID:   chatID,
+				Role:     "assistant",
+				Content:  reply,
+				Status:   status,
+				Metadata: metadata,
+			}
+			if err := s.saveTurn(detachedCtx, previous, assistantMessage); err != nil {
+				// the reply will never be saved, so it must not keep the chat locked
+				s.unlockChat(detachedCtx, chatID, messageID.String())
+				return err
+			}
+			s.embeddings.enqueue(assistantMessage, user.ID.String())
+			if status == model.MessageStatusCompleted {
+				question, _, _ := lo.FindLastIndexOf(messages, func(m *model.Message) bool { return m.Role == "user" })
+				s.rememberExchange(chat, question, assistantMessage, settings)
+			}
+			return nil
+		}, func(reply string, status model.MessageStatus) {
+			// the reply is already saved and its terminal event sent, the suggestions follow them
+			if status != model.MessageStatusCompleted {
+				return
+			}
+			if suggestions := s.saveSuggestions(detachedCtx, chat, messages, gen.messageID, reply, settings); suggestions != "" {
+				gen.publishTrailer(model.StreamEventSuggestions, suggestions)
+			}
+		})
 	}()
 
-	return msgChan, nil
+	s.events.publish(user.ID.String(), &model.ChatEvent{
+		Type:      model.ChatEventGeneration,
+		ChatID:    chatID,
+		MessageID: gen.messageID,
+		EventID:   formatStreamEventID(gen.messageID, 0),
+	})
+	return gen, nil
+}
+
+func (s *chatSvc) SubscribeStream(chatID, lastEventID string, user *model.User) (<-chan *model.StreamedMessage, error) {
+	chat, err := s.stg.Chat(s.ctx).FindById(chatID)
+	if err != nil {
+		return nil, errs.Wrapf(err, "failed to find chat")
+	}
+	if chat.UserId != user.ID.String() {
+		return nil, errors.New("permission denied")
+	}
+
+	messageID, afterSeq := "", 0
+	if lastEventID != "" {
+		var ok bool
+		messageID, afterSeq, ok = parseStreamEventID(lastEventID)
+		if !ok {
+			return nil, errs.Newf(errs.InvalidArgument, nil, "Invalid event id %q.", lastEventID)
+		}
+	}
+
+	gen := s.generations.find(chatID, messageID)
+	if gen == nil {
+		return nil, errs.Newf(errs.NotFound, nil, "There is no generation in progress for chat %q.", chatID)
+	}
+	return gen.subscribe(s.ctx, afterSeq), nil
+}
+
+func (s *chatSvc) StopGeneration(chatID, messageID string, user *model.User) error {
+	chat, err := s.stg.Chat(s.ctx).FindById(chatID)
+	if err != nil {
+		return errs.Wrapf(err, "failed to find chat")
+	}
+	if chat.UserId != user.ID.String() {
+		return errors.New("permission denied")
+	}
+	if s.generations.stop(chatID, messageID) {
+		return nil
+	}
+	// the reply may be generated by another instance, which stops it once it sees the request
+	requested, err := s.requestStop(chatID, messageID)
+	if err != nil {
+		return err
+	}
+	if !requested {
+		return errs.Newf(errs.NotFound, nil, "There is no generation in progress for chat %q.", chatID)
+	}
+	return nil
+}
+
+func (s *chatSvc) WatchChats(user *model.User) (<-chan *model.ChatEvent, func()) {
+	return s.events.subscribe(user.ID.String())
+}
+
+func (s *chatSvc) NotifyTyping(chatID string, user *model.User) error {
+	chat, err := s.stg.Chat(s.ctx).FindById(chatID)
+	if err != nil {
+		return errs.Wrapf(err, "failed to find chat")
+	}
+	if chat.UserId != user.ID.String() {
+		return errors.New("permission denied")
+	}
+	s.events.publish(user.ID.String(), &model.ChatEvent{
+		Type:   model.ChatEventTyping,
+		ChatID: chatID,
+	})
+	return nil
+}
+
+func (s *chatSvc) ListChats(filter *model.ChatFilter, pagination *common.CursorPagination, user *model.User) ([]*model.Chat, error) {
+	if filter.Sort == "" {
+		filter.Sort = model.ChatSortActivity
+	}
+	if pagination.Limit == 0 {
+		pagination.Limit = common.DefaultCursorLimit
+	}
+	chats, err := s.stg.Chat(s.ctx).ListByUserId(user.ID.String(), filter, pagination)
+	if err != nil {
+		return nil, errs.Wrapf(err, "failed to list chats")
+	}
+
+	chatIds := lo.Map(chats, func(c *model.Chat, _ int) string { return c.ID.String() })
+	tagsByChat, err := s.stg.Tag(s.ctx).ListByChatIds(chatIds)
+	if err != nil {
+		return nil, errs.Wrapf(err, "failed to list chat tags")
+	}
+	for _, chat := range chats {
+		chat.Tags = tagsByChat[chat.ID.String()]
+	}
+	return chats, nil
+}
+
+func (s *chatSvc) SetPinned(chatID string, pinned bool, user *model.User) error {
+	if err := checkChatsOwnership(s.ctx, s.stg, []string{chatID}, user); err != nil {
+		return err
+	}
+	if err := s.stg.Chat(s.ctx).SetPinned(chatID, pinned); err != nil {
+		return errs.Wrapf(err, "failed to pin chat")
+	}
+	return nil
 }
 
-func (s *chatSvc) ListChats(user *model.User) ([]*model.Chat, error) {
-	return s.stg.Chat(s.ctx).ListByUserId(user.ID.String())
+func (s *chatSvc) SetArchived(chatID string, archived bool, user *model.User) error {
+	if err := checkChatsOwnership(s.ctx, s.stg, []string{chatID}, user); err != nil {
+		return err
+	}
+	if err := s.stg.Chat(s.ctx).SetArchived(chatID, archived); err != nil {
+		return errs.Wrapf(err, "failed to archive chat")
+	}
+	return nil
 }
 
+// GetChat returns the chat with its latest page of messages, older ones are listed through ListMessages.
 func (s *chatSvc) GetChat(id string, user *model.User) (*model.Chat, error) {
 	chat, err := s.stg.Chat(s.ctx).FindById(id)
 	if err != nil {
@@ -129,41 +454,148 @@ func (s *chatSvc) GetChat(id string, user *model.User) (*model.Chat, error) {
 	if chat.UserId != user.ID.String() {
 		return nil, errors.New("permission denied")
 	}
-	messages, err := s.stg.Message(s.ctx).ListByChatId(id)
+	pagination := &common.CursorPagination{Limit: common.DefaultCursorLimit}
+	messages, err := s.stg.Message(s.ctx).ListPageByChatId(id, pagination)
 	if err != nil {
 		return nil, err
 	}
 	chat.Messages = messages
+	chat.MessagesCursor = pagination.NextCursor
 	return chat, nil
 }
 
-func (s *chatSvc) DeleteChat(chatID string, user *model.User) error {
+func (s *chatSvc) ListMessages(chatID string, pagination *common.CursorPagination, user *model.User) ([]*model.Message, error) {
 	chat, err := s.stg.Chat(s.ctx).FindById(chatID)
 	if err != nil {
-		return err
+		return nil, errs.Wrapf(err, "failed to find chat")
 	}
 	if chat.UserId != user.ID.String() {
-		return errors.New("not authorized")
+		return nil, errors.New("permission denied")
+	}
+	if pagination.Limit == 0 {
+		pagination.Limit = common.DefaultCursorLimit
 	}
-	return s.stg.Chat(s.ctx).DeleteById(chatID)
+	messages, err := s.stg.Message(s.ctx).ListPageByChatId(chatID, pagination)
+	if err != nil {
+		return nil, errs.Wrapf(err, "failed to list messages")
+	}
+	return messages, nil
 }
 
-func (s *chatSvc) CreateChat(message string, user *model.User) (*model.Chat, error) {
-	title, err := s.createChatTitle(message)
+// CreateChat creates a chat with the message as its first one and answers it.
+// The chat is returned with both messages, its title is generated concurrently with the answer.
+func (s *chatSvc) CreateChat(message, projectID, idempotencyKey string, user *model.User) (*model.Chat, error) {
+	record, claimed, err := s.acquireKey(idempotencyKey, requestFingerprint("create", projectID, message), user)
+	if err != nil {
+		return nil, err
+	}
+	if !claimed {
+		if record.Response != nil {
+			chat := &model.Chat{}
+			if err = json.Unmarshal(record.Response, chat); err != nil {
+				return nil, errs.Wrapf(err, "failed to decode the response of idempotency key %q", record.Key)
+			}
+			return chat, nil
+		}
+		if _, err = s.awaitReply(*record.ChatId, *record.MessageId); err != nil {
+			return nil, err
+		}
+		return s.GetChat(*record.ChatId, user)
+	}
+
+	chat, err := s.createChat(projectID, user)
 	if err != nil {
-		return nil, errs.Wrapf(err, "failed to create chat title")
+		s.releaseKey(record)
+		return nil, err
 	}
+	reply, err := s.sendMessage(chat.ID.String(), message, user)
+	if err != nil {
+		s.discardChat(chat)
+		s.releaseKey(record)
+		return nil, err
+	}
+	res, err := s.GetChat(chat.ID.String(), user)
+	if err != nil {
+		// the chat is answered, the retries with the key read it again instead of creating another one
+		s.completeKey(record, chat.ID.String(), reply.ID.String(), nil)
+		return nil, err
+	}
+	s.completeKey(record, chat.ID.String(), reply.ID.String(), res)
+	return res, nil
+}
+
+// CreateChatStream creates a chat with the message as its first one and streams the answer like SendMessageStream.
+func (s *chatSvc) CreateChatStream(message, projectID, idempotencyKey string, user *model.User) (*model.Chat, <-chan *model.StreamedMessage, error) {
+	record, claimed, err := s.acquireKey(idempotencyKey, requestFingerprint("create", projectID, message), user)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !claimed {
+		chat, err := s.GetChat(*record.ChatId, user)
+		if err != nil {
+			return nil, nil, err
+		}
+		stream, err := s.replayStream(record)
+		if err != nil {
+			return nil, nil, err
+		}
+		return chat, stream, nil
+	}
+
+	chat, err := s.createChat(projectID, user)
+	if err != nil {
+		s.releaseKey(record)
+		return nil, nil, err
+	}
+	gen, err := s.sendMessageAsync(chat.ID.String(), message, user)
+	if err != nil {
+		s.discardChat(chat)
+		s.releaseKey(record)
+		return nil, nil, err
+	}
+	s.completeKey(record, chat.ID.String(), gen.messageID, nil)
+	return chat, gen.subscribe(s.ctx, 0), nil
+}
+
+// createChat creates a chat with the placeholder title. Chats created in a project use the project's default agent,
+// the other ones the user's default agent.
+func (s *chatSvc) createChat(projectID string, user *model.User) (*model.Chat, error) {
 	newChat := model.Chat{
-		Title:  title,
-		UserId: user.ID.String(),
+		UserId:     user.ID.String(),
+		Title:      model.PlaceholderChatTitle,
+		TitleState: model.ChatTitleStatePlaceholder,
 	}
-	if err = s.stg.Chat(s.ctx).CreateOne(&newChat); err != nil {
+	if projectID != "" {
+		project, err := s.stg.Project(s.ctx).FindById(projectID)
+		if err != nil {
+			return nil, errs.Wrapf(err, "failed to find project")
+		}
+		if project.UserId != user.ID.String() {
+			return nil, errors.New("permission denied")
+		}
+		newChat.ProjectId = &projectID
+		newChat.Agent = project.DefaultAgent
+	} else {
+		newChat.Agent = s.userSettings(&newChat).DefaultAgent
+	}
+
+	if err := s.stg.Chat(s.ctx).CreateOne(&newChat); err != nil {
 		return nil, errs.Wrapf(err, "failed to create chat record")
 	}
 	return &newChat, nil
 }
 
-func (s *chatSvc) prepareMessage(chatID, message string, user *model.User) (*model.Chat, error) {
+// discardChat permanently removes a chat whose first message could not be answered,
+// so requests failing to create a chat do not leave empty or failed chats behind, not even in the trash.
+func (s *chatSvc) discardChat(chat *model.Chat) {
+	if err := s.stg.Chat(context.WithoutCancel(s.ctx)).Purge(chat.ID.String()); err != nil {
+		logger.Errorf("failed to discard chat %s: %v", chat.ID, err)
+	}
+}
+
+// prepareMessage checks that the user can send messages to the chat and locks the chat for the reply.
+// Locking before saving the user message keeps the messages of concurrent sends in a strict order.
+func (s *chatSvc) prepareMessage(chatID, replyID string, user *model.User) (*model.Chat, error) {
 	chat, err := s.stg.Chat(s.ctx).FindById(chatID)
 	if err != nil {
 		return nil, errs.Wrapf(err, "failed to find chat")
@@ -171,25 +603,172 @@ func (s *chatSvc) prepareMessage(chatID, message string, user *model.User) (*mod
 	if chat.UserId != user.ID.String() {
 		return nil, errors.New("permission denied")
 	}
+	if err = s.lockChat(chatID, replyID); err != nil {
+		return nil, err
+	}
+	return chat, nil
+}
 
-	err = s.stg.Message(s.ctx).CreateOne(&model.Message{
-		ChatID:   chatID,
-		Role:     "user",
-		Content:  message,
-		Metadata: common.Metadata{},
+// newUserMessage creates the message sent by the user, it keeps the time it was sent even if it is saved with the reply.
+func newUserMessage(chatID, content string) *model.Message {
+	return &model.Message{
+		ID:        uuid.New(),
+		ChatID:    chatID,
+		Role:      "user",
+		Content:   content,
+		Status:    model.MessageStatusCompleted,
+		Metadata:  common.Metadata{},
+		CreatedAt: time.Now(),
+	}
+}
+
+// saveTurn saves the messages of a turn, which end with the reply, as a single unit of work. It also marks the
+// reply regenerated by the turn as superseded and unlocks the chat. Therefore a failure can neither leave a user
+// message without its reply, nor two active replies to the same message, nor a chat locked by a saved reply.
+func (s *chatSvc) saveTurn(ctx context.Context, previous *model.Message, messages ...*model.Message) error {
+	reply := messages[len(messages)-1]
+	return s.stg.Atomic(func(stg storage.Storage) error {
+		for _, m := range messages {
+			if err := stg.Message(ctx).CreateOne(m); err != nil {
+				return errs.Wrapf(err, "failed to save %s message", m.Role)
+			}
+		}
+		if previous != nil {
+			if previous.Metadata == nil {
+				previous.Metadata = common.Metadata{}
+			}
+			previous.Metadata[model.MetadataSupersededBy] = reply.ID.String()
+			if err := stg.Message(ctx).UpdateOne(previous, false); err != nil {
+				return errs.Wrapf(err, "failed to update the regenerated message")
+			}
+		}
+		if err := stg.Chat(ctx).UnlockGeneration(reply.ChatID, reply.ID.String()); err != nil {
+			return errs.Wrapf(err, "failed to unlock chat")
+		}
+		return nil
 	})
-	if err != nil {
-		return nil, errs.Wrapf(err, "failed to save user message")
+}
+
+// failReply saves a failed reply to the saved user message whose generation could not start,
+// so the turn is explicitly marked as failed and can be regenerated.
+func (s *chatSvc) failReply(chatID string, replyID uuid.UUID) {
+	ctx := context.WithoutCancel(s.ctx)
+	reply := &model.Message{
+		ID:       replyID,
+		ChatID:   chatID,
+		Role:     "assistant",
+		Status:   model.MessageStatusFailed,
+		Metadata: common.Metadata{model.MetadataModel: clients.ChatModel},
+	}
+	if err := s.saveTurn(ctx, nil, reply); err != nil {
+		logger.Errorf("failed to save the failed reply %s of chat %s: %v", replyID, chatID, err)
+		s.unlockChat(ctx, chatID, replyID.String())
 	}
-	return chat, nil
 }
 
-func (s *chatSvc) createChatTitle(message string) (string, error) {
-	titlePrompt := []*model.Message{
-		{Role: "system", Content: "You are a helpful assistant that writes concise titles for chat conversations."},
-		{Role: "user", Content: fmt.Sprintf("Create a short and clear title without quoutes for this message:\n\"%s\"", message)},
+// promptContext builds the system prompt, with the citations of the reply, and the summary of the history concurrently,
+// both may call the model before the reply can start.
+func (s *chatSvc) promptContext(chat *model.Chat, messages []*model.Message, settings *model.UserSettings) (string, []*model.Citation, string) {
+	summaryCh := make(chan string, 1)
+	go func() { summaryCh <- s.checkAndSummarizeIfNeeded(messages) }()
+	systemPrompt, citations := s.systemPrompt(chat, messages, settings)
+	return systemPrompt, citations, <-summaryCh
+}
+
+// systemPrompt builds the system prompt of the chat from its agent, the settings and the memories of the user
+// relevant to the last message and, for project chats, the project instructions and the relevant project documents.
+// The excerpts of the project documents are returned as the citations of the reply.
+func (s *chatSvc) systemPrompt(chat *model.Chat, messages []*model.Message, settings *model.UserSettings) (string, []*model.Citation) {
+	// the memories and the documents are both searched by the last message, which is embedded while the project is loaded
+	vectorCh := make(chan common.Vector, 1)
+	if chat.ProjectId != nil || settings.MemoryEnabled {
+		go func() { vectorCh <- s.lastMessageVector(chat, messages) }()
+	} else {
+		vectorCh <- nil
+	}
+
+	var project *model.Project
+	if chat.ProjectId != nil {
+		var err error
+		project, err = s.stg.Project(s.ctx).FindById(*chat.ProjectId)
+		if err != nil {
+			logger.Errorf("failed to load project %s of chat %s: %v", *chat.ProjectId, chat.ID, err)
+			project = nil
+		}
+	}
+	vector := <-vectorCh
+
+	var sb strings.Builder
+	sb.WriteString(chatInstructions(chat, project))
+	if instructions := settingsInstructions(settings); instructions != "" {
+		sb.WriteString("\n\n")
+		sb.WriteString(instructions)
 	}
-	return s.gptClient.SendMessages(titlePrompt)
+	if settings.MemoryEnabled {
+		if memories := s.relevantMemories(chat.UserId, vector); len(memories) > 0 {
+			sb.WriteString("\n\nYou remember this about the user from previous conversations, use it when it is relevant:")
+			for _, memory := range memories {
+				sb.WriteString("\n- ")
+				sb.WriteString(memory.Content)
+			}
+		}
+	}
+	if project == nil {
+		return sb.String(), nil
+	}
+	var citations []*model.Citation
+	if documents := s.projectDocuments(project, vector); len(documents) > 0 {
+		sb.WriteString("\n\nUse these excerpts of the project documents when they are relevant:")
+		for _, chunk := range documents {
+			sb.WriteString(fmt.Sprintf("\n\n[%s]\n%s", chunk.FileName, chunk.Content))
+			citations = append(citations, &model.Citation{
+				FileID:     chunk.FileID,
+				FileName:   chunk.FileName,
+				ChunkIndex: chunk.ChunkIndex,
+				Excerpt:    truncateRunes(chunk.Content, maxCitationExcerptLength),
+			})
+		}
+	}
+	return sb.String(), citations
+}
+
+// withCitations records the citations in the metadata of a reply.
+func withCitations(metadata common.Metadata, citations []*model.Citation) common.Metadata {
+	if len(citations) == 0 {
+		return metadata
+	}
+	if metadata == nil {
+		metadata = common.Metadata{}
+	}
+	metadata[model.MetadataCitations] = model.EncodeCitations(citations)
+	return metadata
+}
+
+// chatInstructions combines the prompt of the chat's agent with the instructions of its project, if any.
+func chatInstructions(chat *model.Chat, project *model.Project) string {
+	agent, ok := model.FindAgent(chat.Agent)
+	if !ok {
+		logger.Warnf("chat %s uses unknown agent %q, falling back to the default agent", chat.ID, chat.Agent)
+		agent = model.DefaultAgent
+	}
+	if project == nil || project.Instructions == "" {
+		return agent.SystemPrompt
+	}
+	return fmt.Sprintf("%s\n\nFollow these instructions of the project \"%s\":\n%s", agent.SystemPrompt, project.Name, project.Instructions)
+}
+
+// projectDocuments returns the chunks of the project files closest to the vector of the last user message.
+// Failures are only logged, the message is still answered without the documents.
+func (s *chatSvc) projectDocuments(project *model.Project, vector common.Vector) []*model.ProjectFileChunk {
+	if vector == nil {
+		return nil
+	}
+	chunks, err := s.stg.ProjectFile(s.ctx).SearchChunks(project.ID.String(), vector, projectDocumentChunks)
+	if err != nil {
+		logger.Errorf("failed to search documents of project %s: %v", project.ID, err)
+		return nil
+	}
+	return chunks
 }
 
 func (s *chatSvc) checkAndSummarizeIfNeeded(messages []*model.Message) string {
@@ -213,6 +792,37 @@ func (s *chatSvc) checkAndSummarizeIfNeeded(messages []*model.Message) string {
 	return summary
 }
 
+// checkChatsOwnership fails unless every chat exists and belongs to the user.
+func checkChatsOwnership(ctx context.Context, stg storage.Storage, chatIds []string, user *model.User) error {
+	chatIds = lo.Uniq(chatIds)
+	chats, err := stg.Chat(ctx).ListByIds(chatIds)
+	if err != nil {
+		return errs.Wrapf(err, "failed to list chats")
+	}
+	if len(chats) != len(chatIds) {
+		return errs.Newf(errs.NotFound, nil, "Some of the chats could not be found.")
+	}
+	for _, chat := range chats {
+		if chat.UserId != user.ID.String() {
+			return errors.New("permission denied")
+		}
+	}
+	return nil
+}
+
+// activeMessages filters out the replies that were superseded by a regenerated one and the failed replies,
+// which are empty and must not reach the model as empty assistant turns.
+func activeMessages(messages []*model.Message) []*model.Message {
+	return lo.Filter(messages, func(m *model.Message, _ int) bool {
+		return !superseded(m) && m.Status != model.MessageStatusFailed && m.Content != ""
+	})
+}
+
+func superseded(message *model.Message) bool {
+	_, ok := message.Metadata[model.MetadataSupersededBy]
+	return ok
+}
+
 func joinChunks(chunks []string, limit int) string {
 	var result string
 	count := 0
@@ -0,0 +1,23 @@
+package svc
+
+import (
+	"testing"
+
+	"github.com/amahdian/ai-assistant-be/domain/model"
+	"github.com/amahdian/ai-assistant-be/domain/model/common"
+	"github.com/stretchr/testify/require"
+)
+
+func TestActiveMessages(t *testing.T) {
+	messages := []*model.Message{
+		{Role: "user", Content: "question", Status: model.MessageStatusCompleted},
+		{Role: "assistant", Status: model.MessageStatusFailed},
+		{Role: "assistant", Content: "first", Status: model.MessageStatusCompleted, Metadata: common.Metadata{model.MetadataSupersededBy: "second"}},
+		{Role: "assistant", Content: "second", Status: model.MessageStatusCompleted},
+		{Role: "user", Content: "follow-up", Status: model.MessageStatusCompleted},
+		{Role: "assistant", Status: model.MessageStatusStopped},
+	}
+
+	active := activeMessages(messages)
+	require.Equal(t, []*model.Message{messages[0], messages[3], messages[4]}, active)
+}
@@ -0,0 +1,153 @@
+package svc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/amahdian/ai-assistant-be/domain/model"
+	"github.com/amahdian/ai-assistant-be/global/errs"
+	"github.com/amahdian/ai-assistant-be/pkg/logger"
+	"github.com/samber/lo"
+)
+
+const (
+	// titleTimeout bounds the background generation of a chat title.
+	titleTimeout = time.Minute
+	// maxChatTitleLength is the maximum length of a chat title in runes.
+	maxChatTitleLength = 200
+	// titleExchangeLength is the length in runes of each message of the exchange a title is created from.
+	titleExchangeLength = 2000
+)
+
+// RenameChat sets a custom title, which is never replaced by a generated one.
+func (s *chatSvc) RenameChat(chatID, title string, user *model.User) (*model.Chat, error) {
+	title = strings.TrimSpace(title)
+	if title == "" {
+		return nil, errs.Newf(errs.InvalidArgument, nil, "The title can not be empty.")
+	}
+	if len([]rune(title)) > maxChatTitleLength {
+		return nil, errs.Newf(errs.InvalidArgument, nil, "The title can not be longer than %d characters.", maxChatTitleLength)
+	}
+
+	chat, err := s.stg.Chat(s.ctx).FindById(chatID)
+	if err != nil {
+		return nil, errs.Wrapf(err, "failed to find chat")
+	}
+	if chat.UserId != user.ID.String() {
+		return nil, errors.New("permission denied")
+	}
+	if err = s.stg.Chat(s.ctx).SetTitle(chatID, title, model.ChatTitleStateCustom); err != nil {
+		return nil, errs.Wrapf(err, "failed to rename chat")
+	}
+	chat.Title = title
+	chat.TitleState = model.ChatTitleStateCustom
+
+	s.publishTitle(chat, user)
+	return chat, nil
+}
+
+// RegenerateTitle generates a new title from the first exchange of the chat in the background,
+// replacing custom titles too. The title is pushed to the clients once it is ready.
+func (s *chatSvc) RegenerateTitle(chatID string, user *model.User) error {
+	chat, err := s.stg.Chat(s.ctx).FindById(chatID)
+	if err != nil {
+		return errs.Wrapf(err, "failed to find chat")
+	}
+	if chat.UserId != user.ID.String() {
+		return errors.New("permission denied")
+	}
+	if chat.MessageCount == 0 {
+		return errs.Newf(errs.FailedPrecondition, nil, "There are no messages to title chat %q after.", chatID)
+	}
+
+	s.generateTitle(chat, user, true)
+	return nil
+}
+
+// titleIfPlaceholder starts titling a chat which still has the placeholder title,
+// concurrently with the answer to its first message.
+func (s *chatSvc) titleIfPlaceholder(chat *model.Chat, user *model.User) {
+	if chat.TitleState == model.ChatTitleStatePlaceholder {
+		s.generateTitle(chat, user, false)
+	}
+}
+
+// generateTitle titles the chat after its first exchange, or its first message while it is being answered,
+// in the background and publishes the title.
+// Unless force is set, the title is only replaced while the chat still has the placeholder one,
+// so a rename in the meantime wins. Failures are only logged, the chat keeps its current title.
+func (s *chatSvc) generateTitle(chat *model.Chat, user *model.User, force bool) {
+	chatID := chat.ID.String()
+	// the chat is still used by the request, the title is set on a copy
+	chat = lo.ToPtr(*chat)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(s.ctx), titleTimeout)
+		defer cancel()
+
+		messages, err := s.stg.Message(ctx).ListByChatId(chatID)
+		if err != nil {
+			logger.Errorf("failed to list messages to title chat %s: %v", chatID, err)
+			return
+		}
+		title, err := s.createChatTitle(activeMessages(messages))
+		if err != nil {
+			logger.Errorf("failed to create title of chat %s: %v", chatID, err)
+			return
+		}
+		if title == "" {
+			return
+		}
+
+		if force {
+			err = s.stg.Chat(ctx).SetTitle(chatID, title, model.ChatTitleStateGenerated)
+		} else {
+			var replaced bool
+			replaced, err = s.stg.Chat(ctx).SetPlaceholderTitle(chatID, title)
+			if err == nil && !replaced {
+				return
+			}
+		}
+		if err != nil {
+			logger.Errorf("failed to save title of chat %s: %v", chatID, err)
+			return
+		}
+
+		chat.Title = title
+		chat.TitleState = model.ChatTitleStateGenerated
+		s.publishTitle(chat, user)
+	}()
+}
+
+func (s *chatSvc) publishTitle(chat *model.Chat, user *model.User) {
+	s.events.publish(user.ID.String(), &model.ChatEvent{
+		Type:   model.ChatEventTitle,
+		ChatID: chat.ID.String(),
+		Title:  chat.Title,
+	})
+}
+
+// createChatTitle creates a title from the first user message of the chat and the reply to it.
+func (s *chatSvc) createChatTitle(messages []*model.Message) (string, error) {
+	question, index, ok := lo.FindIndexOf(messages, func(m *model.Message) bool { return m.Role == "user" })
+	if !ok {
+		return "", nil
+	}
+	exchange := fmt.Sprintf("user: %s", truncateRunes(question.Content, titleExchangeLength))
+	if answer, ok := lo.Find(messages[index+1:], func(m *model.Message) bool { return m.Role == "assistant" }); ok {
+		exchange += fmt.Sprintf("\nassistant: %s", truncateRunes(answer.Content, titleExchangeLength))
+	}
+
+	titlePrompt := []*model.Message{
+		{Role: "system", Content: "You are a helpful assistant that writes concise titles for chat conversations."},
+		{Role: "user", Content: fmt.Sprintf("Create a short and clear title without quotes for this conversation:\n%s", exchange)},
+	}
+	title, err := s.gptClient.SendMessages(titlePrompt)
+	if err != nil {
+		return "", err
+	}
+	title = strings.Trim(strings.TrimSpace(title), "\"'")
+	return truncateRunes(title, maxChatTitleLength), nil
+}
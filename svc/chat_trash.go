@@ -0,0 +1,91 @@
+package svc
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/amahdian/ai-assistant-be/domain/model"
+	"github.com/amahdian/ai-assistant-be/domain/model/common"
+	"github.com/amahdian/ai-assistant-be/global/errs"
+	"github.com/amahdian/ai-assistant-be/pkg/logger"
+	"github.com/amahdian/ai-assistant-be/storage"
+	"github.com/samber/lo"
+)
+
+// deletedChatsPurgeInterval is how often the chats deleted for longer than the retention period are purged.
+const deletedChatsPurgeInterval = time.Hour
+
+func (s *chatSvc) DeleteChat(chatID string, user *model.User) error {
+	chat, err := s.stg.Chat(s.ctx).FindById(chatID)
+	if err != nil {
+		return err
+	}
+	if chat.UserId != user.ID.String() {
+		return errors.New("not authorized")
+	}
+	if err = s.stg.Chat(s.ctx).DeleteById(chatID); err != nil {
+		return errs.Wrapf(err, "failed to delete chat")
+	}
+	s.generations.stop(chatID, "")
+	return nil
+}
+
+func (s *chatSvc) DeleteChats(chatIDs []string, user *model.User) error {
+	chatIDs = lo.Uniq(chatIDs)
+	if err := checkChatsOwnership(s.ctx, s.stg, chatIDs, user); err != nil {
+		return err
+	}
+	if err := s.stg.Chat(s.ctx).DeleteByIds(chatIDs); err != nil {
+		return errs.Wrapf(err, "failed to delete chats")
+	}
+	for _, chatID := range chatIDs {
+		s.generations.stop(chatID, "")
+	}
+	return nil
+}
+
+func (s *chatSvc) ListDeletedChats(pagination *common.CursorPagination, user *model.User) ([]*model.Chat, error) {
+	if pagination.Limit == 0 {
+		pagination.Limit = common.DefaultCursorLimit
+	}
+	chats, err := s.stg.Chat(s.ctx).ListDeletedByUserId(user.ID.String(), pagination)
+	if err != nil {
+		return nil, errs.Wrapf(err, "failed to list deleted chats")
+	}
+	return chats, nil
+}
+
+func (s *chatSvc) RestoreChat(chatID string, user *model.User) (*model.Chat, error) {
+	chat, err := s.stg.Chat(s.ctx).FindDeletedById(chatID)
+	if err != nil {
+		return nil, err
+	}
+	if chat.UserId != user.ID.String() {
+		return nil, errors.New("not authorized")
+	}
+	if err = s.stg.Chat(s.ctx).Restore(chatID); err != nil {
+		return nil, errs.Wrapf(err, "failed to restore chat")
+	}
+	chat.DeletedAt.Valid = false
+	return chat, nil
+}
+
+// purgeDeletedChats periodically removes the chats deleted for longer than retention until ctx is done.
+func purgeDeletedChats(ctx context.Context, stg storage.Storage, retention time.Duration) {
+	ticker := time.NewTicker(deletedChatsPurgeInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			count, err := stg.Chat(ctx).PurgeDeleted(time.Now().Add(-retention))
+			if err != nil {
+				logger.Errorf("failed to purge deleted chats: %v", err)
+				continue
+			}
+			logger.Debugf("purged %d deleted chats", count)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
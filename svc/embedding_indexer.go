@@ -0,0 +1,194 @@
+package svc
+
+import (
+	"context"
+	"time"
+
+	"github.com/amahdian/ai-assistant-be/clients"
+	"github.com/amahdian/ai-assistant-be/domain/model"
+	"github.com/amahdian/ai-assistant-be/domain/model/common"
+	"github.com/amahdian/ai-assistant-be/global/errs"
+	"github.com/amahdian/ai-assistant-be/pkg/logger"
+	"github.com/amahdian/ai-assistant-be/storage"
+	"github.com/google/uuid"
+	"github.com/samber/lo"
+)
+
+const (
+	embeddingQueueSize = 1024
+	embeddingBatchSize = 64
+	// embeddingBatchWait is how long a partial batch waits for more messages before it gets indexed.
+	embeddingBatchWait = 500 * time.Millisecond
+	embeddingTimeout   = 30 * time.Second
+	// embeddingMaxInputLength keeps the input well below the token limit of the embedding model.
+	embeddingMaxInputLength = 8000
+)
+
+type embeddingJob struct {
+	messageID uuid.UUID
+	chatID    string
+	userID    string
+	content   string
+}
+
+// embeddingIndexer computes the embeddings of the messages in the background and stores them in the vector index.
+type embeddingIndexer struct {
+	gptClient clients.GPTClient
+	vectors   func(ctx context.Context) storage.EmbeddingStorage
+	jobs      chan *embeddingJob
+}
+
+func newEmbeddingIndexer(ctx context.Context, gptClient clients.GPTClient, vectors func(ctx context.Context) storage.EmbeddingStorage) *embeddingIndexer {
+	i := &embeddingIndexer{
+		gptClient: gptClient,
+		vectors:   vectors,
+		jobs:      make(chan *embeddingJob, embeddingQueueSize),
+	}
+	go i.run(ctx)
+	return i
+}
+
+// enqueue schedules the message for indexing without blocking the caller.
+// Messages dropped because the queue is full are picked up by the next backfill.
+func (i *embeddingIndexer) enqueue(message *model.Message, userID string) {
+	if message.Content == "" {
+		return
+	}
+	job := &embeddingJob{
+		messageID: message.ID,
+		chatID:    message.ChatID,
+		userID:    userID,
+		content:   message.Content,
+	}
+	select {
+	case i.jobs <- job:
+	default:
+		logger.Warnf("embedding queue is full, dropped message %s", message.ID)
+	}
+}
+
+func (i *embeddingIndexer) run(ctx context.Context) {
+	batch := make([]*embeddingJob, 0, embeddingBatchSize)
+	timer := time.NewTimer(embeddingBatchWait)
+	defer timer.Stop()
+
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := i.index(ctx, batch); err != nil {
+			logger.Errorf("failed to index %d messages: %v", len(batch), err)
+		}
+		batch = batch[:0]
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case job := <-i.jobs:
+			batch = append(batch, job)
+			if len(batch) == 1 {
+				timer.Reset(embeddingBatchWait)
+			}
+			if len(batch) == embeddingBatchSize {
+				flush()
+			}
+		case <-timer.C:
+			flush()
+		}
+	}
+}
+
+func (i *embeddingIndexer) index(ctx context.Context, jobs []*embeddingJob) error {
+	ctx, cancel := context.WithTimeout(ctx, embeddingTimeout)
+	defer cancel()
+
+	vectors, err := i.embedTexts(ctx, lo.Map(jobs, func(job *embeddingJob, _ int) string { return job.content }))
+	if err != nil {
+		return err
+	}
+
+	embeddings := make([]*model.MessageEmbedding, len(jobs))
+	for idx, job := range jobs {
+		embeddings[idx] = &model.MessageEmbedding{
+			MessageID: job.messageID,
+			ChatID:    job.chatID,
+			UserId:    job.userID,
+			Embedding: vectors[idx],
+		}
+	}
+	if err = i.vectors(ctx).UpsertMany(embeddings); err != nil {
+		return errs.Wrapf(err, "failed to store embeddings")
+	}
+	return nil
+}
+
+// embedQuery returns the embedding of a search query.
+func (i *embeddingIndexer) embedQuery(ctx context.Context, query string) (common.Vector, error) {
+	vectors, err := i.embedTexts(ctx, []string{query})
+	if err != nil {
+		return nil, err
+	}
+	return vectors[0], nil
+}
+
+// embedTexts returns the embeddings of the texts in the same order, they are sent to the model in batches.
+func (i *embeddingIndexer) embedTexts(ctx context.Context, texts []string) ([]common.Vector, error) {
+	vectors := make([]common.Vector, 0, len(texts))
+	for _, batch := range lo.Chunk(texts, embeddingBatchSize) {
+		inputs := lo.Map(batch, func(text string, _ int) string {
+			return truncateRunes(text, embeddingMaxInputLength)
+		})
+		batchVectors, err := i.gptClient.CreateEmbeddings(ctx, inputs)
+		if err != nil {
+			return nil, errs.Wrapf(err, "failed to create embeddings")
+		}
+		vectors = append(vectors, batchVectors...)
+	}
+	return vectors, nil
+}
+
+// backfill indexes the messages that were stored before the vector index existed or were dropped from the queue.
+func (i *embeddingIndexer) backfill(ctx context.Context, stg storage.Storage) {
+	for ctx.Err() == nil {
+		messages, err := stg.Message(ctx).ListWithoutEmbedding(embeddingBatchSize)
+		if err != nil {
+			logger.Errorf("failed to list messages without embedding: %v", err)
+			return
+		}
+		if len(messages) == 0 {
+			return
+		}
+
+		chatIds := lo.Uniq(lo.Map(messages, func(m *model.Message, _ int) string { return m.ChatID }))
+		chats, err := stg.Chat(ctx).ListByIds(chatIds)
+		if err != nil {
+			logger.Errorf("failed to list chats of messages without embedding: %v", err)
+			return
+		}
+		chatToUser := lo.SliceToMap(chats, func(c *model.Chat) (string, string) { return c.ID.String(), c.UserId })
+
+		jobs := lo.Map(messages, func(m *model.Message, _ int) *embeddingJob {
+			return &embeddingJob{
+				messageID: m.ID,
+				chatID:    m.ChatID,
+				userID:    chatToUser[m.ChatID],
+				content:   m.Content,
+			}
+		})
+		// stop instead of retrying the same batch forever, the next start picks it up again
+		if err = i.index(ctx, jobs); err != nil {
+			logger.Errorf("failed to backfill message embeddings: %v", err)
+			return
+		}
+	}
+}
+
+func truncateRunes(s string, limit int) string {
+	runes := []rune(s)
+	if len(runes) <= limit {
+		return s
+	}
+	return string(runes[:limit])
+}
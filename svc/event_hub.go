@@ -0,0 +1,64 @@
+package svc
+
+import (
+	"sync"
+
+	"github.com/amahdian/ai-assistant-be/domain/model"
+	"github.com/amahdian/ai-assistant-be/pkg/logger"
+)
+
+// eventSubscriberBuffer is the number of events a subscriber may lag behind before events are dropped.
+const eventSubscriberBuffer = 64
+
+// eventHub fans out chat events to all the subscribers of a user, e.g. their open WebSocket connections.
+// Publishing never blocks: events are dropped for subscribers that do not keep up.
+type eventHub struct {
+	mu sync.RWMutex
+	// user id -> subscribers
+	subscribers map[string]map[chan *model.ChatEvent]struct{}
+}
+
+func newEventHub() *eventHub {
+	return &eventHub{
+		subscribers: make(map[string]map[chan *model.ChatEvent]struct{}),
+	}
+}
+
+// subscribe returns the events of the user and a function to unsubscribe, which closes the channel.
+func (h *eventHub) subscribe(userID string) (<-chan *model.ChatEvent, func()) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	ch := make(chan *model.ChatEvent, eventSubscriberBuffer)
+	if _, ok := h.subscribers[userID]; !ok {
+		h.subscribers[userID] = make(map[chan *model.ChatEvent]struct{})
+	}
+	h.subscribers[userID][ch] = struct{}{}
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			h.mu.Lock()
+			defer h.mu.Unlock()
+
+			delete(h.subscribers[userID], ch)
+			if len(h.subscribers[userID]) == 0 {
+				delete(h.subscribers, userID)
+			}
+			close(ch)
+		})
+	}
+}
+
+func (h *eventHub) publish(userID string, event *model.ChatEvent) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	for ch := range h.subscribers[userID] {
+		select {
+		case ch <- event:
+		default:
+			logger.Warnf("dropped %s event of chat %s for a slow subscriber", event.Type, event.ChatID)
+		}
+	}
+}
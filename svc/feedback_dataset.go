@@ -0,0 +1,266 @@
+package svc
+
+import (
+	"bytes"
+	"encoding/json"
+	"hash/fnv"
+	"regexp"
+	"sort"
+
+	"github.com/amahdian/ai-assistant-be/clients/dtos"
+	"github.com/amahdian/ai-assistant-be/domain/model"
+	"github.com/amahdian/ai-assistant-be/global/errs"
+	"github.com/amahdian/ai-assistant-be/pkg/logger"
+	"github.com/samber/lo"
+)
+
+const (
+	// datasetContextMessages is the maximum number of previous messages given as the context of a reply.
+	datasetContextMessages = 20
+	// defaultValidationRatio is the share of the chats in the validation split when none is requested.
+	defaultValidationRatio = 0.1
+)
+
+// piiPatterns are applied in order, so the card numbers are masked before they look like phone numbers.
+var piiPatterns = []struct {
+	pattern *regexp.Regexp
+	mask    string
+}{
+	{regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`), "[EMAIL]"},
+	{regexp.MustCompile(`\b(?:\d[ -]?){12,18}\d\b`), "[CARD]"},
+	{regexp.MustCompile(`\b(?:\d{1,3}\.){3}\d{1,3}\b`), "[IP]"},
+	{regexp.MustCompile(`(?:\+\d{1,3}[\s.-]?)?(?:\(\d{2,4}\)|\b\d{2,4})[\s.-]?\d{3,4}[\s.-]?\d{3,4}\b`), "[PHONE]"},
+}
+
+func (s *feedbackSvc) ExportDataset(filter *model.FeedbackReportFilter, options *model.DatasetOptions) ([]byte, error) {
+	if options.Format == "" {
+		options.Format = model.DatasetFormatChat
+	}
+	if options.Split == "" {
+		options.Split = model.DatasetSplitTrain
+	}
+	ratio := lo.FromPtrOr(options.ValidationRatio, defaultValidationRatio)
+	if ratio < 0 || ratio > 1 {
+		return nil, errs.Newf(errs.InvalidArgument, nil, "The validation ratio must be between 0 and 1.")
+	}
+
+	feedback, err := s.stg.Feedback(s.ctx).ListByFilter(filter)
+	if err != nil {
+		return nil, errs.Wrapf(err, "failed to list feedback")
+	}
+	byChat := lo.GroupBy(feedback, func(f *model.MessageFeedback) string { return f.ChatID })
+	// the chats are visited in a fixed order, so the same data always produces the same file
+	chatIds := lo.Filter(lo.Keys(byChat), func(id string, _ int) bool { return datasetSplit(id, ratio) == options.Split })
+	sort.Strings(chatIds)
+	if len(chatIds) == 0 {
+		return []byte{}, nil
+	}
+
+	chats, err := s.stg.Chat(s.ctx).ListByIds(chatIds)
+	if err != nil {
+		return nil, errs.Wrapf(err, "failed to list chats")
+	}
+	chatsById := lo.KeyBy(chats, func(c *model.Chat) string { return c.ID.String() })
+	projects := map[string]*model.Project{}
+
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	for _, chatID := range chatIds {
+		chat, ok := chatsById[chatID]
+		if !ok {
+			continue
+		}
+		messages, err := s.stg.Message(s.ctx).ListByChatId(chatID)
+		if err != nil {
+			return nil, errs.Wrapf(err, "failed to list messages")
+		}
+		systemPrompt := chatInstructions(chat, s.datasetProject(chat, projects))
+		ratings := lo.SliceToMap(byChat[chatID], func(f *model.MessageFeedback) (string, model.FeedbackRating) {
+			return f.MessageID, f.Rating
+		})
+
+		var examples []any
+		switch options.Format {
+		case model.DatasetFormatChat:
+			for _, example := range chatExamples(systemPrompt, messages, ratings) {
+				if options.ScrubPII {
+					scrubMessages(example.Messages)
+				}
+				examples = append(examples, example)
+			}
+		case model.DatasetFormatPreference:
+			for _, example := range preferenceExamples(systemPrompt, messages, ratings) {
+				if options.ScrubPII {
+					scrubMessages(example.Input.Messages)
+					scrubMessages(example.PreferredOutput)
+					scrubMessages(example.NonPreferredOutput)
+				}
+				examples = append(examples, example)
+			}
+		default:
+			return nil, errs.Newf(errs.InvalidArgument, nil, "Unsupported dataset format %q.", options.Format)
+		}
+		for _, example := range examples {
+			if err = encoder.Encode(example); err != nil {
+				return nil, errs.Wrapf(err, "failed to encode dataset example")
+			}
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// datasetProject returns the project of the chat, caching the projects shared by several chats.
+func (s *feedbackSvc) datasetProject(chat *model.Chat, projects map[string]*model.Project) *model.Project {
+	if chat.ProjectId == nil {
+		return nil
+	}
+	if project, ok := projects[*chat.ProjectId]; ok {
+		return project
+	}
+	project, err := s.stg.Project(s.ctx).FindById(*chat.ProjectId)
+	if err != nil {
+		logger.Errorf("failed to load project %s of chat %s: %v", *chat.ProjectId, chat.ID, err)
+		project = nil
+	}
+	projects[*chat.ProjectId] = project
+	return project
+}
+
+// chatExamples returns an example for every completed reply of the chat rated up.
+func chatExamples(systemPrompt string, messages []*model.Message, ratings map[string]model.FeedbackRating) []*dtos.FineTuneChatExample {
+	var examples []*dtos.FineTuneChatExample
+	for i, message := range messages {
+		if message.Role != "assistant" || message.Status != model.MessageStatusCompleted || ratings[message.ID.String()] != model.FeedbackRatingUp {
+			continue
+		}
+		context := datasetContext(messages[:i])
+		if len(context) == 0 {
+			continue
+		}
+		example := &dtos.FineTuneChatExample{}
+		example.Messages = append(example.Messages, &dtos.GPTMessage{Role: "system", Content: systemPrompt})
+		example.Messages = append(example.Messages, context...)
+		example.Messages = append(example.Messages, &dtos.GPTMessage{Role: "assistant", Content: message.Content})
+		examples = append(examples, example)
+	}
+	return examples
+}
+
+// preferenceExamples pairs every reply rated up with a regenerated sibling of it, which is either
+// rated down or was implicitly rejected by the user regenerating it.
+func preferenceExamples(systemPrompt string, messages []*model.Message, ratings map[string]model.FeedbackRating) []*dtos.FineTunePreferenceExample {
+	var examples []*dtos.FineTunePreferenceExample
+	for _, group := range replyGroups(messages) {
+		if len(group.replies) < 2 {
+			continue
+		}
+		chosen, ok := lo.Find(group.replies, func(m *model.Message) bool {
+			return m.Status == model.MessageStatusCompleted && ratings[m.ID.String()] == model.FeedbackRatingUp
+		})
+		if !ok {
+			continue
+		}
+		candidates := lo.Filter(group.replies, func(m *model.Message, _ int) bool { return m != chosen && m.Content != "" })
+		rejected, ok := lo.Find(candidates, func(m *model.Message) bool { return ratings[m.ID.String()] == model.FeedbackRatingDown })
+		if !ok {
+			rejected, ok = lo.Find(candidates, func(m *model.Message) bool {
+				_, superseded := m.Metadata[model.MetadataSupersededBy]
+				return superseded && ratings[m.ID.String()] == 0
+			})
+		}
+		if !ok {
+			continue
+		}
+		context := datasetContext(messages[:group.index])
+		if len(context) == 0 {
+			continue
+		}
+
+		examples = append(examples, &dtos.FineTunePreferenceExample{
+			Input:              dtos.FineTuneInput{Messages: append([]*dtos.GPTMessage{{Role: "system", Content: systemPrompt}}, context...)},
+			PreferredOutput:    []*dtos.GPTMessage{{Role: "assistant", Content: chosen.Content}},
+			NonPreferredOutput: []*dtos.GPTMessage{{Role: "assistant", Content: rejected.Content}},
+		})
+	}
+	return examples
+}
+
+// replyGroup holds an assistant reply and all of its regenerations.
+type replyGroup struct {
+	// index of the original reply in the messages of the chat
+	index   int
+	replies []*model.Message
+}
+
+// replyGroups groups the assistant replies of the chat by the reply they were regenerated from, in the order of the chat.
+func replyGroups(messages []*model.Message) []*replyGroup {
+	indexes := map[string]int{}
+	for i, m := range messages {
+		indexes[m.ID.String()] = i
+	}
+
+	var groups []*replyGroup
+	byRoot := map[int]*replyGroup{}
+	for i, m := range messages {
+		if m.Role != "assistant" {
+			continue
+		}
+		root := i
+		// the number of steps is bounded in case the links form a cycle
+		for step := 0; step < len(messages); step++ {
+			parent, ok := indexes[messages[root].Metadata[model.MetadataRegeneratedFrom]]
+			if !ok {
+				break
+			}
+			root = parent
+		}
+		group, ok := byRoot[root]
+		if !ok {
+			group = &replyGroup{index: root}
+			byRoot[root] = group
+			groups = append(groups, group)
+		}
+		group.replies = append(group.replies, m)
+	}
+	return groups
+}
+
+// datasetContext returns the last active messages of the history, which must end with a user message.
+func datasetContext(history []*model.Message) []*dtos.GPTMessage {
+	history = activeMessages(history)
+	if len(history) == 0 || history[len(history)-1].Role != "user" {
+		return nil
+	}
+	if len(history) > datasetContextMessages {
+		history = history[len(history)-datasetContextMessages:]
+	}
+	return lo.Map(history, func(m *model.Message, _ int) *dtos.GPTMessage {
+		return &dtos.GPTMessage{Role: m.Role, Content: m.Content}
+	})
+}
+
+// datasetSplit assigns the chat to a split by the hash of its id, so all the examples of a chat
+// stay in the same split and repeated exports never move a chat between the splits.
+func datasetSplit(chatID string, validationRatio float64) model.DatasetSplit {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(chatID))
+	if float64(h.Sum32()%10000)/10000 < validationRatio {
+		return model.DatasetSplitValidation
+	}
+	return model.DatasetSplitTrain
+}
+
+func scrubMessages(messages []*dtos.GPTMessage) {
+	for _, m := range messages {
+		m.Content = scrubPII(m.Content)
+	}
+}
+
+// scrubPII masks the emails, card numbers, IP addresses and phone numbers of the text.
+func scrubPII(text string) string {
+	for _, p := range piiPatterns {
+		text = p.pattern.ReplaceAllString(text, p.mask)
+	}
+	return text
+}
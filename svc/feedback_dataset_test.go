@@ -0,0 +1,46 @@
+package svc
+
+import (
+	"testing"
+	"time"
+
+	"github.com/amahdian/ai-assistant-be/domain/model"
+	"github.com/amahdian/ai-assistant-be/domain/model/common"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFeedbackDataset(t *testing.T) {
+	at := time.Date(2024, 5, 1, 10, 30, 0, 0, time.UTC)
+	question := &model.Message{ID: uuid.New(), Role: "user", Content: "question", Status: model.MessageStatusCompleted, CreatedAt: at}
+	first := &model.Message{ID: uuid.New(), Role: "assistant", Content: "first", Status: model.MessageStatusCompleted, CreatedAt: at}
+	second := &model.Message{ID: uuid.New(), Role: "assistant", Content: "second", Status: model.MessageStatusCompleted, CreatedAt: at,
+		Metadata: common.Metadata{model.MetadataRegeneratedFrom: first.ID.String()}}
+	first.Metadata = common.Metadata{model.MetadataSupersededBy: second.ID.String()}
+	messages := []*model.Message{question, first, second}
+	ratings := map[string]model.FeedbackRating{second.ID.String(): model.FeedbackRatingUp}
+
+	chat := chatExamples("system", messages, ratings)
+	require.Len(t, chat, 1)
+	require.Len(t, chat[0].Messages, 3)
+	require.Equal(t, "question", chat[0].Messages[1].Content)
+	require.Equal(t, "second", chat[0].Messages[2].Content)
+
+	pairs := preferenceExamples("system", messages, ratings)
+	require.Len(t, pairs, 1)
+	require.Equal(t, "second", pairs[0].PreferredOutput[0].Content)
+	require.Equal(t, "first", pairs[0].NonPreferredOutput[0].Content)
+	require.Len(t, pairs[0].Input.Messages, 2)
+
+	require.Empty(t, preferenceExamples("system", messages, map[string]model.FeedbackRating{}))
+
+	id := uuid.NewString()
+	require.Equal(t, datasetSplit(id, 0.5), datasetSplit(id, 0.5))
+	require.Equal(t, model.DatasetSplitTrain, datasetSplit(id, 0))
+	require.Equal(t, model.DatasetSplitValidation, datasetSplit(id, 1))
+
+	require.Equal(t,
+		"mail [EMAIL], call [PHONE] or [PHONE], card [CARD] from [IP]",
+		scrubPII("mail jane.doe@example.com, call +1 (555) 123-4567 or 555-123-4567, card 4111 1111 1111 1111 from 192.168.0.1"))
+	require.Equal(t, "released in 2024-05-01", scrubPII("released in 2024-05-01"))
+}
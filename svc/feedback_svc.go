@@ -0,0 +1,174 @@
+package svc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/amahdian/ai-assistant-be/domain/model"
+	"github.com/amahdian/ai-assistant-be/global"
+	"github.com/amahdian/ai-assistant-be/global/errs"
+	"github.com/amahdian/ai-assistant-be/storage"
+	"github.com/samber/lo"
+)
+
+const (
+	// feedbackTopReasons is the number of complaint reasons listed per report row.
+	feedbackTopReasons = 3
+	// feedbackExamples is the number of negatively rated replies listed per report row.
+	feedbackExamples = 3
+	// feedbackExampleLength is the length in runes of the replies listed in the report.
+	feedbackExampleLength = 500
+	// maxFeedbackCommentLength is the maximum length of a feedback comment in runes.
+	maxFeedbackCommentLength = 2000
+)
+
+type FeedbackSvc interface {
+	// SetFeedback rates an assistant reply of the chat, replacing its previous rating.
+	SetFeedback(chatID, messageID string, feedback *model.MessageFeedback, user *model.User) (*model.MessageFeedback, error)
+	DeleteFeedback(chatID, messageID string, user *model.User) error
+	// Report aggregates the feedback of every user, it is meant for the maintainers of the app.
+	Report(filter *model.FeedbackReportFilter) ([]*model.FeedbackReportRow, error)
+	// ExportDataset writes the rated replies matching the filter as JSONL examples for fine-tuning.
+	ExportDataset(filter *model.FeedbackReportFilter, options *model.DatasetOptions) ([]byte, error)
+}
+
+type feedbackSvc struct {
+	ctx context.Context
+	stg storage.Storage
+}
+
+func newFeedbackSvc(ctx context.Context, stg storage.Storage) FeedbackSvc {
+	return &feedbackSvc{
+		ctx: ctx,
+		stg: stg,
+	}
+}
+
+func (s *feedbackSvc) SetFeedback(chatID, messageID string, feedback *model.MessageFeedback, user *model.User) (*model.MessageFeedback, error) {
+	if err := validateFeedback(feedback); err != nil {
+		return nil, err
+	}
+	chat, message, err := s.findReply(chatID, messageID, user)
+	if err != nil {
+		return nil, err
+	}
+
+	feedback.MessageID = messageID
+	feedback.ChatID = chatID
+	feedback.UserId = user.ID.String()
+	feedback.Reasons = lo.Uniq(append([]string{}, feedback.Reasons...))
+	feedback.Comment = strings.TrimSpace(feedback.Comment)
+	feedback.Agent = chat.Agent
+	if feedback.Agent == "" {
+		feedback.Agent = model.DefaultAgent.Name
+	}
+	feedback.Model = message.Metadata[model.MetadataModel]
+	feedback.UpdatedAt = time.Now()
+
+	if err = s.stg.Feedback(s.ctx).Upsert(feedback); err != nil {
+		return nil, errs.Wrapf(err, "failed to save feedback")
+	}
+	return feedback, nil
+}
+
+func (s *feedbackSvc) DeleteFeedback(chatID, messageID string, user *model.User) error {
+	if _, _, err := s.findReply(chatID, messageID, user); err != nil {
+		return err
+	}
+	if err := s.stg.Feedback(s.ctx).DeleteByMessageId(messageID); err != nil {
+		return errs.Wrapf(err, "failed to delete feedback")
+	}
+	return nil
+}
+
+func (s *feedbackSvc) Report(filter *model.FeedbackReportFilter) ([]*model.FeedbackReportRow, error) {
+	if filter.Period == "" {
+		filter.Period = global.MONTHLY
+	}
+	if !lo.Contains(global.PeriodTypeValues(), filter.Period) {
+		return nil, errs.Newf(errs.InvalidArgument, nil, "Unsupported period %q.", filter.Period)
+	}
+
+	feedbackStg := s.stg.Feedback(s.ctx)
+	rows, err := feedbackStg.ReportRows(filter)
+	if err != nil {
+		return nil, errs.Wrapf(err, "failed to aggregate feedback")
+	}
+	reasons, err := feedbackStg.ReportReasons(filter)
+	if err != nil {
+		return nil, errs.Wrapf(err, "failed to aggregate feedback reasons")
+	}
+	examples, err := feedbackStg.ReportExamples(filter, feedbackExamples)
+	if err != nil {
+		return nil, errs.Wrapf(err, "failed to list feedback examples")
+	}
+
+	return mergeReport(rows, reasons, examples), nil
+}
+
+// mergeReport adds the top reasons and the examples to the report rows of their period, agent and model.
+// The reasons must be sorted by their count.
+func mergeReport(rows []*model.FeedbackReportRow, reasons []*model.FeedbackReasonCount, examples []*model.FeedbackExample) []*model.FeedbackReportRow {
+	byKey := lo.KeyBy(rows, func(r *model.FeedbackReportRow) string { return reportKey(r.Period, r.Agent, r.Model) })
+	for _, row := range rows {
+		row.ApprovalRate = float64(row.Positive) / float64(row.Total)
+		row.TopReasons = []*model.FeedbackReasonCount{}
+		row.Examples = []*model.FeedbackExample{}
+	}
+	for _, reason := range reasons {
+		if row, ok := byKey[reportKey(reason.Period, reason.Agent, reason.Model)]; ok && len(row.TopReasons) < feedbackTopReasons {
+			row.TopReasons = append(row.TopReasons, reason)
+		}
+	}
+	for _, example := range examples {
+		if row, ok := byKey[reportKey(example.Period, example.Agent, example.Model)]; ok {
+			example.Content = truncateRunes(example.Content, feedbackExampleLength)
+			row.Examples = append(row.Examples, example)
+		}
+	}
+	return rows
+}
+
+// findReply returns the chat and the assistant reply the user can rate.
+func (s *feedbackSvc) findReply(chatID, messageID string, user *model.User) (*model.Chat, *model.Message, error) {
+	chat, err := s.stg.Chat(s.ctx).FindById(chatID)
+	if err != nil {
+		return nil, nil, errs.Wrapf(err, "failed to find chat")
+	}
+	if chat.UserId != user.ID.String() {
+		return nil, nil, errors.New("permission denied")
+	}
+	message, err := s.stg.Message(s.ctx).FindById(messageID)
+	if err != nil {
+		return nil, nil, errs.Wrapf(err, "failed to find message")
+	}
+	if message.ChatID != chatID {
+		return nil, nil, errs.Newf(errs.NotFound, nil, "Message %q could not be found in chat %q.", messageID, chatID)
+	}
+	if message.Role != "assistant" {
+		return nil, nil, errs.Newf(errs.InvalidArgument, nil, "Only the replies of the assistant can be rated.")
+	}
+	return chat, message, nil
+}
+
+func validateFeedback(feedback *model.MessageFeedback) error {
+	if feedback.Rating != model.FeedbackRatingUp && feedback.Rating != model.FeedbackRatingDown {
+		return errs.Newf(errs.InvalidArgument, nil, "The rating must be %d or %d.", model.FeedbackRatingUp, model.FeedbackRatingDown)
+	}
+	for _, reason := range feedback.Reasons {
+		if !lo.Contains(model.FeedbackReasons, reason) {
+			return errs.Newf(errs.InvalidArgument, nil, "Unknown feedback reason %q.", reason)
+		}
+	}
+	if len([]rune(feedback.Comment)) > maxFeedbackCommentLength {
+		return errs.Newf(errs.InvalidArgument, nil, "The comment can not be longer than %d characters.", maxFeedbackCommentLength)
+	}
+	return nil
+}
+
+func reportKey(period time.Time, agent, modelName string) string {
+	return fmt.Sprintf("%d|%s|%s", period.Unix(), agent, modelName)
+}
@@ -0,0 +1,63 @@
+package svc
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/amahdian/ai-assistant-be/domain/model"
+	"github.com/amahdian/ai-assistant-be/global/errs"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateFeedback(t *testing.T) {
+	require.NoError(t, validateFeedback(&model.MessageFeedback{Rating: model.FeedbackRatingUp}))
+	require.NoError(t, validateFeedback(&model.MessageFeedback{Rating: model.FeedbackRatingDown, Reasons: []string{"inaccurate", "too_long"}}))
+
+	invalid := []*model.MessageFeedback{
+		{},
+		{Rating: 2},
+		{Rating: model.FeedbackRatingDown, Reasons: []string{"boring"}},
+		{Rating: model.FeedbackRatingDown, Comment: strings.Repeat("a", maxFeedbackCommentLength+1)},
+	}
+	for _, feedback := range invalid {
+		require.Equal(t, errs.InvalidArgument, errs.Code(validateFeedback(feedback)))
+	}
+}
+
+func TestMergeReport(t *testing.T) {
+	may := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	june := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	rows := []*model.FeedbackReportRow{
+		{Period: june, Agent: "default", Model: "gpt-4o", Total: 4, Positive: 3, Negative: 1},
+		{Period: may, Agent: "default", Model: "gpt-4o", Total: 2, Positive: 0, Negative: 2},
+	}
+	reasons := []*model.FeedbackReasonCount{
+		{Period: may, Agent: "default", Model: "gpt-4o", Reason: "inaccurate", Count: 5},
+		{Period: may, Agent: "default", Model: "gpt-4o", Reason: "unhelpful", Count: 4},
+		{Period: may, Agent: "default", Model: "gpt-4o", Reason: "incomplete", Count: 3},
+		{Period: may, Agent: "default", Model: "gpt-4o", Reason: "other", Count: 2},
+		{Period: june, Agent: "default", Model: "gpt-4o", Reason: "too_long", Count: 1},
+		// rows which are not in the report are skipped
+		{Period: june, Agent: "coder", Model: "gpt-4o", Reason: "harmful", Count: 1},
+	}
+	examples := []*model.FeedbackExample{
+		{Period: may, Agent: "default", Model: "gpt-4o", MessageID: "m1", Content: strings.Repeat("x", feedbackExampleLength+10)},
+		{Period: june, Agent: "coder", Model: "gpt-4o", MessageID: "m2"},
+	}
+
+	report := mergeReport(rows, reasons, examples)
+	require.Len(t, report, 2)
+
+	require.InDelta(t, 0.75, report[0].ApprovalRate, 1e-9)
+	require.Len(t, report[0].TopReasons, 1)
+	require.Equal(t, "too_long", report[0].TopReasons[0].Reason)
+	require.NotNil(t, report[0].Examples)
+	require.Empty(t, report[0].Examples)
+
+	require.Zero(t, report[1].ApprovalRate)
+	require.Len(t, report[1].TopReasons, feedbackTopReasons)
+	require.Equal(t, "inaccurate", report[1].TopReasons[0].Reason)
+	require.Len(t, report[1].Examples, 1)
+	require.Len(t, []rune(report[1].Examples[0].Content), feedbackExampleLength)
+}
@@ -0,0 +1,107 @@
+package svc
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/amahdian/ai-assistant-be/domain/model"
+	"github.com/amahdian/ai-assistant-be/global/errs"
+	"github.com/amahdian/ai-assistant-be/storage"
+)
+
+type FolderSvc interface {
+	ListFolders(user *model.User) ([]*model.Folder, error)
+	CreateFolder(name string, user *model.User) (*model.Folder, error)
+	RenameFolder(id, name string, user *model.User) (*model.Folder, error)
+	DeleteFolder(id string, user *model.User) error
+	// MoveChats moves the chats to the folder, an empty folder id moves them out of any folder.
+	MoveChats(chatIds []string, folderId string, user *model.User) error
+}
+
+type folderSvc struct {
+	ctx context.Context
+	stg storage.Storage
+}
+
+func newFolderSvc(ctx context.Context, stg storage.Storage) FolderSvc {
+	return &folderSvc{
+		ctx: ctx,
+		stg: stg,
+	}
+}
+
+func (s *folderSvc) ListFolders(user *model.User) ([]*model.Folder, error) {
+	folders, err := s.stg.Folder(s.ctx).ListByUserId(user.ID.String())
+	if err != nil {
+		return nil, errs.Wrapf(err, "failed to list folders")
+	}
+	return folders, nil
+}
+
+func (s *folderSvc) CreateFolder(name string, user *model.User) (*model.Folder, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, errs.Newf(errs.InvalidArgument, nil, "folder name must not be empty")
+	}
+	folder := &model.Folder{
+		UserId: user.ID.String(),
+		Name:   name,
+	}
+	if err := s.stg.Folder(s.ctx).CreateOne(folder); err != nil {
+		return nil, errs.Wrapf(err, "failed to create folder")
+	}
+	return folder, nil
+}
+
+func (s *folderSvc) RenameFolder(id, name string, user *model.User) (*model.Folder, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, errs.Newf(errs.InvalidArgument, nil, "folder name must not be empty")
+	}
+	folder, err := s.findFolder(id, user)
+	if err != nil {
+		return nil, err
+	}
+	folder.Name = name
+	if err = s.stg.Folder(s.ctx).UpdateOne(folder, false); err != nil {
+		return nil, errs.Wrapf(err, "failed to rename folder")
+	}
+	return folder, nil
+}
+
+// DeleteFolder deletes the folder, its chats are moved out of it rather than deleted.
+func (s *folderSvc) DeleteFolder(id string, user *model.User) error {
+	if _, err := s.findFolder(id, user); err != nil {
+		return err
+	}
+	return s.stg.Folder(s.ctx).DeleteById(id)
+}
+
+func (s *folderSvc) MoveChats(chatIds []string, folderId string, user *model.User) error {
+	var target *string
+	if folderId != "" {
+		if _, err := s.findFolder(folderId, user); err != nil {
+			return err
+		}
+		target = &folderId
+	}
+	if err := checkChatsOwnership(s.ctx, s.stg, chatIds, user); err != nil {
+		return err
+	}
+	if err := s.stg.Chat(s.ctx).SetFolder(chatIds, target); err != nil {
+		return errs.Wrapf(err, "failed to move chats")
+	}
+	return nil
+}
+
+func (s *folderSvc) findFolder(id string, user *model.User) (*model.Folder, error) {
+	folder, err := s.stg.Folder(s.ctx).FindById(id)
+	if err != nil {
+		return nil, errs.Wrapf(err, "failed to find folder")
+	}
+	if folder.UserId != user.ID.String() {
+		return nil, errors.New("permission denied")
+	}
+	return folder, nil
+}
@@ -0,0 +1,391 @@
+package svc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/amahdian/ai-assistant-be/clients/dtos"
+	"github.com/amahdian/ai-assistant-be/domain/model"
+	"github.com/amahdian/ai-assistant-be/pkg/logger"
+)
+
+const (
+	// finishedGenerationRetention is how long a finished generation is kept around
+	// so clients that lost their connection near the end can still replay the tail.
+	finishedGenerationRetention = time.Minute
+
+	// generationTimeout bounds the lifetime of a generation since it is no longer tied to a request.
+	generationTimeout = 5 * time.Minute
+
+	// maxGenerationReplySize and maxGenerationChunks bound the buffer of a generation,
+	// a reply that does not fit is cut off and its generation fails.
+	maxGenerationReplySize = 256 << 10
+	maxGenerationChunks    = 16 << 10
+)
+
+var (
+	// errGenerationStopped is the cancellation cause of generations stopped by the user.
+	errGenerationStopped = errors.New("generation stopped")
+	// errGenerationTooLong is the cancellation cause of generations that outgrew their buffer.
+	errGenerationTooLong = errors.New("the reply does not fit in the generation buffer")
+)
+
+// generationBroker keeps track of the in-flight assistant generations.
+// It is shared by all the chat services, so a generation can be stopped or
+// subscribed to from any request and not only from the one that started it.
+//
+// Generations run detached from the HTTP writers: every generation buffers its
+// reply (up to maxGenerationReplySize), therefore subscribers can attach at any point (e.g. a second device or
+// a reconnecting client), replay what they missed and go away at any time
+// without ever blocking the generation.
+type generationBroker struct {
+	mu sync.Mutex
+	// chat id -> assistant message id -> generation
+	generations map[string]map[string]*generation
+}
+
+type generation struct {
+	chatID    string
+	messageID string
+	startedAt time.Time
+	cancel    context.CancelCauseFunc
+
+	mu sync.Mutex
+	// the buffer only holds the reply and the boundaries of its chunks,
+	// chunk i (1-based) spans reply[offsets[i-1]:offsets[i]].
+	reply   strings.Builder
+	offsets []int
+	// events sent after the terminal event, e.g. the follow-up suggestions
+	trailers []*generationTrailer
+	status   model.MessageStatus
+	// why the generation failed, sent with the terminal event
+	failure string
+	done    bool
+	// set once the trailers are sent too, the subscriptions end then
+	closed bool
+	// closed and replaced on every change to wake up the subscribers
+	changed chan struct{}
+}
+
+type generationTrailer struct {
+	event   string
+	content string
+}
+
+func newGenerationBroker() *generationBroker {
+	return &generationBroker{
+		generations: make(map[string]map[string]*generation),
+	}
+}
+
+// finishedGeneration replays a saved reply as a finished generation, it is not tracked by any broker.
+func finishedGeneration(message *model.Message) *generation {
+	g := &generation{
+		chatID:    message.ChatID,
+		messageID: message.ID.String(),
+		startedAt: message.CreatedAt,
+		cancel:    func(error) {},
+		changed:   make(chan struct{}),
+	}
+	if message.Content != "" {
+		g.publish(message.Content)
+	}
+	g.status = message.Status
+	g.done = true
+	if suggestions, ok := message.Metadata[model.MetadataSuggestions]; ok {
+		g.publishTrailer(model.StreamEventSuggestions, suggestions)
+	}
+	g.closed = true
+	return g
+}
+
+func (b *generationBroker) start(chatID, messageID string, cancel context.CancelCauseFunc) *generation {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	g := &generation{
+		chatID:    chatID,
+		messageID: messageID,
+		startedAt: time.Now(),
+		cancel:    cancel,
+		changed:   make(chan struct{}),
+	}
+	if _, ok := b.generations[chatID]; !ok {
+		b.generations[chatID] = make(map[string]*generation)
+	}
+	b.generations[chatID][messageID] = g
+	return g
+}
+
+// run consumes the upstream stream into the generation until it ends or ctx is done,
+// persists the (possibly partial) reply and finishes the generation. A nil stream fails the generation.
+// followUp, if set, runs after the terminal event and may publish trailers, e.g. the follow-up suggestions.
+// It never blocks on the subscribers.
+func (b *generationBroker) run(ctx context.Context, g *generation, stream <-chan *dtos.GPTStreamResult,
+	persist func(reply string, status model.MessageStatus) error, followUp func(reply string, status model.MessageStatus)) {
+	var streamErr error
+	if stream == nil {
+		// ranging over a nil channel would block forever
+		streamErr = errors.New("the stream did not open")
+	} else {
+		for result := range stream {
+			if result.Err != nil {
+				streamErr = result.Err
+				continue
+			}
+			if !g.publish(result.Content) {
+				// keep draining so the upstream is not left blocked until it notices the cancellation
+				g.cancel(errGenerationTooLong)
+			}
+		}
+	}
+
+	status := model.MessageStatusCompleted
+	failure := ""
+	switch {
+	case errors.Is(context.Cause(ctx), errGenerationStopped):
+		status = model.MessageStatusStopped
+	case ctx.Err() != nil || streamErr != nil:
+		status = model.MessageStatusFailed
+		failure = "The reply could not be generated."
+		logger.Errorf("generation of message %s failed: %v", g.messageID, errors.Join(context.Cause(ctx), streamErr))
+	}
+
+	if err := persist(g.content(), status); err != nil {
+		logger.Errorf("failed to save assistant message %s: %v", g.messageID, err)
+		status = model.MessageStatusFailed
+		failure = "The reply could not be saved."
+	}
+	b.finish(g, status, failure)
+	if followUp != nil {
+		followUp(g.content(), status)
+	}
+	b.close(g)
+}
+
+// finish publishes the terminal event of the generation.
+func (b *generationBroker) finish(g *generation, status model.MessageStatus, failure string) {
+	g.mu.Lock()
+	g.status = status
+	g.failure = failure
+	g.done = true
+	close(g.changed)
+	g.changed = make(chan struct{})
+	g.mu.Unlock()
+
+	g.cancel(nil)
+}
+
+// close ends the subscriptions of the finished generation and schedules its removal.
+func (b *generationBroker) close(g *generation) {
+	g.mu.Lock()
+	g.closed = true
+	close(g.changed)
+	g.mu.Unlock()
+
+	time.AfterFunc(finishedGenerationRetention, func() {
+		b.remove(g)
+	})
+}
+
+func (b *generationBroker) remove(g *generation) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	delete(b.generations[g.chatID], g.messageID)
+	if len(b.generations[g.chatID]) == 0 {
+		delete(b.generations, g.chatID)
+	}
+}
+
+// find returns the generation of the given message in the chat.
+// If messageID is empty the most recent generation of the chat is returned.
+func (b *generationBroker) find(chatID, messageID string) *generation {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if messageID != "" {
+		return b.generations[chatID][messageID]
+	}
+	var latest *generation
+	for _, g := range b.generations[chatID] {
+		if latest == nil || g.startedAt.After(latest.startedAt) {
+			latest = g
+		}
+	}
+	return latest
+}
+
+// stop cancels the generation of the given message in the chat.
+// If messageID is empty all the generations of the chat are cancelled.
+// It reports whether any running generation was found.
+func (b *generationBroker) stop(chatID, messageID string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	stopped := false
+	for id, g := range b.generations[chatID] {
+		if messageID != "" && id != messageID {
+			continue
+		}
+		if g.isDone() {
+			continue
+		}
+		g.cancel(errGenerationStopped)
+		stopped = true
+	}
+	return stopped
+}
+
+// publish appends the chunk to the reply and wakes up the subscribers.
+// It reports whether the chunk fit in the buffer of the generation.
+func (g *generation) publish(chunk string) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	// the chunks can not follow the terminal event
+	if g.done {
+		return true
+	}
+	if g.reply.Len()+len(chunk) > maxGenerationReplySize || len(g.offsets) >= maxGenerationChunks {
+		return false
+	}
+	g.reply.WriteString(chunk)
+	g.offsets = append(g.offsets, g.reply.Len())
+
+	close(g.changed)
+	g.changed = make(chan struct{})
+	return true
+}
+
+// publishTrailer adds an event after the terminal event, it is ignored before the generation is done or once it is closed.
+func (g *generation) publishTrailer(event, content string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if !g.done || g.closed {
+		return
+	}
+	g.trailers = append(g.trailers, &generationTrailer{event: event, content: content})
+
+	close(g.changed)
+	g.changed = make(chan struct{})
+}
+
+func (g *generation) content() string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.reply.String()
+}
+
+func (g *generation) isDone() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.done
+}
+
+// eventsAfter returns the events after the given sequence number, whether the
+// generation is closed and a channel that is closed on the next change.
+func (g *generation) eventsAfter(seq int) ([]*model.StreamedMessage, bool, <-chan struct{}) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	// chunk events come first, then the terminal event and finally the trailers
+	last := len(g.offsets)
+	if g.done {
+		last += 1 + len(g.trailers)
+	}
+	if seq < 0 || seq > last {
+		seq = last
+	}
+
+	reply := g.reply.String()
+	events := make([]*model.StreamedMessage, 0)
+	for i := seq + 1; i <= len(g.offsets); i++ {
+		start := 0
+		if i > 1 {
+			start = g.offsets[i-2]
+		}
+		events = append(events, g.event(i, model.StreamEventMessage, reply[start:g.offsets[i-1]], nil))
+	}
+	if doneSeq := len(g.offsets) + 1; g.done && doneSeq > seq {
+		metadata := map[string]string{"status": string(g.status)}
+		if g.failure != "" {
+			metadata["error"] = g.failure
+		}
+		events = append(events, g.event(doneSeq, model.StreamEventDone, "", metadata))
+	}
+	for i, trailer := range g.trailers {
+		if trailerSeq := len(g.offsets) + i + 2; trailerSeq > seq {
+			events = append(events, g.event(trailerSeq, trailer.event, trailer.content, nil))
+		}
+	}
+	return events, g.closed, g.changed
+}
+
+func (g *generation) event(seq int, event, content string, metadata map[string]string) *model.StreamedMessage {
+	if metadata == nil {
+		metadata = make(map[string]string)
+	}
+	metadata["message_id"] = g.messageID
+	return &model.StreamedMessage{
+		ID:       formatStreamEventID(g.messageID, seq),
+		Event:    event,
+		Content:  content,
+		Metadata: metadata,
+	}
+}
+
+// subscribe streams the events of the generation after the given sequence number.
+// The returned channel is closed when the generation is closed or ctx is cancelled,
+// so abandoned subscriptions never outlive their request.
+func (g *generation) subscribe(ctx context.Context, afterSeq int) <-chan *model.StreamedMessage {
+	out := make(chan *model.StreamedMessage)
+	go func() {
+		defer close(out)
+		seq := afterSeq
+		for {
+			events, done, changed := g.eventsAfter(seq)
+			for _, e := range events {
+				select {
+				case out <- e:
+					seq++
+				case <-ctx.Done():
+					return
+				}
+			}
+			if done {
+				return
+			}
+			select {
+			case <-changed:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out
+}
+
+func formatStreamEventID(messageID string, seq int) string {
+	return fmt.Sprintf("%s:%d", messageID, seq)
+}
+
+// parseStreamEventID parses the ids generated by formatStreamEventID,
+// e.g. the Last-Event-ID header sent by reconnecting SSE clients.
+func parseStreamEventID(id string) (messageID string, seq int, ok bool) {
+	messageID, seqStr, found := strings.Cut(id, ":")
+	if !found || messageID == "" {
+		return "", 0, false
+	}
+	seq, err := strconv.Atoi(seqStr)
+	if err != nil || seq < 0 {
+		return "", 0, false
+	}
+	return messageID, seq, true
+}
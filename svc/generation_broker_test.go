@@ -0,0 +1,211 @@
+package svc
+
+import (
+	"context"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/amahdian/ai-assistant-be/clients/dtos"
+	"github.com/amahdian/ai-assistant-be/domain/model"
+	"github.com/amahdian/ai-assistant-be/domain/model/common"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+type persisted struct {
+	reply  string
+	status model.MessageStatus
+}
+
+func collect(ch <-chan *model.StreamedMessage) []*model.StreamedMessage {
+	var events []*model.StreamedMessage
+	for e := range ch {
+		events = append(events, e)
+	}
+	return events
+}
+
+func startTestGeneration(b *generationBroker, stream <-chan *dtos.GPTStreamResult) (*generation, context.CancelCauseFunc, <-chan persisted) {
+	ctx, cancel := context.WithCancelCause(context.Background())
+	gen := b.start("chat", "msg", cancel)
+	saved := make(chan persisted, 1)
+	go b.run(ctx, gen, stream, func(reply string, status model.MessageStatus) error {
+		saved <- persisted{reply: reply, status: status}
+		return nil
+	}, nil)
+	return gen, cancel, saved
+}
+
+func TestGenerationBroker_ResumeFromLastEventID(t *testing.T) {
+	b := newGenerationBroker()
+	stream := make(chan *dtos.GPTStreamResult)
+	gen, _, saved := startTestGeneration(b, stream)
+
+	stream <- &dtos.GPTStreamResult{Content: "Hello"}
+	stream <- &dtos.GPTStreamResult{Content: " world"}
+
+	// a second subscriber attaches mid generation and receives everything from the beginning
+	live := gen.subscribe(context.Background(), 0)
+
+	stream <- &dtos.GPTStreamResult{Content: "!"}
+	close(stream)
+
+	events := collect(live)
+	require.Len(t, events, 4)
+	require.Equal(t, "msg:1", events[0].ID)
+	require.Equal(t, model.StreamEventDone, events[3].Event)
+	require.Equal(t, string(model.MessageStatusCompleted), events[3].Metadata["status"])
+	require.Equal(t, persisted{reply: "Hello world!", status: model.MessageStatusCompleted}, <-saved)
+
+	messageID, seq, ok := parseStreamEventID(events[1].ID)
+	require.True(t, ok)
+	require.Equal(t, "msg", messageID)
+
+	resumed := collect(b.find("chat", messageID).subscribe(context.Background(), seq))
+	require.Len(t, resumed, 2)
+	require.Equal(t, "!", resumed[0].Content)
+}
+
+func TestGenerationBroker_Stop(t *testing.T) {
+	b := newGenerationBroker()
+	stream := make(chan *dtos.GPTStreamResult)
+	_, cancel, saved := startTestGeneration(b, stream)
+
+	stream <- &dtos.GPTStreamResult{Content: "partial"}
+	require.True(t, b.stop("chat", ""))
+	// mimic the client which closes the stream once its context is cancelled
+	close(stream)
+
+	require.Equal(t, persisted{reply: "partial", status: model.MessageStatusStopped}, <-saved)
+	require.False(t, b.stop("chat", ""))
+	cancel(nil)
+}
+
+func TestGenerationBroker_StreamNeverOpened(t *testing.T) {
+	b := newGenerationBroker()
+	gen, cancel, saved := startTestGeneration(b, nil)
+	defer cancel(nil)
+
+	select {
+	case p := <-saved:
+		require.Equal(t, persisted{status: model.MessageStatusFailed}, p)
+	case <-time.After(time.Second):
+		t.Fatal("the generation did not finish")
+	}
+
+	events := collect(gen.subscribe(context.Background(), 0))
+	require.Len(t, events, 1)
+	require.Equal(t, model.StreamEventDone, events[0].Event)
+	require.Equal(t, string(model.MessageStatusFailed), events[0].Metadata["status"])
+}
+
+func TestGenerationBroker_BufferIsBounded(t *testing.T) {
+	b := newGenerationBroker()
+	stream := make(chan *dtos.GPTStreamResult)
+	gen, cancel, saved := startTestGeneration(b, stream)
+	defer cancel(nil)
+
+	full := strings.Repeat("x", maxGenerationReplySize)
+	stream <- &dtos.GPTStreamResult{Content: full}
+	stream <- &dtos.GPTStreamResult{Content: "y"}
+	close(stream)
+
+	// the reply is cut off at the size of the buffer and the generation fails
+	require.Equal(t, persisted{reply: full, status: model.MessageStatusFailed}, <-saved)
+	events := collect(gen.subscribe(context.Background(), 0))
+	require.Len(t, events, 2)
+	require.Equal(t, string(model.MessageStatusFailed), events[1].Metadata["status"])
+}
+
+func TestGenerationBroker_Trailers(t *testing.T) {
+	b := newGenerationBroker()
+	stream := make(chan *dtos.GPTStreamResult)
+	ctx, cancel := context.WithCancelCause(context.Background())
+	gen := b.start("chat", "msg", cancel)
+	suggest := make(chan struct{})
+	go b.run(ctx, gen, stream, func(reply string, status model.MessageStatus) error {
+		// trailers can not be published before the terminal event
+		gen.publishTrailer(model.StreamEventSuggestions, `["early?"]`)
+		return nil
+	}, func(reply string, status model.MessageStatus) {
+		<-suggest
+		gen.publishTrailer(model.StreamEventSuggestions, `["why?"]`)
+	})
+
+	stream <- &dtos.GPTStreamResult{Content: "answer"}
+	close(stream)
+
+	// the terminal event does not wait for the follow-up
+	sub := gen.subscribe(context.Background(), 0)
+	require.Equal(t, model.StreamEventMessage, (<-sub).Event)
+	done := <-sub
+	require.Equal(t, model.StreamEventDone, done.Event)
+	require.Equal(t, "msg:2", done.ID)
+	close(suggest)
+
+	events := collect(sub)
+	require.Len(t, events, 1)
+	require.Equal(t, model.StreamEventSuggestions, events[0].Event)
+	require.Equal(t, `["why?"]`, events[0].Content)
+	require.Equal(t, "msg:3", events[0].ID)
+
+	resumed := collect(gen.subscribe(context.Background(), 2))
+	require.Len(t, resumed, 1)
+	require.Equal(t, "msg:3", resumed[0].ID)
+	require.Empty(t, collect(gen.subscribe(context.Background(), 3)))
+}
+
+func TestGenerationBroker_NoGoroutineLeakOnDisconnect(t *testing.T) {
+	baseline := runtime.NumGoroutine()
+
+	b := newGenerationBroker()
+	stream := make(chan *dtos.GPTStreamResult)
+	gen, _, saved := startTestGeneration(b, stream)
+
+	// several clients subscribe and abruptly go away while the generation is still running
+	for i := 0; i < 10; i++ {
+		ctx, disconnect := context.WithCancel(context.Background())
+		sub := gen.subscribe(ctx, 0)
+		stream <- &dtos.GPTStreamResult{Content: "x"}
+		<-sub
+		disconnect()
+	}
+	close(stream)
+
+	// the generation still completes and persists the whole reply without anyone listening
+	require.Equal(t, persisted{reply: "xxxxxxxxxx", status: model.MessageStatusCompleted}, <-saved)
+
+	// polling by hand since require.Eventually runs its own goroutines
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > baseline && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	require.LessOrEqual(t, runtime.NumGoroutine(), baseline, "goroutines leaked")
+}
+
+func TestFinishedGeneration(t *testing.T) {
+	message := &model.Message{
+		ID:       uuid.New(),
+		ChatID:   "chat",
+		Content:  "answer",
+		Status:   model.MessageStatusCompleted,
+		Metadata: common.Metadata{model.MetadataSuggestions: `["why?"]`},
+	}
+
+	events := collect(finishedGeneration(message).subscribe(context.Background(), 0))
+	require.Len(t, events, 3)
+	require.Equal(t, "answer", events[0].Content)
+	require.Equal(t, model.StreamEventDone, events[1].Event)
+	require.Equal(t, string(model.MessageStatusCompleted), events[1].Metadata["status"])
+	require.Equal(t, message.ID.String(), events[1].Metadata["message_id"])
+	require.Equal(t, model.StreamEventSuggestions, events[2].Event)
+}
+
+func TestParseStreamEventID(t *testing.T) {
+	for _, id := range []string{"", "msg", ":1", "msg:", "msg:-1", "msg:x"} {
+		_, _, ok := parseStreamEventID(id)
+		require.False(t, ok, id)
+	}
+}
@@ -0,0 +1,13 @@
+package svc
+
+import (
+	"os"
+	"testing"
+
+	"github.com/amahdian/ai-assistant-be/global/test"
+)
+
+func TestMain(m *testing.M) {
+	test.SetupTestingEnv()
+	os.Exit(m.Run())
+}
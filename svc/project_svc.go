@@ -0,0 +1,245 @@
+package svc
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/amahdian/ai-assistant-be/domain/model"
+	"github.com/amahdian/ai-assistant-be/global/errs"
+	"github.com/amahdian/ai-assistant-be/pkg/fileutil"
+	"github.com/amahdian/ai-assistant-be/storage"
+)
+
+const (
+	maxProjectFileSize = 5 << 20
+	// projectChunkLength and projectChunkOverlap are in runes, the overlap keeps sentences cut at a chunk border searchable.
+	projectChunkLength  = 1500
+	projectChunkOverlap = 200
+)
+
+type ProjectSvc interface {
+	ListProjects(user *model.User) ([]*model.Project, error)
+	GetProject(id string, user *model.User) (*model.Project, error)
+	CreateProject(project *model.Project, user *model.User) (*model.Project, error)
+	UpdateProject(id string, project *model.Project, user *model.User) (*model.Project, error)
+	DeleteProject(id string, user *model.User) error
+	AddFile(projectID string, file *fileutil.File, user *model.User) (*model.ProjectFile, error)
+	DeleteFile(projectID, fileID string, user *model.User) error
+}
+
+type projectSvc struct {
+	ctx        context.Context
+	stg        storage.Storage
+	embeddings *embeddingIndexer
+}
+
+func newProjectSvc(ctx context.Context, stg storage.Storage, embeddings *embeddingIndexer) ProjectSvc {
+	return &projectSvc{
+		ctx:        ctx,
+		stg:        stg,
+		embeddings: embeddings,
+	}
+}
+
+func (s *projectSvc) ListProjects(user *model.User) ([]*model.Project, error) {
+	projects, err := s.stg.Project(s.ctx).ListByUserId(user.ID.String())
+	if err != nil {
+		return nil, errs.Wrapf(err, "failed to list projects")
+	}
+	return projects, nil
+}
+
+func (s *projectSvc) GetProject(id string, user *model.User) (*model.Project, error) {
+	project, err := s.findProject(id, user)
+	if err != nil {
+		return nil, err
+	}
+	project.Files, err = s.stg.ProjectFile(s.ctx).ListByProjectId(id)
+	if err != nil {
+		return nil, errs.Wrapf(err, "failed to list project files")
+	}
+	return project, nil
+}
+
+func (s *projectSvc) CreateProject(project *model.Project, user *model.User) (*model.Project, error) {
+	if err := validateProject(project); err != nil {
+		return nil, err
+	}
+	newProject := &model.Project{
+		UserId:       user.ID.String(),
+		Name:         project.Name,
+		Instructions: project.Instructions,
+		DefaultAgent: project.DefaultAgent,
+	}
+	if err := s.stg.Project(s.ctx).CreateOne(newProject); err != nil {
+		return nil, errs.Wrapf(err, "failed to create project")
+	}
+	return newProject, nil
+}
+
+func (s *projectSvc) UpdateProject(id string, project *model.Project, user *model.User) (*model.Project, error) {
+	if err := validateProject(project); err != nil {
+		return nil, err
+	}
+	existing, err := s.findProject(id, user)
+	if err != nil {
+		return nil, err
+	}
+	existing.Name = project.Name
+	existing.Instructions = project.Instructions
+	existing.DefaultAgent = project.DefaultAgent
+	if err = s.stg.Project(s.ctx).UpdateOne(existing, true); err != nil {
+		return nil, errs.Wrapf(err, "failed to update project")
+	}
+	return existing, nil
+}
+
+// DeleteProject deletes the project and its files, its chats are kept outside of any project.
+func (s *projectSvc) DeleteProject(id string, user *model.User) error {
+	if _, err := s.findProject(id, user); err != nil {
+		return err
+	}
+	return s.stg.Project(s.ctx).DeleteById(id)
+}
+
+// AddFile attaches a text file to the project. The file is split into chunks that are embedded right away,
+// so it is searchable by the next message sent in the project.
+func (s *projectSvc) AddFile(projectID string, file *fileutil.File, user *model.User) (*model.ProjectFile, error) {
+	if _, err := s.findProject(projectID, user); err != nil {
+		return nil, err
+	}
+	if file.Size > maxProjectFileSize {
+		return nil, errs.Newf(errs.InvalidArgument, nil, "File %q is larger than %d MB.", file.Filename, maxProjectFileSize>>20)
+	}
+	if !utf8.Valid(file.Bytes) || bytes.IndexByte(file.Bytes, 0) >= 0 {
+		return nil, errs.Newf(errs.InvalidArgument, nil, "File %q is not a text file.", file.Filename)
+	}
+
+	chunks := chunkText(string(file.Bytes), projectChunkLength, projectChunkOverlap)
+	if len(chunks) == 0 {
+		return nil, errs.Newf(errs.InvalidArgument, nil, "File %q is empty.", file.Filename)
+	}
+	vectors, err := s.embeddings.embedTexts(s.ctx, chunks)
+	if err != nil {
+		return nil, err
+	}
+
+	projectFile := &model.ProjectFile{
+		ProjectID:   projectID,
+		Name:        file.Filename,
+		ContentType: http.DetectContentType(file.Bytes),
+		Size:        file.Size,
+	}
+	// a file without chunks would never show up in the prompts
+	err = s.stg.Atomic(func(stg storage.Storage) error {
+		if err := stg.ProjectFile(s.ctx).CreateOne(projectFile); err != nil {
+			return errs.Wrapf(err, "failed to save project file")
+		}
+		fileChunks := make([]*model.ProjectFileChunk, len(chunks))
+		for i, chunk := range chunks {
+			fileChunks[i] = &model.ProjectFileChunk{
+				FileID:     projectFile.ID.String(),
+				ProjectID:  projectID,
+				ChunkIndex: i,
+				Content:    chunk,
+				Embedding:  vectors[i],
+			}
+		}
+		if err := stg.ProjectFile(s.ctx).CreateChunks(fileChunks); err != nil {
+			return errs.Wrapf(err, "failed to save project file chunks")
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return projectFile, nil
+}
+
+func (s *projectSvc) DeleteFile(projectID, fileID string, user *model.User) error {
+	if _, err := s.findProject(projectID, user); err != nil {
+		return err
+	}
+	file, err := s.stg.ProjectFile(s.ctx).FindById(fileID)
+	if err != nil {
+		return errs.Wrapf(err, "failed to find project file")
+	}
+	if file.ProjectID != projectID {
+		return errs.Newf(errs.NotFound, nil, "File %q is not part of project %q.", fileID, projectID)
+	}
+	return s.stg.ProjectFile(s.ctx).DeleteById(fileID)
+}
+
+func (s *projectSvc) findProject(id string, user *model.User) (*model.Project, error) {
+	project, err := s.stg.Project(s.ctx).FindById(id)
+	if err != nil {
+		return nil, errs.Wrapf(err, "failed to find project")
+	}
+	if project.UserId != user.ID.String() {
+		return nil, errors.New("permission denied")
+	}
+	return project, nil
+}
+
+func validateProject(project *model.Project) error {
+	project.Name = strings.TrimSpace(project.Name)
+	if project.Name == "" {
+		return errs.Newf(errs.InvalidArgument, nil, "project name must not be empty")
+	}
+	if _, ok := model.FindAgent(project.DefaultAgent); !ok {
+		return errs.Newf(errs.InvalidArgument, nil, "Unknown agent %q.", project.DefaultAgent)
+	}
+	return nil
+}
+
+// chunkText splits the text into overlapping chunks of at most length runes, preferably at line or word breaks.
+func chunkText(text string, length, overlap int) []string {
+	runes := []rune(strings.TrimSpace(text))
+	chunks := make([]string, 0, len(runes)/(length-overlap)+1)
+	for start := 0; start < len(runes); {
+		end := min(start+length, len(runes))
+		if end < len(runes) {
+			// end at the last break of the second half of the chunk
+			if i := lastBreak(runes[start+length/2 : end]); i >= 0 {
+				end = start + length/2 + i + 1
+			}
+		}
+		if chunk := strings.TrimSpace(string(runes[start:end])); chunk != "" {
+			chunks = append(chunks, chunk)
+		}
+		if end == len(runes) {
+			break
+		}
+
+		// start the next chunk at the first break of the overlap
+		next := max(end-overlap, start+1)
+		if i := firstBreak(runes[next:end]); i >= 0 {
+			next += i + 1
+		}
+		start = next
+	}
+	return chunks
+}
+
+func lastBreak(runes []rune) int {
+	for i := len(runes) - 1; i >= 0; i-- {
+		if unicode.IsSpace(runes[i]) {
+			return i
+		}
+	}
+	return -1
+}
+
+func firstBreak(runes []rune) int {
+	for i, r := range runes {
+		if unicode.IsSpace(r) {
+			return i
+		}
+	}
+	return -1
+}
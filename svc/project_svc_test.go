@@ -0,0 +1,29 @@
+package svc
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestChunkText(t *testing.T) {
+	words := []string{"lorem", "ipsum", "dolor", "sit", "amet"}
+	text := strings.Repeat(strings.Join(words, " ")+" ", 200)
+
+	chunks := chunkText(text, 100, 20)
+
+	require.Greater(t, len(chunks), 1)
+	for _, chunk := range chunks {
+		require.LessOrEqual(t, utf8.RuneCountInString(chunk), 100)
+		// chunks are cut at word breaks
+		fields := strings.Fields(chunk)
+		require.Contains(t, words, fields[0])
+		require.Contains(t, words, fields[len(fields)-1])
+	}
+	require.True(t, strings.HasSuffix(chunks[len(chunks)-1], "sit amet"))
+
+	require.Empty(t, chunkText("  \n ", 100, 20))
+	require.Equal(t, []string{"short"}, chunkText("short", 100, 20))
+}
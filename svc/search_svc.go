@@ -0,0 +1,178 @@
+package svc
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/amahdian/ai-assistant-be/domain/model"
+	"github.com/amahdian/ai-assistant-be/domain/model/common"
+	"github.com/amahdian/ai-assistant-be/global/errs"
+	"github.com/amahdian/ai-assistant-be/storage"
+	"github.com/samber/lo"
+)
+
+const (
+	defaultSemanticSearchLimit = 20
+	// semanticCandidatesFactor over-fetches the nearest messages, since several of them may belong to the same chat.
+	semanticCandidatesFactor = 4
+	// reciprocalRankConstant dampens the weight of the top ranks when the rankings are merged.
+	reciprocalRankConstant = 60
+	semanticSnippetLength  = 200
+)
+
+type SearchSvc interface {
+	SearchMessages(query string, pagination *common.Pagination, user *model.User) ([]*model.ChatSearchResult, error)
+	SemanticSearch(query string, mode model.SearchMode, limit int, user *model.User) ([]*model.ChatSearchResult, error)
+}
+
+type searchSvc struct {
+	ctx        context.Context
+	stg        storage.Storage
+	embeddings *embeddingIndexer
+}
+
+func newSearchSvc(ctx context.Context, stg storage.Storage, embeddings *embeddingIndexer) SearchSvc {
+	return &searchSvc{
+		ctx:        ctx,
+		stg:        stg,
+		embeddings: embeddings,
+	}
+}
+
+func (s *searchSvc) SearchMessages(query string, pagination *common.Pagination, user *model.User) ([]*model.ChatSearchResult, error) {
+	query = strings.TrimSpace(query)
+	if query == "" {
+		return nil, errs.Newf(errs.InvalidArgument, nil, "search query must not be empty")
+	}
+	if pagination.PageSize == 0 {
+		pagination.PageSize = common.DefaultPageSize
+	}
+
+	results, err := s.stg.Message(s.ctx).SearchByUserId(user.ID.String(), query, pagination)
+	if err != nil {
+		return nil, errs.Wrapf(err, "failed to search messages")
+	}
+	return results, nil
+}
+
+// SemanticSearch returns the chats of the user closest in meaning to the query.
+// In the hybrid mode the semantic ranking is merged with the full-text one.
+func (s *searchSvc) SemanticSearch(query string, mode model.SearchMode, limit int, user *model.User) ([]*model.ChatSearchResult, error) {
+	query = strings.TrimSpace(query)
+	if query == "" {
+		return nil, errs.Newf(errs.InvalidArgument, nil, "search query must not be empty")
+	}
+	if limit == 0 {
+		limit = defaultSemanticSearchLimit
+	}
+
+	semantic, err := s.semanticResults(query, limit, user)
+	if err != nil {
+		return nil, err
+	}
+	if mode != model.SearchModeHybrid {
+		return semantic, nil
+	}
+
+	fullText, err := s.stg.Message(s.ctx).SearchByUserId(user.ID.String(), query, &common.Pagination{PageSize: limit})
+	if err != nil {
+		return nil, errs.Wrapf(err, "failed to search messages")
+	}
+	return mergeSearchResults(limit, fullText, semantic), nil
+}
+
+func (s *searchSvc) semanticResults(query string, limit int, user *model.User) ([]*model.ChatSearchResult, error) {
+	vector, err := s.embeddings.embedQuery(s.ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	matches, err := s.embeddings.vectors(s.ctx).SearchByUserId(user.ID.String(), vector, limit*semanticCandidatesFactor)
+	if err != nil {
+		return nil, errs.Wrapf(err, "failed to search embeddings")
+	}
+	if len(matches) == 0 {
+		return []*model.ChatSearchResult{}, nil
+	}
+
+	messageIds := lo.Map(matches, func(m *model.EmbeddingMatch, _ int) string { return m.MessageID })
+	messages, err := s.stg.Message(s.ctx).ListByIds(messageIds)
+	if err != nil {
+		return nil, errs.Wrapf(err, "failed to list messages")
+	}
+	// superseded replies are left in the index but never shown
+	messageById := lo.SliceToMap(activeMessages(messages), func(m *model.Message) (string, *model.Message) {
+		return m.ID.String(), m
+	})
+
+	chatIds := lo.Uniq(lo.Map(matches, func(m *model.EmbeddingMatch, _ int) string { return m.ChatID }))
+	chats, err := s.stg.Chat(s.ctx).ListByIds(chatIds)
+	if err != nil {
+		return nil, errs.Wrapf(err, "failed to list chats")
+	}
+	chatById := lo.SliceToMap(chats, func(c *model.Chat) (string, *model.Chat) { return c.ID.String(), c })
+
+	// matches are sorted by similarity, so the first match of every chat is its best one
+	results := make([]*model.ChatSearchResult, 0, limit)
+	seenChats := make(map[string]bool)
+	for _, match := range matches {
+		message, chat := messageById[match.MessageID], chatById[match.ChatID]
+		if message == nil || chat == nil || chat.UserId != user.ID.String() || seenChats[match.ChatID] {
+			continue
+		}
+		seenChats[match.ChatID] = true
+		results = append(results, &model.ChatSearchResult{
+			ChatID:    match.ChatID,
+			ChatTitle: chat.Title,
+			MessageID: match.MessageID,
+			Role:      message.Role,
+			Snippet:   snippet(message.Content, semanticSnippetLength),
+			Rank:      match.Similarity,
+			CreatedAt: message.CreatedAt,
+		})
+		if len(results) == limit {
+			break
+		}
+	}
+	return results, nil
+}
+
+// mergeSearchResults merges rankings of chats with reciprocal rank fusion.
+// A chat found by several rankings keeps the result of the first one, so full-text results should come first
+// to keep their highlighted snippets.
+func mergeSearchResults(limit int, rankings ...[]*model.ChatSearchResult) []*model.ChatSearchResult {
+	scores := make(map[string]float64)
+	resultByChat := make(map[string]*model.ChatSearchResult)
+	for _, ranking := range rankings {
+		for rank, result := range ranking {
+			scores[result.ChatID] += 1 / float64(reciprocalRankConstant+rank+1)
+			if _, ok := resultByChat[result.ChatID]; !ok {
+				resultByChat[result.ChatID] = result
+			}
+		}
+	}
+
+	merged := lo.Values(resultByChat)
+	for _, result := range merged {
+		result.Rank = scores[result.ChatID]
+	}
+	sort.Slice(merged, func(i, j int) bool {
+		if merged[i].Rank != merged[j].Rank {
+			return merged[i].Rank > merged[j].Rank
+		}
+		return merged[i].CreatedAt.After(merged[j].CreatedAt)
+	})
+	if len(merged) > limit {
+		merged = merged[:limit]
+	}
+	return merged
+}
+
+func snippet(content string, length int) string {
+	truncated := truncateRunes(content, length)
+	if truncated != content {
+		truncated += "…"
+	}
+	return truncated
+}
@@ -0,0 +1,88 @@
+package svc
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/amahdian/ai-assistant-be/clients"
+	"github.com/amahdian/ai-assistant-be/domain/model"
+	"github.com/amahdian/ai-assistant-be/domain/model/common"
+	"github.com/amahdian/ai-assistant-be/storage"
+	"github.com/amahdian/ai-assistant-be/storage/memory"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+// topicEmbeddingClient embeds texts on a fixed set of topics, one dimension per topic.
+type topicEmbeddingClient struct {
+	clients.GPTClient
+	topics []string
+}
+
+func (c *topicEmbeddingClient) CreateEmbeddings(_ context.Context, inputs []string) ([]common.Vector, error) {
+	vectors := make([]common.Vector, len(inputs))
+	for i, input := range inputs {
+		vector := make(common.Vector, len(c.topics))
+		for j, topic := range c.topics {
+			if strings.Contains(strings.ToLower(input), topic) {
+				vector[j] = 1
+			}
+		}
+		vectors[i] = vector
+	}
+	return vectors, nil
+}
+
+func TestEmbeddingIndexer_SearchNearestOfUser(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	index := memory.NewEmbeddingStg()
+	client := &topicEmbeddingClient{topics: []string{"berlin", "rent", "recipe"}}
+	indexer := newEmbeddingIndexer(ctx, client, func(context.Context) storage.EmbeddingStorage { return index })
+
+	rent := &model.Message{ID: uuid.New(), ChatID: "chat-1", Content: "How much is the rent for a flat in Berlin?"}
+	recipe := &model.Message{ID: uuid.New(), ChatID: "chat-2", Content: "Give me a recipe for pancakes"}
+	otherUser := &model.Message{ID: uuid.New(), ChatID: "chat-3", Content: "Renting in Berlin as a student"}
+	indexer.enqueue(rent, "user-a")
+	indexer.enqueue(recipe, "user-a")
+	indexer.enqueue(otherUser, "user-b")
+
+	query, err := indexer.embedQuery(ctx, "that conversation about rent in berlin")
+	require.NoError(t, err)
+
+	var matches []*model.EmbeddingMatch
+	deadline := time.Now().Add(5 * time.Second)
+	for len(matches) < 2 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+		matches, err = index.SearchByUserId("user-a", query, 10)
+		require.NoError(t, err)
+	}
+
+	require.Len(t, matches, 2)
+	require.Equal(t, rent.ID.String(), matches[0].MessageID)
+	require.InDelta(t, 1, matches[0].Similarity, 1e-6)
+	require.Equal(t, recipe.ID.String(), matches[1].MessageID)
+}
+
+func TestMergeSearchResults(t *testing.T) {
+	now := time.Now()
+	fullText := []*model.ChatSearchResult{
+		{ChatID: "a", Snippet: "<mark>rent</mark> in Berlin", CreatedAt: now},
+		{ChatID: "b", Snippet: "<mark>rent</mark> a car", CreatedAt: now},
+	}
+	semantic := []*model.ChatSearchResult{
+		{ChatID: "c", Snippet: "flat hunting", CreatedAt: now},
+		{ChatID: "a", Snippet: "rent in Berlin", CreatedAt: now},
+	}
+
+	merged := mergeSearchResults(2, fullText, semantic)
+
+	require.Len(t, merged, 2)
+	require.Equal(t, "a", merged[0].ChatID)
+	require.Equal(t, "<mark>rent</mark> in Berlin", merged[0].Snippet)
+	require.InDelta(t, 1.0/61+1.0/62, merged[0].Rank, 1e-9)
+	require.Equal(t, "c", merged[1].ChatID)
+}
@@ -0,0 +1,159 @@
+package svc
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"time"
+
+	"github.com/amahdian/ai-assistant-be/domain/model"
+	"github.com/amahdian/ai-assistant-be/global/errs"
+	"github.com/amahdian/ai-assistant-be/storage"
+)
+
+// shareTokenBytes is the number of random bytes of a share token.
+const shareTokenBytes = 32
+
+type ShareSvc interface {
+	// ShareChat snapshots the active messages of the chat behind a new public link, which never expires when expiresAt is nil.
+	ShareChat(chatID string, expiresAt *time.Time, user *model.User) (*model.ChatShare, error)
+	ListShares(chatID string, user *model.User) ([]*model.ChatShare, error)
+	RevokeShare(chatID, shareID string, user *model.User) error
+	// ViewSharedChat returns the snapshot of the link and counts the view, it requires no user.
+	ViewSharedChat(token string) (*model.SharedChat, error)
+	// ContinueSharedChat copies the snapshot of the link into a new chat of the user.
+	ContinueSharedChat(token string, user *model.User) (*model.Chat, error)
+}
+
+type shareSvc struct {
+	ctx        context.Context
+	stg        storage.Storage
+	embeddings *embeddingIndexer
+}
+
+func newShareSvc(ctx context.Context, stg storage.Storage, embeddings *embeddingIndexer) ShareSvc {
+	return &shareSvc{
+		ctx:        ctx,
+		stg:        stg,
+		embeddings: embeddings,
+	}
+}
+
+func (s *shareSvc) ShareChat(chatID string, expiresAt *time.Time, user *model.User) (*model.ChatShare, error) {
+	now := time.Now()
+	if expiresAt != nil && !expiresAt.After(now) {
+		return nil, errs.Newf(errs.InvalidArgument, nil, "The expiry must be in the future.")
+	}
+
+	chat, err := s.stg.Chat(s.ctx).FindById(chatID)
+	if err != nil {
+		return nil, errs.Wrapf(err, "failed to find chat")
+	}
+	if chat.UserId != user.ID.String() {
+		return nil, errors.New("permission denied")
+	}
+	messages, err := s.stg.Message(s.ctx).ListByChatId(chatID)
+	if err != nil {
+		return nil, errs.Wrapf(err, "failed to list messages")
+	}
+	if len(messages) == 0 {
+		return nil, errs.Newf(errs.FailedPrecondition, nil, "Chat %q has no messages to share.", chatID)
+	}
+
+	token, err := newShareToken()
+	if err != nil {
+		return nil, errs.Wrapf(err, "failed to create share token")
+	}
+	share := &model.ChatShare{
+		Token:     token,
+		ChatID:    chatID,
+		UserId:    user.ID.String(),
+		Snapshot:  activeExport(newChatExport(chat, messages, now)),
+		ExpiresAt: expiresAt,
+	}
+	if err = s.stg.ChatShare(s.ctx).CreateOne(share); err != nil {
+		return nil, errs.Wrapf(err, "failed to share chat")
+	}
+	return share, nil
+}
+
+func (s *shareSvc) ListShares(chatID string, user *model.User) ([]*model.ChatShare, error) {
+	if err := checkChatsOwnership(s.ctx, s.stg, []string{chatID}, user); err != nil {
+		return nil, err
+	}
+	shares, err := s.stg.ChatShare(s.ctx).ListByChatId(chatID)
+	if err != nil {
+		return nil, errs.Wrapf(err, "failed to list shares")
+	}
+	return shares, nil
+}
+
+func (s *shareSvc) RevokeShare(chatID, shareID string, user *model.User) error {
+	share, err := s.stg.ChatShare(s.ctx).FindById(shareID)
+	if err != nil {
+		return errs.Wrapf(err, "failed to find share")
+	}
+	if share.UserId != user.ID.String() || share.ChatID != chatID {
+		return errors.New("permission denied")
+	}
+	if err = s.stg.ChatShare(s.ctx).DeleteById(shareID); err != nil {
+		return errs.Wrapf(err, "failed to revoke share")
+	}
+	return nil
+}
+
+func (s *shareSvc) ViewSharedChat(token string) (*model.SharedChat, error) {
+	share, err := s.findShare(token)
+	if err != nil {
+		return nil, err
+	}
+	if err = s.stg.ChatShare(s.ctx).IncrementViews(share.ID.String()); err != nil {
+		return nil, errs.Wrapf(err, "failed to count the view")
+	}
+
+	return &model.SharedChat{
+		Title:     share.Snapshot.Title,
+		Messages:  share.Snapshot.Messages,
+		SharedAt:  share.CreatedAt,
+		ExpiresAt: share.ExpiresAt,
+		ViewCount: share.ViewCount + 1,
+	}, nil
+}
+
+func (s *shareSvc) ContinueSharedChat(token string, user *model.User) (*model.Chat, error) {
+	share, err := s.findShare(token)
+	if err != nil {
+		return nil, err
+	}
+	imported, err := importChatExport(share.Snapshot, user)
+	if err != nil {
+		return nil, errs.Newf(errs.FailedPrecondition, err, "The shared chat can not be continued.")
+	}
+
+	chats, err := createImportedChats(s.ctx, s.stg, s.embeddings, []*importedChat{imported}, user)
+	if err != nil {
+		return nil, err
+	}
+	return chats[0], nil
+}
+
+// findShare returns the link of the token unless it expired.
+func (s *shareSvc) findShare(token string) (*model.ChatShare, error) {
+	share, err := s.stg.ChatShare(s.ctx).FindByToken(token)
+	if err != nil {
+		return nil, err
+	}
+	if share.Expired(time.Now()) {
+		return nil, errs.Newf(errs.NotFound, nil, "The shared chat has expired.")
+	}
+	return share, nil
+}
+
+func newShareToken() (string, error) {
+	b := make([]byte, shareTokenBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
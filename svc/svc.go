@@ -12,19 +12,36 @@ import (
 type Svc interface {
 	NewUserSvc(ctx context.Context) UserSvc
 	NewChatSvc(ctx context.Context) ChatSvc
+	NewSearchSvc(ctx context.Context) SearchSvc
+	NewFolderSvc(ctx context.Context) FolderSvc
+	NewTagSvc(ctx context.Context) TagSvc
+	NewProjectSvc(ctx context.Context) ProjectSvc
+	NewShareSvc(ctx context.Context) ShareSvc
+	NewFeedbackSvc(ctx context.Context) FeedbackSvc
 }
 
 type svcImpl struct {
-	stg       storage.Storage
-	Envs      *env.Envs
-	gptClient clients.GPTClient
+	stg         storage.Storage
+	Envs        *env.Envs
+	gptClient   clients.GPTClient
+	generations *generationBroker
+	events      *eventHub
+	embeddings  *embeddingIndexer
 }
 
 func NewSvc(stg storage.Storage, envs *env.Envs, gptClient clients.GPTClient) Svc {
+	embeddings := newEmbeddingIndexer(context.Background(), gptClient, stg.Embedding)
+	go embeddings.backfill(context.Background(), stg)
+	go purgeIdempotencyKeys(context.Background(), stg)
+	go purgeDeletedChats(context.Background(), stg, envs.Server.DeletedChatRetention)
+
 	return &svcImpl{
 		stg,
 		envs,
 		gptClient,
+		newGenerationBroker(),
+		newEventHub(),
+		embeddings,
 	}
 }
 
@@ -33,5 +50,29 @@ func (s *svcImpl) NewUserSvc(ctx context.Context) UserSvc {
 }
 
 func (s *svcImpl) NewChatSvc(ctx context.Context) ChatSvc {
-	return newChatSvc(ctx, s.stg, s.gptClient)
+	return newChatSvc(ctx, s.stg, s.gptClient, s.generations, s.events, s.embeddings, s.Envs.Server.IdempotencyKeyTTL)
+}
+
+func (s *svcImpl) NewSearchSvc(ctx context.Context) SearchSvc {
+	return newSearchSvc(ctx, s.stg, s.embeddings)
+}
+
+func (s *svcImpl) NewFolderSvc(ctx context.Context) FolderSvc {
+	return newFolderSvc(ctx, s.stg)
+}
+
+func (s *svcImpl) NewTagSvc(ctx context.Context) TagSvc {
+	return newTagSvc(ctx, s.stg)
+}
+
+func (s *svcImpl) NewProjectSvc(ctx context.Context) ProjectSvc {
+	return newProjectSvc(ctx, s.stg, s.embeddings)
+}
+
+func (s *svcImpl) NewShareSvc(ctx context.Context) ShareSvc {
+	return newShareSvc(ctx, s.stg, s.embeddings)
+}
+
+func (s *svcImpl) NewFeedbackSvc(ctx context.Context) FeedbackSvc {
+	return newFeedbackSvc(ctx, s.stg)
 }
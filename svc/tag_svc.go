@@ -0,0 +1,96 @@
+package svc
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/amahdian/ai-assistant-be/domain/model"
+	"github.com/amahdian/ai-assistant-be/global/errs"
+	"github.com/amahdian/ai-assistant-be/storage"
+	"github.com/samber/lo"
+)
+
+type TagSvc interface {
+	ListTags(user *model.User) ([]*model.Tag, error)
+	CreateTag(name string, user *model.User) (*model.Tag, error)
+	DeleteTag(id string, user *model.User) error
+	// TagChats adds and removes the tags on every chat.
+	TagChats(chatIds, addTagIds, removeTagIds []string, user *model.User) error
+}
+
+type tagSvc struct {
+	ctx context.Context
+	stg storage.Storage
+}
+
+func newTagSvc(ctx context.Context, stg storage.Storage) TagSvc {
+	return &tagSvc{
+		ctx: ctx,
+		stg: stg,
+	}
+}
+
+func (s *tagSvc) ListTags(user *model.User) ([]*model.Tag, error) {
+	tags, err := s.stg.Tag(s.ctx).ListByUserId(user.ID.String())
+	if err != nil {
+		return nil, errs.Wrapf(err, "failed to list tags")
+	}
+	return tags, nil
+}
+
+func (s *tagSvc) CreateTag(name string, user *model.User) (*model.Tag, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, errs.Newf(errs.InvalidArgument, nil, "tag name must not be empty")
+	}
+	tag := &model.Tag{
+		UserId: user.ID.String(),
+		Name:   name,
+	}
+	if err := s.stg.Tag(s.ctx).CreateOne(tag); err != nil {
+		return nil, errs.Wrapf(err, "failed to create tag")
+	}
+	return tag, nil
+}
+
+func (s *tagSvc) DeleteTag(id string, user *model.User) error {
+	tag, err := s.stg.Tag(s.ctx).FindById(id)
+	if err != nil {
+		return errs.Wrapf(err, "failed to find tag")
+	}
+	if tag.UserId != user.ID.String() {
+		return errors.New("permission denied")
+	}
+	return s.stg.Tag(s.ctx).DeleteById(id)
+}
+
+func (s *tagSvc) TagChats(chatIds, addTagIds, removeTagIds []string, user *model.User) error {
+	if err := checkChatsOwnership(s.ctx, s.stg, chatIds, user); err != nil {
+		return err
+	}
+
+	tagIds := lo.Uniq(append(append([]string{}, addTagIds...), removeTagIds...))
+	tags, err := s.stg.Tag(s.ctx).ListByIds(tagIds)
+	if err != nil {
+		return errs.Wrapf(err, "failed to list tags")
+	}
+	if len(tags) != len(tagIds) {
+		return errs.Newf(errs.NotFound, nil, "Some of the tags could not be found.")
+	}
+	for _, tag := range tags {
+		if tag.UserId != user.ID.String() {
+			return errors.New("permission denied")
+		}
+	}
+
+	return s.stg.Atomic(func(stg storage.Storage) error {
+		if err := stg.Tag(s.ctx).RemoveFromChats(chatIds, removeTagIds); err != nil {
+			return errs.Wrapf(err, "failed to untag chats")
+		}
+		if err := stg.Tag(s.ctx).AddToChats(chatIds, addTagIds); err != nil {
+			return errs.Wrapf(err, "failed to tag chats")
+		}
+		return nil
+	})
+}
@@ -0,0 +1,101 @@
+package svc
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/amahdian/ai-assistant-be/clients"
+	"github.com/amahdian/ai-assistant-be/domain/model"
+	"github.com/amahdian/ai-assistant-be/global/errs"
+	"github.com/amahdian/ai-assistant-be/pkg/logger"
+	"github.com/amahdian/ai-assistant-be/svc/auth"
+)
+
+var responseLengthInstructions = map[model.ResponseLength]string{
+	model.ResponseLengthShort:    "Keep your answers short and to the point.",
+	model.ResponseLengthBalanced: "Keep your answers reasonably concise, only go into details when they are needed.",
+	model.ResponseLengthDetailed: "Give detailed and thorough answers.",
+}
+
+var responseStyleInstructions = map[model.ResponseStyle]string{
+	model.ResponseStyleCasual:    "Use a casual and friendly tone.",
+	model.ResponseStyleFormal:    "Use a formal and professional tone.",
+	model.ResponseStyleTechnical: "Use a precise and technical tone.",
+}
+
+func (s *userSvc) GetSettings(user *model.User) (*model.UserSettings, error) {
+	if settings := auth.UserSettingsFromCtx(s.ctx); settings != nil {
+		return settings, nil
+	}
+	settings, err := s.stg.UserSettings(s.ctx).FindByUserId(user.ID.String())
+	if err != nil {
+		return nil, errs.Wrapf(err, "failed to find user settings")
+	}
+	return settings, nil
+}
+
+func (s *userSvc) UpdateSettings(settings *model.UserSettings, user *model.User) (*model.UserSettings, error) {
+	if err := validateUserSettings(settings); err != nil {
+		return nil, err
+	}
+	settings.UserId = user.ID.String()
+	if err := s.stg.UserSettings(s.ctx).Save(settings); err != nil {
+		return nil, errs.Wrapf(err, "failed to save user settings")
+	}
+	return settings, nil
+}
+
+func validateUserSettings(settings *model.UserSettings) error {
+	if _, ok := model.FindAgent(settings.DefaultAgent); !ok {
+		return errs.Newf(errs.InvalidArgument, nil, "Unknown agent %q.", settings.DefaultAgent)
+	}
+	if settings.DefaultModel != "" && !slices.Contains(clients.ChatModels, settings.DefaultModel) {
+		return errs.Newf(errs.InvalidArgument, nil, "Unknown model %q, the supported models are %s.",
+			settings.DefaultModel, strings.Join(clients.ChatModels, ", "))
+	}
+	return nil
+}
+
+// userSettings returns the settings of the chat's owner, the ones loaded by the route when it declares them.
+// Failures are only logged, the chat is answered without the settings.
+func (s *chatSvc) userSettings(chat *model.Chat) *model.UserSettings {
+	if settings := auth.UserSettingsFromCtx(s.ctx); settings != nil && settings.UserId == chat.UserId {
+		return settings
+	}
+	settings, err := s.stg.UserSettings(s.ctx).FindByUserId(chat.UserId)
+	if err != nil {
+		logger.Errorf("failed to load settings of user %s: %v", chat.UserId, err)
+		return model.NewUserSettings(chat.UserId)
+	}
+	return settings
+}
+
+// chatModel returns the model answering the user.
+func chatModel(settings *model.UserSettings) string {
+	if settings.DefaultModel != "" {
+		return settings.DefaultModel
+	}
+	return clients.ChatModel
+}
+
+// settingsInstructions turns the custom instructions and preferences of the user into system prompt instructions.
+func settingsInstructions(settings *model.UserSettings) string {
+	var instructions []string
+	if settings.AboutMe != "" {
+		instructions = append(instructions, fmt.Sprintf("The user shared this about themselves:\n%s", settings.AboutMe))
+	}
+	if settings.HowToRespond != "" {
+		instructions = append(instructions, fmt.Sprintf("The user wants you to respond like this:\n%s", settings.HowToRespond))
+	}
+	if settings.Language != "" {
+		instructions = append(instructions, fmt.Sprintf("Always answer in %s unless the user asks for another language.", settings.Language))
+	}
+	if instruction, ok := responseLengthInstructions[settings.ResponseLength]; ok {
+		instructions = append(instructions, instruction)
+	}
+	if instruction, ok := responseStyleInstructions[settings.ResponseStyle]; ok {
+		instructions = append(instructions, instruction)
+	}
+	return strings.Join(instructions, "\n\n")
+}
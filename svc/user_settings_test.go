@@ -0,0 +1,32 @@
+package svc
+
+import (
+	"testing"
+
+	"github.com/amahdian/ai-assistant-be/clients"
+	"github.com/amahdian/ai-assistant-be/domain/model"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUserSettings(t *testing.T) {
+	require.True(t, model.NewUserSettings("user").MemoryEnabled)
+	require.Empty(t, settingsInstructions(model.NewUserSettings("user")))
+	require.Equal(t, clients.ChatModel, chatModel(&model.UserSettings{}))
+
+	settings := &model.UserSettings{
+		AboutMe:        "I am a nurse.",
+		Language:       "German",
+		DefaultModel:   "gpt-4o-mini",
+		ResponseLength: model.ResponseLengthShort,
+	}
+	instructions := settingsInstructions(settings)
+	require.Contains(t, instructions, "I am a nurse.")
+	require.Contains(t, instructions, "Always answer in German")
+	require.Contains(t, instructions, responseLengthInstructions[model.ResponseLengthShort])
+	require.NotContains(t, instructions, "respond like this")
+	require.Equal(t, "gpt-4o-mini", chatModel(settings))
+
+	require.NoError(t, validateUserSettings(settings))
+	require.Error(t, validateUserSettings(&model.UserSettings{DefaultModel: "gpt-2"}))
+	require.Error(t, validateUserSettings(&model.UserSettings{DefaultAgent: "nobody"}))
+}
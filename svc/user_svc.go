@@ -16,6 +16,13 @@ import (
 type UserSvc interface {
 	Login(email, password string) (string, error)
 	Register(email, password string) (string, error)
+	GetSettings(user *model.User) (*model.UserSettings, error)
+	// UpdateSettings replaces all the settings of the user.
+	UpdateSettings(settings *model.UserSettings, user *model.User) (*model.UserSettings, error)
+	// ListMemories returns what the assistant remembers about the user across their chats.
+	ListMemories(user *model.User) ([]*model.Memory, error)
+	DeleteMemory(id string, user *model.User) error
+	DeleteMemories(user *model.User) error
 }
 
 type userSvc struct {